@@ -2,21 +2,86 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
-	"sync"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Azure/aks-periscope/pkg/collector"
 	"github.com/Azure/aks-periscope/pkg/diagnoser"
+	"github.com/Azure/aks-periscope/pkg/encryption"
+	"github.com/Azure/aks-periscope/pkg/events"
 	"github.com/Azure/aks-periscope/pkg/exporter"
 	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/metrics"
+	"github.com/Azure/aks-periscope/pkg/redaction"
+	"github.com/Azure/aks-periscope/pkg/status"
 	"github.com/Azure/aks-periscope/pkg/utils"
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 )
 
+// contentIndexFileName is the name of the file in the temporary directory recording the content exported in previous
+// runs, which is kept for as long as the container is running.
+const contentIndexFileName = "periscope-content-index.json"
+
+// configSourceVariable is the environment variable selecting how changes to the config and secret are seen: either
+// "files" (the default) to poll the mounted files, or "api" to watch the resources through the Kubernetes API.
+const configSourceVariable = "CONFIG_SOURCE"
+
+// metricsAddressVariable is the environment variable specifying the address (e.g. ":9090") to serve Prometheus metrics
+// on. Metrics are not served if it is empty.
+const metricsAddressVariable = "METRICS_ADDRESS"
+
+// statusAddressVariable is the environment variable specifying the address (e.g. ":8080") to serve the liveness,
+// readiness and status endpoints on. They are not served if it is empty. If it is the same as the metrics address,
+// they are served by the same server.
+const statusAddressVariable = "STATUS_ADDRESS"
+
+// logFormatVariable is the environment variable selecting the format of log lines: either "logfmt" (the default) or
+// "json".
+const logFormatVariable = "LOG_FORMAT"
+
 func main() {
+	logFormat, err := logging.ParseFormat(os.Getenv(logFormatVariable))
+	if err != nil {
+		log.Fatalf("Invalid %s value: %v", logFormatVariable, err)
+	}
+	logging.Configure(os.Stderr, logFormat)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "collect":
+			err = runCollect(os.Args[2:])
+		case "decrypt":
+			err = runDecrypt(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s (available: collect, decrypt)", os.Args[1])
+		}
+
+		if err != nil {
+			log.Fatalf("Error running Periscope: %v", err)
+		}
+		return
+	}
+
+	runDaemon()
+}
+
+// runDaemon runs Periscope in the cluster, collecting data whenever the run ID changes.
+func runDaemon() {
 	osIdentifier, err := utils.StringToOSIdentifier(runtime.GOOS)
 	if err != nil {
 		log.Fatalf("cannot determine OS: %v", err)
@@ -27,50 +92,281 @@ func main() {
 		log.Fatalf("failed to get file paths: %v", err)
 	}
 
-	fileSystem := utils.NewFileSystem()
-
-	// Create a watcher for the Run ID file that checks its content every 10 seconds
-	fileWatcher := utils.NewFileContentWatcher(fileSystem, 10*time.Second)
+	// Every read of the run ID (whether polling the mounted file, notifying a change to the ConfigMap, or at the start of a
+	// run) is recorded in the status.
+	tracker := status.NewTracker()
+	runIdPath := knownFilePaths.GetConfigPath(utils.RunIdKey)
+	recordingFileSystem := status.NewRunIdReadRecorder(utils.NewFileSystem(), runIdPath, tracker)
+	fileWatcher, fileSystem, err := createContentWatcher(knownFilePaths, recordingFileSystem)
+	if err != nil {
+		log.Fatalf("failed to create config watcher: %v", err)
+	}
+	if fileSystem != recordingFileSystem {
+		fileSystem = status.NewRunIdReadRecorder(fileSystem, runIdPath, tracker)
+	}
+	if resourceWatcher, ok := fileWatcher.(*utils.ResourceContentWatcher); ok {
+		// The watcher reads the run ID from the ConfigMap itself, rather than polling the mounted file.
+		resourceWatcher.SetReader(fileSystem)
+	}
 
 	// Create a channel for unrecoverable errors
 	errChan := make(chan error)
 
+	reporters := runReporters{metrics: metrics.NewMetrics(), status: tracker}
+	var stopEvents func()
+	reporters.events, stopEvents = createEventRecorder()
+	defer stopEvents()
+	if err := serveHTTP(reporters, errChan); err != nil {
+		log.Fatalf("failed to serve HTTP: %v", err)
+	}
+
 	// Add a watcher for the run ID file content
 	runIdChan := make(chan string)
 	fileWatcher.AddHandler(knownFilePaths.GetConfigPath(utils.RunIdKey), runIdChan, errChan)
 
+	// Runs are also started on the configured schedule (if any), with run IDs generated from the scheduled time. These
+	// are the same on every node, so that the nodes elect a single one to collect each scheduled run's cluster-level
+	// data. The scheduler only starts a run when this loop is waiting for one, so scheduled runs never overlap with
+	// other runs.
+	scheduler := utils.NewScheduler(runIdChan)
+	watchSchedule(fileWatcher, knownFilePaths, scheduler)
+
+	// When the pod is terminated, a run in progress is cancelled, and the data collected so far is exported before
+	// exiting. No more runs are started.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		for {
-			runId := <-runIdChan
-			log.Printf("Starting Periscope run %s", runId)
-			err := run(osIdentifier, knownFilePaths, fileSystem)
-			if err != nil {
-				errChan <- err
+			var runId string
+			select {
+			case <-ctx.Done():
+				return
+			case runId = <-runIdChan:
+			}
+
+			// A failed run (e.g. because of an invalid config value) doesn't stop Periscope, so that it can be fixed by
+			// updating the config and run ID.
+			start := time.Now()
+			if err := run(ctx, osIdentifier, knownFilePaths, fileSystem, runId, reporters); err != nil {
+				slog.Error("Periscope run failed", logging.RunIdKey, runId, logging.Duration(time.Since(start)), logging.Error(err))
+				continue
 			}
 
-			log.Printf("Completed Periscope run %s", runId)
+			if ctx.Err() != nil {
+				slog.Warn("Cancelled Periscope run, partial data exported", logging.RunIdKey, runId, logging.Duration(time.Since(start)))
+				return
+			}
+			slog.Info("Completed Periscope run", logging.RunIdKey, runId, logging.Duration(time.Since(start)))
 		}
 	}()
 
 	fileWatcher.Start()
 
-	// Run until unrecoverable error
-	err = <-errChan
-	log.Fatalf("Error running Periscope: %v", err)
+	// Run until terminated, or an unrecoverable error
+	select {
+	case <-stopped:
+		slog.Info("Stopped Periscope")
+	case err = <-errChan:
+		log.Fatalf("Error running Periscope: %v", err)
+	}
 }
 
-func run(osIdentifier utils.OSIdentifier, knownFilePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor) error {
-	runtimeInfo, err := utils.GetRuntimeInfo(fileSystem, knownFilePaths)
+// createEventRecorder creates the recorder for Kubernetes Events against this node, along with the function to stop it.
+// Events are not essential, so if the recorder cannot be created, this is logged and no Events are recorded.
+func createEventRecorder() (*events.Recorder, func()) {
+	config, err := restclient.InClusterConfig()
+	if err != nil {
+		slog.Warn("Could not load kubeconfig, Events will not be recorded", logging.Error(err))
+		return nil, func() {}
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatalf("Failed to get runtime information: %v", err)
+		slog.Warn("Could not create clientset, Events will not be recorded", logging.Error(err))
+		return nil, func() {}
+	}
+
+	return events.NewBroadcastingRecorder(clientset, os.Getenv("HOST_NODE_NAME"))
+}
+
+// serveHTTP serves the metrics and status endpoints on their configured addresses (if any), sharing a server if they
+// are the same.
+func serveHTTP(reporters runReporters, errChan chan error) error {
+	muxes := map[string]*http.ServeMux{}
+	getMux := func(address string) *http.ServeMux {
+		if _, ok := muxes[address]; !ok {
+			muxes[address] = http.NewServeMux()
+		}
+		return muxes[address]
+	}
+
+	if address := os.Getenv(metricsAddressVariable); address != "" {
+		getMux(address).Handle(metrics.MetricsPath, reporters.metrics.Handler())
+	}
+	if address := os.Getenv(statusAddressVariable); address != "" {
+		reporters.status.RegisterHandlers(getMux(address))
+	}
+
+	for address, mux := range muxes {
+		if err := utils.ServeHTTP(address, mux, errChan); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// watchSchedule updates the scheduler whenever the schedule config value changes. The schedule is optional, so an error
+// reading it (e.g. because it is not set) just means there are no scheduled runs, and an invalid schedule is logged.
+func watchSchedule(fileWatcher utils.ContentWatcher, knownFilePaths *utils.KnownFilePaths, scheduler *utils.Scheduler) {
+	scheduleChan := make(chan string)
+	scheduleErrChan := make(chan error)
+	fileWatcher.AddHandler(knownFilePaths.GetConfigPath(utils.ScheduleKey), scheduleChan, scheduleErrChan)
+
+	go func() {
+		currentValue := ""
+		for {
+			value := ""
+			select {
+			case value = <-scheduleChan:
+			case <-scheduleErrChan:
+			}
+
+			value = strings.TrimSpace(value)
+			if value == currentValue {
+				continue
+			}
+			currentValue = value
+
+			schedule, err := utils.ParseSchedule(value)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("Invalid %s value, scheduled runs are disabled", utils.ScheduleKey), logging.Error(err))
+			} else if schedule == nil {
+				slog.Info("Scheduled runs are disabled")
+			} else {
+				slog.Info("Scheduled runs are enabled", "schedule", value)
+			}
+
+			scheduler.SetSchedule(schedule)
+		}
+	}()
+}
+
+// createContentWatcher creates the watcher for the config and secret files, along with the FileSystemAccessor to read
+// them (and all other files) from, which is consistent with the watcher's notifications.
+func createContentWatcher(knownFilePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor) (utils.ContentWatcher, interfaces.FileSystemAccessor, error) {
+	switch source := os.Getenv(configSourceVariable); source {
+	case "", "files":
+		// Check the content of the mounted files every 10 seconds
+		return utils.NewFileContentWatcher(fileSystem, 10*time.Second), fileSystem, nil
+	case "api":
+		config, err := restclient.InClusterConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot load kubeconfig: %w", err)
+		}
+
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot create clientset: %w", err)
+		}
+
+		namespace := os.Getenv("POD_NAMESPACE")
+		if len(namespace) == 0 {
+			namespace, err = utils.GetContent(func() (io.ReadCloser, error) {
+				return fileSystem.GetFileReader(knownFilePaths.ServiceAccountNamespace)
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("cannot determine namespace: %w", err)
+			}
+		}
+
+		watcher := utils.NewResourceContentWatcher(clientset, strings.TrimSpace(namespace), knownFilePaths, fileSystem)
+		return watcher, watcher, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown %s value: %s (available: files, api)", configSourceVariable, source)
+	}
+}
+
+// run collects and exports all the data for a run, reporting its progress and result to the specified reporters. The
+// run's log is captured and included in the archive. An error is returned if the run failed before running any
+// producers (e.g. because of an invalid config value), in which case it is reported as failed, and only a manifest
+// recording the error is exported (if possible).
+func run(ctx context.Context, osIdentifier utils.OSIdentifier, knownFilePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor, runId string, reporters runReporters) (err error) {
+	// The run has failed unless it gets as far as exporting the archive.
+	start := time.Now()
+	result := metrics.RunFailed
+	failedProducers := []string{}
+	reporters.status.StartRun(runId)
+	defer func() {
+		reporters.metrics.ObserveRun(result, start)
+		reporters.status.CompleteRun(string(result), err)
+		if err != nil {
+			reporters.events.RunFailed(runId, err)
+			return
+		}
+		reporters.events.RunCompleted(runId, result, time.Since(start), failedProducers)
+	}()
+
+	// Invalid config values are reported once the run has started, so that they are recorded in its manifest.
+	runtimeInfo, configErr := utils.GetRuntimeInfo(fileSystem, knownFilePaths)
+	if runtimeInfo == nil {
+		return fmt.Errorf("cannot get runtime information: %w", configErr)
 	}
 
+	// The run ID is either the configured value that triggered the run, or generated for a scheduled run.
+	runtimeInfo.Scheduled = runId != runtimeInfo.RunId
+	runtimeInfo.RunId = runId
+
+	reporters.runLog = logging.StartRunLog(runId, runtimeInfo.HostNodeName)
+	defer reporters.runLog.Stop()
+	slog.Info("Starting Periscope run", "scheduled", runtimeInfo.Scheduled)
+	reporters.events.RunStarted(runId, runtimeInfo.Scheduled)
+
 	config, err := restclient.InClusterConfig()
 	if err != nil {
 		return fmt.Errorf("cannot load kubeconfig: %w", err)
 	}
 
-	exp := exporter.NewAzureBlobExporter(runtimeInfo, knownFilePaths, runtimeInfo.RunId)
+	// Values which haven't changed since a recent run are exported as references to that run, rather than being
+	// uploaded again. The index is only valid for the same destination and recipients, so it is scoped to those.
+	var index *exporter.ContentIndex
+	if runtimeInfo.DeduplicationMaxAge > 0 {
+		scope := strings.Join([]string{
+			runtimeInfo.Exporter,
+			runtimeInfo.StorageAccountName,
+			runtimeInfo.StorageContainerName,
+			runtimeInfo.S3Endpoint,
+			runtimeInfo.S3Bucket,
+			runtimeInfo.ExportDirectory,
+			runtimeInfo.EncryptionRecipients,
+		}, "\n")
+		index = exporter.LoadContentIndex(filepath.Join(os.TempDir(), contentIndexFileName), scope)
+	}
+
+	redactionRules, err := redaction.GetRules(runtimeInfo.RedactionRules)
+	if err != nil {
+		configErr = multierror.Append(configErr, fmt.Errorf("invalid %s value: %w", utils.RedactionRulesKey, err))
+	}
+
+	archiveFormat, err := exporter.ParseArchiveFormat(runtimeInfo.ArchiveFormat)
+	if err != nil {
+		configErr = multierror.Append(configErr, fmt.Errorf("invalid %s value: %w", utils.ArchiveFormatKey, err))
+	}
+
+	// If the exporter can't be created (e.g. because of an unknown exporter or invalid encryption recipients), the
+	// errors can only be logged and reported, rather than recorded in a manifest.
+	exp, err := createRunExporter(runtimeInfo, knownFilePaths, index)
+	if err != nil {
+		return multierror.Append(configErr, err)
+	}
+
+	if configErr != nil {
+		exportRunError(ctx, runtimeInfo, exp, configErr)
+		return configErr
+	}
 
 	// Copies self-signed cert information to container if application is running on Azure Stack Cloud.
 	// We need the cert in order to communicate with the storage account.
@@ -80,94 +376,222 @@ func run(osIdentifier utils.OSIdentifier, knownFilePaths *utils.KnownFilePaths,
 		}
 	}
 
-	dnsCollector := collector.NewDNSCollector(osIdentifier, knownFilePaths, fileSystem)
-	kubeletCmdCollector := collector.NewKubeletCmdCollector(osIdentifier, runtimeInfo)
-	networkOutboundCollector := collector.NewNetworkOutboundCollector()
-	collectors := []interfaces.Collector{
-		dnsCollector,
-		kubeletCmdCollector,
-		networkOutboundCollector,
-		collector.NewHelmCollector(config, runtimeInfo),
-		collector.NewIPTablesCollector(osIdentifier, runtimeInfo),
-		collector.NewKubeObjectsCollector(config, runtimeInfo),
-		collector.NewNodeLogsCollector(runtimeInfo, fileSystem),
-		collector.NewOsmCollector(config, runtimeInfo),
-		collector.NewPDBCollector(config, runtimeInfo),
-		collector.NewPodsContainerLogsCollector(config, runtimeInfo),
-		collector.NewSmiCollector(config, runtimeInfo),
-		collector.NewSystemLogsCollector(osIdentifier, runtimeInfo),
-		collector.NewSystemPerfCollector(config, runtimeInfo),
-		collector.NewWindowsLogsCollector(osIdentifier, runtimeInfo, knownFilePaths, fileSystem, 10*time.Second, 20*time.Minute),
-	}
-
-	collectorGrp := new(sync.WaitGroup)
-
-	dataProducers := []interfaces.DataProducer{}
-	for _, c := range collectors {
-		if err := c.CheckSupported(); err != nil {
-			// Log the reason why this collector is not supported, and skip to the next
-			log.Printf("Skipping unsupported collector %s: %v", c.GetName(), err)
-			continue
+	selections := collector.Select(&collector.Dependencies{
+		OSIdentifier:   osIdentifier,
+		KnownFilePaths: knownFilePaths,
+		FileSystem:     fileSystem,
+		Config:         config,
+		RuntimeInfo:    runtimeInfo,
+	})
+
+	// Cluster-scoped collectors are run by a single elected pod, in a separate pipeline exporting cluster-level data.
+	selections, clusterSelections, release := electClusterCollectors(config, runtimeInfo, selections)
+
+	// A dry run only exports the plan of what would be collected, without reading or exporting any data.
+	if runtimeInfo.DryRun {
+		result = runDryRun(ctx, runtimeInfo, knownFilePaths, index, exp, selections, clusterSelections, release)
+		return nil
+	}
+
+	clusterDone := make(chan struct{})
+	clusterResult := metrics.RunSucceeded
+	clusterFailedProducers := []string{}
+	go func() {
+		defer close(clusterDone)
+		if release == nil {
+			return
 		}
+		defer release()
 
-		dataProducers = append(dataProducers, c)
-		collectorGrp.Add(1)
-		go func(c interfaces.Collector) {
-			defer collectorGrp.Done()
+		clusterPipeline, err := runClusterPipeline(ctx, runtimeInfo, knownFilePaths, index, clusterSelections, redactionRules, archiveFormat, &reporters)
+		if err != nil {
+			slog.Error("Could not collect cluster-level data", logging.Error(err))
+		}
+		if clusterPipeline == nil {
+			clusterResult = metrics.RunFailed
+			return
+		}
+		clusterResult = clusterPipeline.result()
+		clusterFailedProducers = clusterPipeline.getFailedProducers()
+	}()
+	defer func() { <-clusterDone }()
 
-			log.Printf("Collector: %s, collect data", c.GetName())
-			err := c.Collect()
-			if err != nil {
-				log.Printf("Collector: %s, collect data failed: %v", c.GetName(), err)
-				return
-			}
+	p := newPipeline(runtimeInfo, exp, redactionRules, archiveFormat, &reporters)
+	p.run(ctx, selections, diagnoser.GetRegistrations())
 
-			log.Printf("Collector: %s, export data", c.GetName())
-			if err = exp.Export(c); err != nil {
-				log.Printf("Collector: %s, export data failed: %v", c.GetName(), err)
-			}
-		}(c)
+	// Make the DNS and network results available in the node's Diagnostic resource.
+	diagnosticFields := map[string]interfaces.DataProducer{}
+	for field, producer := range map[string]struct {
+		producerType exporter.ProducerType
+		name         string
+	}{
+		"dns":             {exporter.CollectorProducer, "dns"},
+		"networkconfig":   {exporter.DiagnoserProducer, "networkconfig"},
+		"networkoutbound": {exporter.DiagnoserProducer, "networkoutbound"},
+	} {
+		if dataProducer, ok := p.getSucceeded(producer.producerType, producer.name); ok {
+			diagnosticFields[field] = p.redact(dataProducer)
+		}
+	}
+
+	if len(diagnosticFields) > 0 {
+		if err := writeDiagnosticResource(config, runtimeInfo, diagnosticFields); err != nil {
+			slog.Error("Could not write Diagnostic resource", logging.Error(err))
+		}
 	}
 
-	collectorGrp.Wait()
+	// Failure to export the archive is logged and recorded in the result.
+	_ = p.exportArchive()
 
-	diagnosers := []interfaces.Diagnoser{
-		diagnoser.NewNetworkConfigDiagnoser(runtimeInfo, dnsCollector, kubeletCmdCollector),
-		diagnoser.NewNetworkOutboundDiagnoser(runtimeInfo, networkOutboundCollector),
+	// The run fails if either the node or the cluster-level data could not be collected and exported.
+	<-clusterDone
+	result = p.result()
+	if result == metrics.RunSucceeded {
+		result = clusterResult
 	}
+	failedProducers = append(p.getFailedProducers(), clusterFailedProducers...)
 
-	diagnoserGrp := new(sync.WaitGroup)
+	return nil
+}
 
-	for _, d := range diagnosers {
-		dataProducers = append(dataProducers, d)
-		diagnoserGrp.Add(1)
-		go func(d interfaces.Diagnoser) {
-			defer diagnoserGrp.Done()
+// electClusterCollectors determines where the selected cluster-scoped collectors are run. A single pod is elected to
+// run them for each run ID, exporting their data under the cluster name rather than a node name. In the selections
+// for this node, they are skipped. If this pod is elected, it returns them in the cluster selections, along with the
+// function to release the election once they have completed. If the election fails, they are run as part of this
+// node's data instead.
+func electClusterCollectors(config *restclient.Config, runtimeInfo *utils.RuntimeInfo, selections []*collector.Selection) (nodeSelections, clusterSelections []*collector.Selection, release func()) {
+	hasClusterCollectors := false
+	for _, selection := range selections {
+		if selection.Registration.GetScope() == collector.ClusterScope && selection.SkipReason == nil {
+			hasClusterCollectors = true
+		}
+	}
+	if !hasClusterCollectors {
+		return selections, nil, nil
+	}
 
-			log.Printf("Diagnoser: %s, diagnose data", d.GetName())
-			err := d.Diagnose()
-			if err != nil {
-				log.Printf("Diagnoser: %s, diagnose data failed: %v", d.GetName(), err)
-				return
-			}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		slog.Warn("Could not create clientset to elect a node to collect cluster-level data, collecting it on this node", logging.Error(err))
+		return selections, nil, nil
+	}
 
-			log.Printf("Diagnoser: %s, export data", d.GetName())
-			if err = exp.Export(d); err != nil {
-				log.Printf("Diagnoser: %s, export data failed: %v", d.GetName(), err)
-			}
-		}(d)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	elector := utils.NewRunLeaseElector(clientset, runtimeInfo.Namespace, runtimeInfo.HostNodeName)
+	holder, release, err := elector.Elect(ctx, runtimeInfo.RunId)
+	if err != nil {
+		slog.Warn("Could not elect a node to collect cluster-level data, collecting it on this node", logging.Error(err))
+		return selections, nil, nil
+	}
+
+	skipReason := fmt.Errorf("cluster-scoped, collected by %s", holder)
+	if release != nil {
+		slog.Info("Elected to collect cluster-level data")
+		skipReason = fmt.Errorf("cluster-scoped, collected in the %s data", clusterNodeName)
+	}
+
+	for _, selection := range selections {
+		if selection.Registration.GetScope() != collector.ClusterScope || selection.SkipReason != nil {
+			nodeSelections = append(nodeSelections, selection)
+			continue
+		}
+
+		if release != nil {
+			clusterSelections = append(clusterSelections, selection)
+		}
+		nodeSelections = append(nodeSelections, &collector.Selection{
+			Registration: selection.Registration,
+			Collector:    selection.Collector,
+			SkipReason:   skipReason,
+		})
 	}
 
-	diagnoserGrp.Wait()
+	return nodeSelections, clusterSelections, release
+}
+
+// exportRunError exports a manifest recording the error that stopped the run before it ran any producers, in place of
+// the data and archive, so that the failure can be seen where the data would have been.
+func exportRunError(ctx context.Context, runtimeInfo *utils.RuntimeInfo, exp interfaces.Exporter, runErr error) {
+	exportCtx, stopExport := newExportContext(ctx)
+	defer stopExport()
+
+	manifest := exporter.NewManifest(runtimeInfo.RunId, runtimeInfo.HostNodeName)
+	manifest.SetError(runErr)
 
-	zip, err := exporter.Zip(dataProducers)
+	content, err := manifest.Marshal()
+	if err == nil {
+		err = exp.ExportReader(exportCtx, exporter.ManifestFileName, bytes.NewReader(content))
+	}
 	if err != nil {
-		log.Printf("Could not zip data: %v", err)
-	} else {
-		if err := exp.ExportReader(runtimeInfo.HostNodeName+".zip", bytes.NewReader(zip.Bytes())); err != nil {
-			log.Printf("Could not export zip archive: %v", err)
+		slog.Error("Could not export manifest", logging.Error(err))
+	}
+}
+
+// runClusterPipeline runs the cluster-scoped collectors, exporting their data and archive under the cluster name in
+// place of the node name, and returns the pipeline once it has completed (if it was created).
+func runClusterPipeline(ctx context.Context, runtimeInfo *utils.RuntimeInfo, knownFilePaths *utils.KnownFilePaths, index *exporter.ContentIndex, selections []*collector.Selection, redactionRules []*redaction.Rule, archiveFormat exporter.ArchiveFormat, reporters *runReporters) (*pipeline, error) {
+	clusterRuntimeInfo := *runtimeInfo
+	clusterRuntimeInfo.HostNodeName = clusterNodeName
+
+	exp, err := createRunExporter(&clusterRuntimeInfo, knownFilePaths, index)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPipeline(&clusterRuntimeInfo, exp, redactionRules, archiveFormat, reporters)
+	p.run(ctx, selections, nil)
+	err = p.exportArchive()
+	return p, err
+}
+
+func writeDiagnosticResource(config *restclient.Config, runtimeInfo *utils.RuntimeInfo, fields map[string]interfaces.DataProducer) error {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+
+	writer := exporter.NewDiagnosticResourceWriter(client, runtimeInfo.Namespace, runtimeInfo.HostNodeName)
+	return utils.RunWithTimeout(time.Minute, func(ctx context.Context) error {
+		return writer.Write(ctx, fields)
+	})
+}
+
+// createRunExporter creates the exporter for the run's data, which encrypts it for the configured recipients (if any),
+// and deduplicates it using the specified index (if any).
+func createRunExporter(runtimeInfo *utils.RuntimeInfo, knownFilePaths *utils.KnownFilePaths, index *exporter.ContentIndex) (interfaces.Exporter, error) {
+	exp, err := createExporter(runtimeInfo, knownFilePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// When recipients are configured, all exported data is encrypted so that only the holders of their private keys
+	// can read it.
+	if runtimeInfo.EncryptionRecipients != "" {
+		recipients, err := encryption.ParseRecipients([]byte(runtimeInfo.EncryptionRecipients))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %w", utils.EncryptionKey, err)
 		}
+		exp = exporter.NewEncryptingExporter(exp, recipients)
 	}
 
-	return nil
+	if index != nil {
+		exp = exporter.NewDeduplicatingExporter(exp, index, runtimeInfo.HostNodeName, runtimeInfo.RunId, runtimeInfo.DeduplicationMaxAge)
+	}
+
+	return exp, nil
+}
+
+func createExporter(runtimeInfo *utils.RuntimeInfo, knownFilePaths *utils.KnownFilePaths) (interfaces.Exporter, error) {
+	switch runtimeInfo.Exporter {
+	case "", "azureblob":
+		return exporter.NewAzureBlobExporter(runtimeInfo, knownFilePaths, runtimeInfo.RunId), nil
+	case "localdirectory":
+		return exporter.NewLocalDirectoryExporter(runtimeInfo, runtimeInfo.ExportDirectory, runtimeInfo.RunId), nil
+	case "s3":
+		return exporter.NewS3Exporter(runtimeInfo, runtimeInfo.RunId), nil
+	default:
+		return nil, fmt.Errorf("unknown exporter: %s", runtimeInfo.Exporter)
+	}
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/collector"
+	"github.com/Azure/aks-periscope/pkg/encryption"
+	"github.com/Azure/aks-periscope/pkg/exporter"
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/metrics"
+	"github.com/Azure/aks-periscope/pkg/redaction"
+	"github.com/Azure/aks-periscope/pkg/status"
+	"github.com/Azure/aks-periscope/pkg/utils"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterNodeName is used in place of a node name for cluster-level data, whether collected by the pod elected to run
+// the cluster-scoped collectors, or from outside the cluster.
+const clusterNodeName = "cluster"
+
+// getClusterCollectorNames gets the names of the cluster-scoped collectors, which only need access to the Kubernetes
+// API (rather than to the node itself), and so can be run from outside the cluster.
+func getClusterCollectorNames() []string {
+	names := []string{}
+	for _, registration := range collector.GetRegistrations() {
+		if registration.GetScope() == collector.ClusterScope {
+			names = append(names, registration.Name)
+		}
+	}
+	return names
+}
+
+// runCollect implements the `collect` command, which runs the API collectors against the cluster from the current
+// (or specified) kubeconfig context, and writes their data and the archive to a local directory.
+func runCollect(args []string) error {
+	flags := flag.NewFlagSet("collect", flag.ExitOnError)
+	kubeconfig := flags.String("kubeconfig", "", "path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	kubeContext := flags.String("context", "", "kubeconfig context to use (defaults to the current context)")
+	clusterCollectorNames := getClusterCollectorNames()
+	collectorNames := flags.String("collectors", "kubeobjects,poddisruptionbudget,systemperf", "comma-separated list of collectors to run: "+strings.Join(clusterCollectorNames, ","))
+	output := flags.String("output", ".", "directory to write collected data to")
+	runId := flags.String("run-id", "", "identifier for the run (defaults to the current UTC time)")
+	kubeObjects := flags.String("kubeobjects", "kube-system/pod kube-system/service kube-system/deployment", "space-separated list of namespace/resource-type[/resource] for the kubeobjects collector")
+	containerLogsNamespaces := flags.String("containerlogs-namespaces", "kube-system", "space-separated list of namespaces for the podscontainerlogs collector")
+	timeout := flags.Duration("timeout", utils.DefaultCollectorTimeout, "deadline for each collector")
+	recipientsFile := flags.String("recipients", "", "path to a file of PEM-encoded X.509 certificates to encrypt the output for")
+	archiveFormat := flags.String("archive-format", string(exporter.DefaultArchiveFormat), "format of the archive of all collected data: zip, tar.gz or tar.zst")
+	dryRun := flags.Bool("dry-run", false, "write the plan of what would be collected to the output directory, without collecting any data")
+	redactionRulesFile := flags.String("redaction-rules", "", "path to a file of redaction rules, in the same format as the "+string(utils.RedactionRulesKey)+" config value")
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: *kubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("cannot load kubeconfig: %w", err)
+	}
+
+	redactionRuleEntries := []string{}
+	if *redactionRulesFile != "" {
+		content, err := os.ReadFile(*redactionRulesFile)
+		if err != nil {
+			return fmt.Errorf("cannot read redaction rules: %w", err)
+		}
+		redactionRuleEntries = strings.Split(string(content), "\n")
+	}
+
+	redactionRules, err := redaction.GetRules(redactionRuleEntries)
+	if err != nil {
+		return fmt.Errorf("invalid redaction rules: %w", err)
+	}
+
+	format, err := exporter.ParseArchiveFormat(*archiveFormat)
+	if err != nil {
+		return err
+	}
+
+	if *runId == "" {
+		*runId = utils.GenerateRunId(time.Now())
+	}
+
+	runtimeInfo := &utils.RuntimeInfo{
+		RunId:                   *runId,
+		HostNodeName:            clusterNodeName,
+		KubernetesObjects:       strings.Fields(*kubeObjects),
+		ContainerLogsNamespaces: strings.Fields(*containerLogsNamespaces),
+		CollectorTimeout:        *timeout,
+		DryRun:                  *dryRun,
+		Features:                map[utils.Feature]bool{},
+	}
+
+	// The selected collectors are explicitly included, so that they run regardless of the COLLECTOR_LIST profiles.
+	selected := map[*collector.Registration]bool{}
+	for _, name := range strings.Split(*collectorNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		registration := collector.GetRegistration(name)
+		if registration == nil || registration.GetScope() != collector.ClusterScope {
+			return fmt.Errorf("collector %s cannot be run outside the cluster (available: %s)", name, strings.Join(clusterCollectorNames, ","))
+		}
+
+		selected[registration] = true
+		runtimeInfo.CollectorList = append(runtimeInfo.CollectorList, "+"+registration.Name)
+	}
+
+	if len(selected) == 0 {
+		return fmt.Errorf("no collectors selected")
+	}
+
+	selections := []*collector.Selection{}
+	for _, selection := range collector.Select(&collector.Dependencies{Config: config, RuntimeInfo: runtimeInfo}) {
+		if selected[selection.Registration] {
+			selections = append(selections, selection)
+		}
+	}
+
+	var exp interfaces.Exporter = exporter.NewLocalDirectoryExporter(runtimeInfo, *output, runtimeInfo.RunId)
+	if *recipientsFile != "" {
+		content, err := os.ReadFile(*recipientsFile)
+		if err != nil {
+			return fmt.Errorf("cannot read recipients: %w", err)
+		}
+		recipients, err := encryption.ParseRecipients(content)
+		if err != nil {
+			return fmt.Errorf("invalid recipients: %w", err)
+		}
+		exp = exporter.NewEncryptingExporter(exp, recipients)
+	}
+
+	runLog := logging.StartRunLog(runtimeInfo.RunId, runtimeInfo.HostNodeName)
+	defer runLog.Stop()
+	start := time.Now()
+	slog.Info("Starting Periscope run", "host", config.Host)
+
+	// Interrupting the run (e.g. with Ctrl+C) cancels the collectors, and writes the data collected so far.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if runtimeInfo.DryRun {
+		return exportPlan(ctx, runtimeInfo, exp, selections)
+	}
+
+	// Metrics and status are not served when collecting from outside the cluster.
+	reporters := &runReporters{metrics: metrics.NewMetrics(), status: status.NewTracker(), runLog: runLog}
+	p := newPipeline(runtimeInfo, exp, redactionRules, format, reporters)
+	p.run(ctx, selections, nil)
+	if err := p.exportArchive(); err != nil {
+		return fmt.Errorf("could not export archive: %w", err)
+	}
+
+	if ctx.Err() != nil {
+		slog.Warn("Cancelled Periscope run, partial data written", logging.Duration(time.Since(start)))
+		return nil
+	}
+	slog.Info("Completed Periscope run", logging.Duration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/rsa"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Azure/aks-periscope/pkg/encryption"
+)
+
+// runDecrypt implements the `decrypt` command, which decrypts files exported by Periscope when encryption recipients
+// are configured, using the private key of one of the recipients.
+func runDecrypt(args []string) error {
+	flags := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	keyFile := flags.String("key", "", "path to the PEM-encoded RSA private key of a recipient")
+	output := flags.String("output", "", "path to write the decrypted data to (only valid for a single file; defaults to the file path without the "+encryption.FileExtension+" extension)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: aks-periscope decrypt -key <private-key-file> [-output <path>] <file>...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	files := flags.Args()
+	if *keyFile == "" || len(files) == 0 {
+		flags.Usage()
+		return fmt.Errorf("a private key and at least one file are required")
+	}
+	if *output != "" && len(files) > 1 {
+		return fmt.Errorf("-output can only be used with a single file")
+	}
+
+	keyContent, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot read private key: %w", err)
+	}
+
+	privateKey, err := encryption.ParsePrivateKey(keyContent)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+
+	for _, file := range files {
+		outputPath := *output
+		if outputPath == "" {
+			var ok bool
+			outputPath, ok = strings.CutSuffix(file, encryption.FileExtension)
+			if !ok {
+				return fmt.Errorf("cannot determine output path for %s: file does not have the %s extension", file, encryption.FileExtension)
+			}
+		}
+
+		if err := decryptFile(file, outputPath, privateKey); err != nil {
+			return fmt.Errorf("cannot decrypt %s: %w", file, err)
+		}
+
+		slog.Info("Decrypted file", "path", file, "outputPath", outputPath)
+	}
+
+	return nil
+}
+
+func decryptFile(inputPath, outputPath string, privateKey *rsa.PrivateKey) error {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	reader, err := encryption.NewDecryptingReader(input, privateKey)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that a partially decrypted (and therefore unverified) file isn't left behind.
+	tempPath := outputPath + ".partial"
+	outputFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(outputFile, reader)
+	if closeErr := outputFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return os.Rename(tempPath, outputPath)
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/budget"
+	"github.com/Azure/aks-periscope/pkg/collector"
+	"github.com/Azure/aks-periscope/pkg/diagnoser"
+	"github.com/Azure/aks-periscope/pkg/events"
+	"github.com/Azure/aks-periscope/pkg/exporter"
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/metrics"
+	"github.com/Azure/aks-periscope/pkg/redaction"
+	"github.com/Azure/aks-periscope/pkg/status"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// stopWait is how long a cancelled collector is given to stop, so that the data it has collected so far can still
+// be exported.
+const stopWait = 5 * time.Second
+
+// shutdownExportTimeout is how long exports can continue once the run has been cancelled because the pod is being
+// terminated. It is a few seconds less than the DaemonSet's terminationGracePeriodSeconds, so that an export still in
+// progress is abandoned, and recorded as failed, before the process is killed.
+const shutdownExportTimeout = 55 * time.Second
+
+// errShutdownDeadline is the cause of exports being abandoned once shutdownExportTimeout has passed.
+var errShutdownDeadline = errors.New("shutdown deadline for exporting data exceeded")
+
+// newExportContext creates a context for exporting the data of a run, which is not cancelled along with the run's
+// context, so that the data collected so far can still be exported. Instead, it is cancelled shutdownExportTimeout
+// after the run's context is. The returned function releases its resources.
+func newExportContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	exportCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.AfterFunc(shutdownExportTimeout, func() { cancel(errShutdownDeadline) })
+		context.AfterFunc(exportCtx, func() { timer.Stop() })
+	})
+
+	return exportCtx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// runReporters report the progress and outcome of a run, in addition to its manifest.
+type runReporters struct {
+	// metrics records the outcome of each step once the archive has been exported.
+	metrics *metrics.Metrics
+	// status records the state of the run and the outcome of each producer as they progress.
+	status *status.Tracker
+	// runLog is the log of the run, which is included in the archive (if set).
+	runLog *logging.RunLog
+	// events records Kubernetes Events for skipped and failed producers (if set).
+	events *events.Recorder
+}
+
+// pipeline runs collectors and diagnosers, exporting the data of each as it completes, and keeps track of
+// the outcome of each step so that the archive and its manifest can be exported at the end of the run.
+type pipeline struct {
+	runtimeInfo   *utils.RuntimeInfo
+	exporter      interfaces.Exporter
+	manifest      *exporter.Manifest
+	lock          sync.Mutex
+	records       map[interfaces.DataProducer]*exporter.ProducerRecord
+	dataProducers []interfaces.DataProducer
+	rules         []*redaction.Rule
+	redactor      *redaction.Redactor
+	budget        *budget.Budget
+	archiveFormat exporter.ArchiveFormat
+	reporters     *runReporters
+	logger        *slog.Logger
+	exportCtx     context.Context
+	stopExport    context.CancelFunc
+	partial       bool
+	archiveFailed bool
+}
+
+// newPipeline creates a pipeline which redacts all exported data using the specified rules, and truncates it to fit
+// the configured size budgets. The archive of all the data is written in the specified format, including the run log
+// (if any). The progress and outcome of the run are reported to the specified reporters.
+func newPipeline(runtimeInfo *utils.RuntimeInfo, exp interfaces.Exporter, rules []*redaction.Rule, archiveFormat exporter.ArchiveFormat, reporters *runReporters) *pipeline {
+	// The manifest records the outcome of every step for every producer, and is included in the archive.
+	manifest := exporter.NewManifest(runtimeInfo.RunId, runtimeInfo.HostNodeName)
+	logger := slog.With(logging.RunIdKey, runtimeInfo.RunId, logging.NodeKey, runtimeInfo.HostNodeName)
+
+	ruleNames := make([]string, len(rules))
+	for i, rule := range rules {
+		ruleNames[i] = rule.Name
+	}
+	manifest.SetRedactionRules(ruleNames)
+
+	// Truncations are recorded in the manifest when each producer's budget is first allocated.
+	recordTruncation := func(producer interfaces.DataProducer, truncation *budget.Truncation) {
+		logger.Info("Truncating data to fit the size budget", logging.ComponentKey, producer.GetName(),
+			"key", truncation.Key, "length", truncation.Length, "limit", truncation.Limit)
+		manifest.AddTruncation(producer, &exporter.TruncationRecord{
+			Key:          truncation.Key,
+			Length:       truncation.Length,
+			Limit:        truncation.Limit,
+			DroppedBytes: truncation.Length - truncation.Limit,
+		})
+	}
+
+	return &pipeline{
+		runtimeInfo:   runtimeInfo,
+		exporter:      exp,
+		manifest:      manifest,
+		records:       map[interfaces.DataProducer]*exporter.ProducerRecord{},
+		dataProducers: []interfaces.DataProducer{},
+		rules:         rules,
+		redactor:      redaction.NewRedactor(rules, nil),
+		budget:        budget.NewBudget(runtimeInfo.RunSizeBudget, runtimeInfo.GetSizeBudget, recordTruncation),
+		archiveFormat: archiveFormat,
+		reporters:     reporters,
+		logger:        logger,
+	}
+}
+
+// run runs all the selected and supported collectors concurrently. Each diagnoser is run as soon as the collectors
+// it depends on have completed, and is skipped if any of them did not succeed. This waits for everything to complete.
+// If the context is cancelled, running collectors are cancelled and the data they have collected so far is exported
+// (as long as they stop promptly), and no more diagnosers are run. Exports continue until shutdownExportTimeout after
+// the context is cancelled, including that of the archive.
+func (p *pipeline) run(ctx context.Context, selections []*collector.Selection, diagnoserRegistrations []*diagnoser.Registration) {
+	p.exportCtx, p.stopExport = newExportContext(ctx)
+
+	grp := new(sync.WaitGroup)
+	p.setState(status.Collecting)
+
+	// Each collector's channel is closed once it has finished collecting (or has been skipped).
+	collectors := map[string]interfaces.Collector{}
+	collectorDone := map[string]chan struct{}{}
+	for _, selection := range selections {
+		collectors[selection.Registration.Name] = selection.Collector
+		collectorDone[selection.Registration.Name] = make(chan struct{})
+	}
+
+	supportedCollectors := []interfaces.Collector{}
+	for _, selection := range selections {
+		c := selection.Collector
+		done := collectorDone[selection.Registration.Name]
+		record := p.addRecord(exporter.CollectorProducer, c)
+
+		logger := p.logger.With(logging.ComponentKey, c.GetName())
+
+		var err error
+		record.CheckSupported, err = exporter.RecordStep(selection.CheckSupported)
+		if err != nil {
+			// Log the reason why this collector is not supported, and skip to the next
+			logger.Info("Skipping unsupported collector", "reason", err)
+			p.setOutcome(exporter.CollectorProducer, c.GetName(), status.Skipped, err)
+			close(done)
+			continue
+		}
+
+		supportedCollectors = append(supportedCollectors, c)
+		grp.Add(1)
+		go func(c interfaces.Collector, record *exporter.ProducerRecord, done chan struct{}) {
+			defer grp.Done()
+
+			var err error
+			var stopped bool
+
+			timeout := p.runtimeInfo.GetCollectorTimeout(c.GetName())
+			logger.Info("Collecting data", "timeout", timeout.String())
+			p.setOutcome(exporter.CollectorProducer, c.GetName(), status.Running, nil)
+			record.Collect, err = exporter.RecordStep(func() (err error) {
+				stopped, err = utils.RunWithCancellation(ctx, timeout, stopWait, c.Collect)
+				return err
+			})
+			close(done)
+
+			// A cancelled collector that stopped has its data so far exported, marked as partial.
+			record.Partial = record.Collect.Cancelled && stopped
+			if err != nil && !record.Partial {
+				logger.Error("Collecting data failed", logging.Duration(record.Collect.GetDuration()), logging.Error(err))
+				p.setFailed(exporter.CollectorProducer, c.GetName(), "collect", err)
+				return
+			}
+			if record.Partial {
+				logger.Warn("Collecting data cancelled", logging.Duration(record.Collect.GetDuration()), logging.Error(err))
+			} else {
+				logger.Info("Collected data", logging.Duration(record.Collect.GetDuration()))
+			}
+
+			record.Export, err = exporter.RecordStep(func() error { return p.export(c, record) })
+			if err != nil {
+				logger.Error("Exporting data failed", logging.Duration(record.Export.GetDuration()), logging.Error(err))
+				p.setFailed(exporter.CollectorProducer, c.GetName(), "export", err)
+			} else {
+				logger.Info("Exported data", logging.Duration(record.Export.GetDuration()))
+				outcome := status.Succeeded
+				if record.Partial {
+					outcome = status.Partial
+				}
+				p.setOutcome(exporter.CollectorProducer, c.GetName(), outcome, nil)
+			}
+		}(c, record, done)
+	}
+
+	// Once all the collectors have completed, only diagnosers can still be running.
+	grp.Add(1)
+	go func() {
+		defer grp.Done()
+		for _, done := range collectorDone {
+			<-done
+		}
+		if len(diagnoserRegistrations) > 0 {
+			p.setState(status.Diagnosing)
+		}
+	}()
+
+	diagnosers := make([]interfaces.Diagnoser, len(diagnoserRegistrations))
+	for i, registration := range diagnoserRegistrations {
+		grp.Add(1)
+		go func(i int, registration *diagnoser.Registration) {
+			defer grp.Done()
+			diagnosers[i] = p.runDiagnoser(ctx, registration, collectors, collectorDone)
+		}(i, registration)
+	}
+
+	grp.Wait()
+
+	if ctx.Err() != nil {
+		p.partial = true
+		p.manifest.SetPartial()
+	}
+
+	for _, c := range supportedCollectors {
+		// A collector that timed out (or didn't stop when cancelled) may still be running, so its data is not safe to read.
+		if record := p.records[c]; record.Collect.TimedOut || (record.Collect.Cancelled && !record.Partial) {
+			continue
+		}
+		p.dataProducers = append(p.dataProducers, c)
+	}
+
+	for _, d := range diagnosers {
+		if d == nil || p.records[d].Diagnose.TimedOut || p.records[d].Diagnose.Cancelled {
+			continue
+		}
+		p.dataProducers = append(p.dataProducers, d)
+	}
+
+	// The reasons producers were skipped are in the manifest, so they are listed in a single Event.
+	skipped := p.manifest.GetProducerNames(func(record *exporter.ProducerRecord) bool {
+		return record.CheckSupported != nil && !record.CheckSupported.Succeeded
+	})
+	if len(skipped) > 0 {
+		p.reporters.events.ProducersSkipped(p.runtimeInfo.RunId, skipped)
+	}
+}
+
+// runDiagnoser waits for the collectors the diagnoser depends on, and then creates and runs it if they succeeded.
+// The diagnoser is returned, or nil if it was skipped.
+func (p *pipeline) runDiagnoser(ctx context.Context, registration *diagnoser.Registration, collectors map[string]interfaces.Collector, collectorDone map[string]chan struct{}) interfaces.Diagnoser {
+	succeededCollectors := map[string]interfaces.Collector{}
+	for _, name := range registration.Dependencies {
+		done, ok := collectorDone[name]
+		if !ok {
+			continue
+		}
+
+		<-done
+		if p.succeeded(collectors[name]) {
+			succeededCollectors[name] = collectors[name]
+		}
+	}
+
+	logger := p.logger.With(logging.ComponentKey, registration.Name)
+
+	if ctx.Err() != nil {
+		err := fmt.Errorf("run cancelled: %w", ctx.Err())
+		logger.Info("Skipping diagnoser", "reason", err)
+		p.manifest.AddSkippedProducer(exporter.DiagnoserProducer, registration.Name, err)
+		p.setOutcome(exporter.DiagnoserProducer, registration.Name, status.Skipped, err)
+		return nil
+	}
+
+	d, err := registration.New(p.runtimeInfo, succeededCollectors)
+	if err != nil {
+		logger.Info("Skipping diagnoser", "reason", err)
+		p.manifest.AddSkippedProducer(exporter.DiagnoserProducer, registration.Name, err)
+		p.setOutcome(exporter.DiagnoserProducer, registration.Name, status.Skipped, err)
+		return nil
+	}
+
+	record := p.addRecord(exporter.DiagnoserProducer, d)
+
+	// Diagnosers share the configured timeouts with collectors, and can be given their own in the same way.
+	timeout := p.runtimeInfo.GetCollectorTimeout(d.GetName())
+	logger.Info("Diagnosing data", "timeout", timeout.String())
+	p.setOutcome(exporter.DiagnoserProducer, d.GetName(), status.Running, nil)
+	record.Diagnose, err = exporter.RecordStep(func() error {
+		_, err := utils.RunWithCancellation(ctx, timeout, stopWait, d.Diagnose)
+		return err
+	})
+	if err != nil {
+		logger.Error("Diagnosing data failed", logging.Duration(record.Diagnose.GetDuration()), logging.Error(err))
+		p.setFailed(exporter.DiagnoserProducer, d.GetName(), "diagnose", err)
+		return d
+	}
+	logger.Info("Diagnosed data", logging.Duration(record.Diagnose.GetDuration()))
+
+	record.Export, err = exporter.RecordStep(func() error { return p.export(d, record) })
+	if err != nil {
+		logger.Error("Exporting data failed", logging.Duration(record.Export.GetDuration()), logging.Error(err))
+		p.setFailed(exporter.DiagnoserProducer, d.GetName(), "export", err)
+	} else {
+		logger.Info("Exported data", logging.Duration(record.Export.GetDuration()))
+		p.setOutcome(exporter.DiagnoserProducer, d.GetName(), status.Succeeded, nil)
+	}
+
+	return d
+}
+
+// export exports the producer's truncated and redacted data, including the outcome of uploading each key in its record if the
+// exporter supports that.
+func (p *pipeline) export(producer interfaces.DataProducer, record *exporter.ProducerRecord) error {
+	producer = p.redact(p.budget.Apply(producer))
+	recordingExporter, ok := p.exporter.(exporter.UploadRecordingExporter)
+	if !ok {
+		return p.exportErr(p.exporter.Export(p.exportCtx, producer))
+	}
+
+	uploads, err := recordingExporter.ExportWithRecords(p.exportCtx, producer)
+	record.Uploads = uploads
+	return p.exportErr(err)
+}
+
+// exportErr adds the reason exports were abandoned (i.e. the shutdown deadline) to an export error, if they were.
+func (p *pipeline) exportErr(err error) error {
+	if err == nil || p.exportCtx.Err() == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", context.Cause(p.exportCtx), err)
+}
+
+// setState reports the state of the run in this pipeline.
+func (p *pipeline) setState(state status.State) {
+	p.reporters.status.SetState(p.runtimeInfo.HostNodeName, state)
+}
+
+// setFailed reports that a step of a producer in this pipeline failed.
+func (p *pipeline) setFailed(producerType exporter.ProducerType, name string, step string, err error) {
+	p.setOutcome(producerType, name, status.Failed, err)
+	p.reporters.events.ProducerFailed(p.runtimeInfo.RunId, string(producerType), name, step, err)
+}
+
+// setOutcome reports the outcome so far of a producer in this pipeline.
+func (p *pipeline) setOutcome(producerType exporter.ProducerType, name string, outcome status.Outcome, err error) {
+	p.reporters.status.SetProducer(p.runtimeInfo.HostNodeName, string(producerType), name, outcome, err)
+}
+
+// redact wraps the producer so that its data is redacted as it is read.
+func (p *pipeline) redact(producer interfaces.DataProducer) interfaces.DataProducer {
+	return p.redactor.RedactProducer(producer)
+}
+
+func (p *pipeline) addRecord(producerType exporter.ProducerType, producer interfaces.DataProducer) *exporter.ProducerRecord {
+	record := p.manifest.AddProducer(producerType, producer)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.records[producer] = record
+	return record
+}
+
+// succeeded returns true if the specified producer was run as part of this pipeline, and succeeded.
+func (p *pipeline) succeeded(producer interfaces.DataProducer) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	record, ok := p.records[producer]
+	return ok && record.Succeeded()
+}
+
+// getSucceeded returns the producer of the specified type and name, if it was run and succeeded.
+func (p *pipeline) getSucceeded(producerType exporter.ProducerType, name string) (interfaces.DataProducer, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for producer, record := range p.records {
+		if record.Type == producerType && record.Name == name && record.Succeeded() {
+			return producer, true
+		}
+	}
+
+	return nil, false
+}
+
+// exportArchive exports an archive of the truncated and redacted data from all the producers that have been run,
+// along with the manifest, and then records the outcome of every step in the metrics (including the size of each
+// producer's data in the archive). The archive is not exported if the shutdown deadline is reached first.
+func (p *pipeline) exportArchive() error {
+	p.setState(status.Exporting)
+	defer p.setState(status.Idle)
+	defer p.stopExport()
+
+	record, err := exporter.RecordStep(func() error { return p.exportErr(p.writeArchive()) })
+	p.archiveFailed = err != nil
+
+	logger := p.logger.With(logging.ComponentKey, "archive", logging.Duration(record.GetDuration()))
+	if err != nil {
+		logger.Error("Exporting archive failed", logging.Error(err))
+	} else {
+		logger.Info("Exported archive")
+	}
+
+	p.reporters.metrics.ObserveStep(metrics.ArchiveProducer, string(p.archiveFormat), "export", record)
+	for _, record := range p.records {
+		p.reporters.metrics.ObserveProducer(record)
+	}
+
+	return err
+}
+
+// result gets the result of the run, once the archive has been exported. The run failed if any step of a producer
+// that was run failed, or if the archive could not be exported.
+func (p *pipeline) result() metrics.RunResult {
+	if p.partial {
+		return metrics.RunCancelled
+	}
+	if p.archiveFailed {
+		return metrics.RunFailed
+	}
+
+	if len(p.getFailedProducers()) > 0 {
+		return metrics.RunFailed
+	}
+
+	return metrics.RunSucceeded
+}
+
+// getFailedProducers gets the names of the producers with a step that failed.
+func (p *pipeline) getFailedProducers() []string {
+	return p.manifest.GetProducerNames(func(record *exporter.ProducerRecord) bool {
+		for _, step := range []*exporter.StepRecord{record.Collect, record.Diagnose, record.Export} {
+			if step != nil && !step.Succeeded {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// writeArchive writes the archive to the exporter. The manifest records how many values each redaction rule
+// redacted within the archive. The run log is written last, so that it includes as much of the run as possible.
+// If the run was cancelled, the manifest is first exported on its own, since there may not be time to export the
+// whole archive before the process is terminated.
+func (p *pipeline) writeArchive() error {
+	if p.partial {
+		content, err := p.manifest.Marshal()
+		if err != nil {
+			return fmt.Errorf("error serializing manifest: %w", err)
+		}
+		if err := p.exporter.ExportReader(p.exportCtx, exporter.ManifestFileName, bytes.NewReader(content)); err != nil {
+			p.logger.Error("Could not export manifest", logging.Error(err))
+		}
+	}
+
+	archiveRedactor := redaction.NewRedactor(p.rules, p.manifest.AddRedactions)
+	producers := p.dataProducers
+	if runLog := p.reporters.runLog; runLog != nil {
+		p.manifest.AddProducer(exporter.LogProducer, runLog)
+		producers = append(producers[:len(producers):len(producers)], runLog)
+	}
+
+	// When deduplicating, the values that were exported as references to a previous run are also references in the
+	// archive, rather than copies of content that has already been exported.
+	deduplicatingExporter, isDeduplicating := p.exporter.(*exporter.DeduplicatingExporter)
+
+	dataProducers := make([]interfaces.DataProducer, len(producers))
+	for i, producer := range producers {
+		if isDeduplicating {
+			producer = deduplicatingExporter.ReplaceUnchanged(producer)
+		}
+		dataProducers[i] = archiveRedactor.RedactProducer(p.budget.Apply(producer))
+	}
+
+	// Stream the archive to the exporter as it is written, rather than building it in memory first,
+	// so that memory use is bounded regardless of how much data has been collected.
+	archiveReader, archiveWriter := io.Pipe()
+	go func() {
+		archiveWriter.CloseWithError(exporter.WriteArchive(archiveWriter, p.archiveFormat, dataProducers, p.manifest))
+	}()
+
+	// Unblock the archive writer if the exporter stops reading before the end of the archive.
+	defer archiveReader.Close()
+
+	return p.exporter.ExportReader(p.exportCtx, p.runtimeInfo.HostNodeName+"."+string(p.archiveFormat), archiveReader)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Azure/aks-periscope/pkg/collector"
+	"github.com/Azure/aks-periscope/pkg/exporter"
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/metrics"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// runDryRun exports the plan of what would be collected for this node and, if this pod was elected to collect it
+// (i.e. release is set), the cluster-level data. Nothing else is read or exported, and the result is that of
+// exporting the plans.
+func runDryRun(ctx context.Context, runtimeInfo *utils.RuntimeInfo, knownFilePaths *utils.KnownFilePaths, index *exporter.ContentIndex, exp interfaces.Exporter, selections, clusterSelections []*collector.Selection, release func()) metrics.RunResult {
+	result := metrics.RunSucceeded
+	if err := exportPlan(ctx, runtimeInfo, exp, selections); err != nil {
+		slog.Error("Could not export plan", logging.Error(err))
+		result = metrics.RunFailed
+	}
+
+	if release == nil {
+		return result
+	}
+	defer release()
+
+	clusterRuntimeInfo := *runtimeInfo
+	clusterRuntimeInfo.HostNodeName = clusterNodeName
+
+	clusterExp, err := createRunExporter(&clusterRuntimeInfo, knownFilePaths, index)
+	if err == nil {
+		err = exportPlan(ctx, &clusterRuntimeInfo, clusterExp, clusterSelections)
+	}
+	if err != nil {
+		slog.Error("Could not export cluster-level plan", logging.Error(err))
+		result = metrics.RunFailed
+	}
+
+	return result
+}
+
+// exportPlan evaluates the selected collectors and resolves their targets without reading any content, and exports
+// the resulting plan.
+func exportPlan(ctx context.Context, runtimeInfo *utils.RuntimeInfo, exp interfaces.Exporter, selections []*collector.Selection) error {
+	plan := collector.NewPlan(ctx, runtimeInfo, selections)
+	content, err := plan.Marshal()
+	if err != nil {
+		return fmt.Errorf("error serializing plan: %w", err)
+	}
+
+	exportCtx, stopExport := newExportContext(ctx)
+	defer stopExport()
+	if err := exp.ExportReader(exportCtx, collector.PlanFileName, bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("error exporting plan: %w", err)
+	}
+
+	slog.Info("Exported dry run plan", logging.NodeKey, runtimeInfo.HostNodeName, "estimatedSize", plan.EstimatedSize)
+	return nil
+}
@@ -0,0 +1,168 @@
+package budget
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// Unlimited is the limit used when data is not limited.
+const Unlimited int64 = -1
+
+// Truncation describes a value which was longer than its share of the budget, and so had its start dropped.
+type Truncation struct {
+	Key    string
+	Length int64
+	Limit  int64
+}
+
+// Budget limits the number of bytes exported for each producer, and for the run as a whole. Each producer's budget is
+// shared between its values, so that values within an equal share are kept in full and only the largest values are
+// truncated. The run budget is allocated to producers in the order their data is first exported, so a producer can
+// receive less than its own budget (or nothing) once the run budget has been used up.
+type Budget struct {
+	producerLimit func(name string) int64
+	record        func(producer interfaces.DataProducer, truncation *Truncation)
+	lock          sync.Mutex
+	remaining     int64
+	limits        map[interfaces.DataProducer]map[string]int64
+}
+
+// NewBudget creates a Budget with the specified run limit, and a function returning the limit for each named producer.
+// A limit of zero (or less) means the data is not limited. The record function (if any) is called for every value
+// that is truncated, and must be safe to call concurrently.
+func NewBudget(runLimit int64, producerLimit func(name string) int64, record func(producer interfaces.DataProducer, truncation *Truncation)) *Budget {
+	if runLimit <= 0 {
+		runLimit = Unlimited
+	}
+
+	return &Budget{
+		producerLimit: producerLimit,
+		record:        record,
+		remaining:     runLimit,
+		limits:        map[interfaces.DataProducer]map[string]int64{},
+	}
+}
+
+// Apply wraps the producer so that its values are truncated to fit the budget when read. The budget for a producer is
+// allocated the first time it is applied, based on the lengths of its values at that time, and the same limits are
+// used every time the producer is applied after that, so that the same data is exported everywhere.
+func (b *Budget) Apply(producer interfaces.DataProducer) interfaces.DataProducer {
+	return &budgetedProducer{producer: producer, limits: b.getLimits(producer)}
+}
+
+func (b *Budget) getLimits(producer interfaces.DataProducer) map[string]int64 {
+	b.lock.Lock()
+	limits, ok := b.limits[producer]
+	if ok {
+		b.lock.Unlock()
+		return limits
+	}
+
+	lengths := map[string]int64{}
+	for key, value := range producer.GetData() {
+		lengths[key] = value.GetLength()
+	}
+
+	limit := Unlimited
+	if b.producerLimit != nil {
+		if producerLimit := b.producerLimit(producer.GetName()); producerLimit > 0 {
+			limit = producerLimit
+		}
+	}
+	if b.remaining != Unlimited && (limit == Unlimited || limit > b.remaining) {
+		limit = b.remaining
+	}
+
+	var used int64
+	limits, used = allocate(lengths, limit)
+	if b.remaining != Unlimited {
+		b.remaining -= used
+	}
+	b.limits[producer] = limits
+	b.lock.Unlock()
+
+	if b.record != nil {
+		keys := make([]string, 0, len(limits))
+		for key := range limits {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			b.record(producer, &Truncation{Key: key, Length: lengths[key], Limit: limits[key]})
+		}
+	}
+
+	return limits
+}
+
+// allocate shares the budget between values with the specified lengths, so that the smallest values are kept in
+// full, and the values which don't fit within an equal share of what remains are limited to that share.
+// It returns the limits for only the values that need truncating, along with the total number of bytes allocated.
+func allocate(lengths map[string]int64, budget int64) (map[string]int64, int64) {
+	limits := map[string]int64{}
+
+	keys := make([]string, 0, len(lengths))
+	var total int64
+	for key, length := range lengths {
+		keys = append(keys, key)
+		total += length
+	}
+
+	if budget == Unlimited || total <= budget {
+		return limits, total
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if lengths[keys[i]] != lengths[keys[j]] {
+			return lengths[keys[i]] < lengths[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	remaining := budget
+	for i, key := range keys {
+		share := remaining / int64(len(keys)-i)
+		if lengths[key] <= share {
+			remaining -= lengths[key]
+			continue
+		}
+
+		limits[key] = share
+		remaining -= share
+	}
+
+	return limits, budget - remaining
+}
+
+type budgetedProducer struct {
+	producer interfaces.DataProducer
+	limits   map[string]int64
+}
+
+func (p *budgetedProducer) GetName() string {
+	return p.producer.GetName()
+}
+
+func (p *budgetedProducer) GetData() map[string]interfaces.DataValue {
+	data := p.producer.GetData()
+	if len(p.limits) == 0 {
+		return data
+	}
+
+	result := make(map[string]interfaces.DataValue, len(data))
+	for key, value := range data {
+		if limit, ok := p.limits[key]; ok {
+			value = TruncateValue(value, limit)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// Unwrap returns the producer whose data is truncated, so that its data can be attributed to it.
+func (p *budgetedProducer) Unwrap() interfaces.DataProducer {
+	return p.producer
+}
@@ -0,0 +1,145 @@
+package budget
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+type testDataProducer struct {
+	name string
+	data map[string]string
+}
+
+func (p *testDataProducer) GetName() string {
+	return p.name
+}
+
+func (p *testDataProducer) GetData() map[string]interfaces.DataValue {
+	return utils.ToDataValueMap(p.data)
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name       string
+		lengths    map[string]int64
+		budget     int64
+		wantLimits map[string]int64
+		wantUsed   int64
+	}{
+		{
+			name:       "unlimited",
+			lengths:    map[string]int64{"a": 100, "b": 200},
+			budget:     Unlimited,
+			wantLimits: map[string]int64{},
+			wantUsed:   300,
+		},
+		{
+			name:       "within budget",
+			lengths:    map[string]int64{"a": 100, "b": 200},
+			budget:     300,
+			wantLimits: map[string]int64{},
+			wantUsed:   300,
+		},
+		{
+			name:       "small values kept in full",
+			lengths:    map[string]int64{"a": 10, "b": 200, "c": 300},
+			budget:     210,
+			wantLimits: map[string]int64{"b": 100, "c": 100},
+			wantUsed:   210,
+		},
+		{
+			name:       "equal shares",
+			lengths:    map[string]int64{"a": 100, "b": 100},
+			budget:     101,
+			wantLimits: map[string]int64{"a": 50, "b": 51},
+			wantUsed:   101,
+		},
+		{
+			name:       "no budget",
+			lengths:    map[string]int64{"a": 100},
+			budget:     0,
+			wantLimits: map[string]int64{"a": 0},
+			wantUsed:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		limits, used := allocate(tt.lengths, tt.budget)
+		if !reflect.DeepEqual(limits, tt.wantLimits) {
+			t.Errorf("%s: unexpected limits: expected %v, found %v", tt.name, tt.wantLimits, limits)
+		}
+		if used != tt.wantUsed {
+			t.Errorf("%s: unexpected used: expected %d, found %d", tt.name, tt.wantUsed, used)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	producer1 := &testDataProducer{
+		name: "systemlogs",
+		data: map[string]string{"kubelet": strings.Repeat("kubelet\n", 100), "docker": "docker\n"},
+	}
+	producer2 := &testDataProducer{
+		name: "osm",
+		data: map[string]string{"stats": strings.Repeat("stat\n", 100)},
+	}
+	producer3 := &testDataProducer{
+		name: "dns",
+		data: map[string]string{"conf": "nameserver 10.0.0.10\n"},
+	}
+
+	producerLimits := map[string]int64{"systemlogs": 100}
+
+	lock := sync.Mutex{}
+	truncations := map[string]*Truncation{}
+	budget := NewBudget(300, func(name string) int64 { return producerLimits[name] }, func(producer interfaces.DataProducer, truncation *Truncation) {
+		lock.Lock()
+		defer lock.Unlock()
+		truncations[producer.GetName()+"/"+truncation.Key] = truncation
+	})
+
+	budgeted1 := budget.Apply(producer1)
+	budgeted2 := budget.Apply(producer2)
+	budgeted3 := budget.Apply(producer3)
+
+	// systemlogs is limited by its own budget, osm by what remains of the run budget, and dns has nothing left.
+	expectedTruncations := map[string]*Truncation{
+		"systemlogs/kubelet": {Key: "kubelet", Length: 800, Limit: 93},
+		"osm/stats":          {Key: "stats", Length: 500, Limit: 200},
+		"dns/conf":           {Key: "conf", Length: 21, Limit: 0},
+	}
+	if !reflect.DeepEqual(truncations, expectedTruncations) {
+		t.Errorf("unexpected truncations: %v", truncations)
+	}
+
+	expectedLengths := map[interfaces.DataProducer]map[string]int64{
+		budgeted1: {"kubelet": 93, "docker": 7},
+		budgeted2: {"stats": 200},
+		budgeted3: {"conf": 0},
+	}
+	for budgeted, lengths := range expectedLengths {
+		for key, value := range budgeted.GetData() {
+			if value.GetLength() != lengths[key] {
+				t.Errorf("unexpected length for %s/%s: expected %d, found %d", budgeted.GetName(), key, lengths[key], value.GetLength())
+			}
+		}
+	}
+
+	// Applying the budget again uses the same limits, without recording the truncations again.
+	truncations = map[string]*Truncation{}
+	if value := budget.Apply(producer1).GetData()["kubelet"]; value.GetLength() != 93 {
+		t.Errorf("unexpected length after reapplying budget: %d", value.GetLength())
+	}
+	if len(truncations) != 0 {
+		t.Errorf("unexpected truncations after reapplying budget: %v", truncations)
+	}
+
+	if unwrapped := budgeted1.(*budgetedProducer).Unwrap(); unwrapped != producer1 {
+		t.Errorf("expected Unwrap to return the original producer")
+	}
+}
@@ -0,0 +1,114 @@
+package budget
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// maxLineAlignment is the furthest past the truncation point that the reader looks for the start of the next line,
+// so that the kept data doesn't begin with a partial line.
+const maxLineAlignment = 4096
+
+// truncationMarkerFormat is written in place of the dropped data, with the number of bytes that were dropped.
+const truncationMarkerFormat = "[periscope: truncated %d bytes to fit the size budget]\n"
+
+// TruncateValue limits the value to the specified number of bytes, keeping the end (i.e. the most recent data for
+// logs). If the value is longer than the limit, the start of the data is replaced by a truncation marker, which is not
+// counted as part of the limit. Values within the limit are returned unchanged.
+func TruncateValue(value interfaces.DataValue, limit int64) interfaces.DataValue {
+	if limit < 0 || value.GetLength() <= limit {
+		return value
+	}
+
+	return &truncatedValue{value: value, limit: limit}
+}
+
+type truncatedValue struct {
+	value interfaces.DataValue
+	limit int64
+}
+
+// GetLength returns the number of bytes kept, excluding the truncation marker.
+func (v *truncatedValue) GetLength() int64 {
+	return v.limit
+}
+
+func (v *truncatedValue) GetReader() (io.ReadCloser, error) {
+	reader, err := v.value.GetReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &truncatingReader{
+		source: reader,
+		skip:   v.value.GetLength() - v.limit,
+		limit:  v.limit,
+	}, nil
+}
+
+// truncatingReader drops the first bytes of the source, up to the start of the next line, and then reads at most
+// the remaining limit. The limit still applies if the source has grown since its length was read (e.g. a log file
+// being written to), so that the budget is never exceeded.
+type truncatingReader struct {
+	source io.ReadCloser
+	skip   int64
+	limit  int64
+	reader io.Reader
+}
+
+func (r *truncatingReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		if err := r.start(); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.reader.Read(p)
+}
+
+func (r *truncatingReader) start() error {
+	bufferedSource := bufio.NewReaderSize(r.source, maxLineAlignment)
+
+	skipped, err := io.CopyN(io.Discard, bufferedSource, r.skip-1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	// The last skipped byte shows whether the kept data already starts at the beginning of a line.
+	lastSkipped, err := bufferedSource.ReadByte()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == nil {
+		skipped++
+	}
+
+	// Otherwise, drop the remainder of the partial line, so that the kept data starts at the beginning of a line (which
+	// also means redaction rules are matched against whole lines). Data without line breaks is kept as it is.
+	lookahead := r.limit
+	if lookahead > maxLineAlignment {
+		lookahead = maxLineAlignment
+	}
+
+	var aligned int64
+	if err == nil && lastSkipped != '\n' && lookahead > 0 {
+		peeked, _ := bufferedSource.Peek(int(lookahead))
+		if index := bytes.IndexByte(peeked, '\n'); index >= 0 {
+			discarded, _ := bufferedSource.Discard(index + 1)
+			aligned = int64(discarded)
+		}
+	}
+
+	marker := strings.NewReader(fmt.Sprintf(truncationMarkerFormat, skipped+aligned))
+	r.reader = io.MultiReader(marker, io.LimitReader(bufferedSource, r.limit-aligned))
+	return nil
+}
+
+func (r *truncatingReader) Close() error {
+	return r.source.Close()
+}
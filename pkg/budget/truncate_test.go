@@ -0,0 +1,101 @@
+package budget
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+func readValue(t *testing.T, value interface {
+	GetReader() (io.ReadCloser, error)
+}) string {
+	reader, err := value.GetReader()
+	if err != nil {
+		t.Fatalf("error getting reader: %v", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(iotest.OneByteReader(reader))
+	if err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+	return string(content)
+}
+
+func TestTruncateValue(t *testing.T) {
+	lines := "line 1\nline 2\nline 3\nline 4\n"
+	longLine := strings.Repeat("x", 2*maxLineAlignment)
+
+	tests := []struct {
+		name     string
+		value    string
+		limit    int64
+		expected string
+	}{
+		{
+			name:     "within limit",
+			value:    lines,
+			limit:    int64(len(lines)),
+			expected: lines,
+		},
+		{
+			name:     "truncated at line boundary",
+			value:    lines,
+			limit:    14,
+			expected: fmt.Sprintf(truncationMarkerFormat, 14) + "line 3\nline 4\n",
+		},
+		{
+			name:     "truncated within line",
+			value:    lines,
+			limit:    10,
+			expected: fmt.Sprintf(truncationMarkerFormat, 21) + "line 4\n",
+		},
+		{
+			name:     "no line breaks",
+			value:    longLine,
+			limit:    10,
+			expected: fmt.Sprintf(truncationMarkerFormat, len(longLine)-10) + strings.Repeat("x", 10),
+		},
+		{
+			name:     "nothing kept",
+			value:    lines,
+			limit:    0,
+			expected: fmt.Sprintf(truncationMarkerFormat, len(lines)),
+		},
+	}
+
+	for _, tt := range tests {
+		value := TruncateValue(utils.NewStringDataValue(tt.value), tt.limit)
+		if actual := readValue(t, value); actual != tt.expected {
+			t.Errorf("%s: unexpected content.\nExpected '%s'\nFound '%s'", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+// growingValue reports a shorter length than its data, like a log file written to after its size was read.
+type growingValue struct {
+	length int64
+	data   string
+}
+
+func (v *growingValue) GetLength() int64 {
+	return v.length
+}
+
+func (v *growingValue) GetReader() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(v.data)), nil
+}
+
+func TestTruncateGrowingValue(t *testing.T) {
+	value := TruncateValue(&growingValue{length: 22, data: "0123456789\n0123456789\nnew data\n"}, 11)
+
+	// The limit applies even though the data is longer than its reported length.
+	expected := fmt.Sprintf(truncationMarkerFormat, 11) + "0123456789\n"
+	if actual := readValue(t, value); actual != expected {
+		t.Errorf("unexpected content.\nExpected '%s'\nFound '%s'", expected, actual)
+	}
+}
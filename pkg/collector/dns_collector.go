@@ -1,7 +1,7 @@
 package collector
 
 import (
-	"fmt"
+	"context"
 	"io"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
@@ -12,17 +12,28 @@ import (
 type DNSCollector struct {
 	HostConf      string
 	ContainerConf string
-	osIdentifier  utils.OSIdentifier
 	filePaths     *utils.KnownFilePaths
 	fileSystem    interfaces.FileSystemAccessor
 }
 
+func init() {
+	Register(&Registration{
+		Name: "dns",
+		// NOTE: This *might* be achievable in Windows using APIs that query the registry, see:
+		// https://kubernetes.io/docs/setup/production-environment/windows/intro-windows-in-kubernetes/#networking
+		// But for now it's restricted to Linux containers only, in which we can read `resolv.conf`.
+		OSes: []utils.OSIdentifier{utils.Linux},
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewDNSCollector(deps.KnownFilePaths, deps.FileSystem)
+		},
+	})
+}
+
 // NewDNSCollector is a constructor
-func NewDNSCollector(osIdentifier utils.OSIdentifier, filePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor) *DNSCollector {
+func NewDNSCollector(filePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor) *DNSCollector {
 	return &DNSCollector{
 		HostConf:      "",
 		ContainerConf: "",
-		osIdentifier:  osIdentifier,
 		filePaths:     filePaths,
 		fileSystem:    fileSystem,
 	}
@@ -33,18 +44,11 @@ func (collector *DNSCollector) GetName() string {
 }
 
 func (collector *DNSCollector) CheckSupported() error {
-	// NOTE: This *might* be achievable in Windows using APIs that query the registry, see:
-	// https://kubernetes.io/docs/setup/production-environment/windows/intro-windows-in-kubernetes/#networking
-	// But for now it's restricted to Linux containers only, in which we can read `resolv.conf`.
-	if collector.osIdentifier != utils.Linux {
-		return fmt.Errorf("unsupported OS: %s", collector.osIdentifier)
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *DNSCollector) Collect() error {
+func (collector *DNSCollector) Collect(ctx context.Context) error {
 	collector.HostConf = collector.getConfFileContent(collector.filePaths.ResolvConfHost)
 	collector.ContainerConf = collector.getConfFileContent(collector.filePaths.ResolvConfContainer)
 
@@ -60,6 +64,16 @@ func (collector *DNSCollector) getConfFileContent(filePath string) string {
 	return content
 }
 
+// GetHostConf implements the interfaces.DNSConfigOutput method
+func (collector *DNSCollector) GetHostConf() string {
+	return collector.HostConf
+}
+
+// GetContainerConf implements the interfaces.DNSConfigOutput method
+func (collector *DNSCollector) GetContainerConf() string {
+	return collector.ContainerConf
+}
+
 func (collector *DNSCollector) GetData() map[string]interfaces.DataValue {
 	return map[string]interfaces.DataValue{
 		"virtualmachine": utils.NewStringDataValue(collector.HostConf),
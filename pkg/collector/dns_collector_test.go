@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/aks-periscope/pkg/test"
@@ -10,7 +11,7 @@ import (
 func TestDNSCollectorGetName(t *testing.T) {
 	const expectedName = "dns"
 
-	c := NewDNSCollector("", nil, nil)
+	c := NewDNSCollector(nil, nil)
 	actualName := c.GetName()
 	if actualName != expectedName {
 		t.Errorf("unexpected name: expected %s, found %s", expectedName, actualName)
@@ -33,8 +34,7 @@ func TestDNSCollectorCheckSupported(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		c := NewDNSCollector(tt.osIdentifier, nil, nil)
-		err := c.CheckSupported()
+		err := checkSelected("dns", tt.osIdentifier, &utils.RuntimeInfo{})
 		if (err != nil) != tt.wantErr {
 			t.Errorf("CheckSupported() error = %v, wantErr %v", err, tt.wantErr)
 		}
@@ -90,8 +90,8 @@ func TestDNSCollectorCollect(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			fs := test.NewFakeFileSystem(tt.files)
 
-			c := NewDNSCollector(utils.Linux, filePaths, fs)
-			err := c.Collect()
+			c := NewDNSCollector(filePaths, fs)
+			err := c.Collect(context.Background())
 
 			if err != nil {
 				if !tt.wantErr {
@@ -1,10 +1,10 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"strings"
+	"log/slog"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -12,6 +12,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
 	"github.com/Azure/aks-periscope/pkg/utils"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/release"
@@ -41,6 +42,17 @@ type HelmCollector struct {
 	runtimeInfo *utils.RuntimeInfo
 }
 
+func init() {
+	Register(&Registration{
+		Name:     "helm",
+		Profiles: []string{"connectedCluster"},
+		Scope:    ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewHelmCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewHelmCollector is a constructor
 func NewHelmCollector(config *restclient.Config, runtimeInfo *utils.RuntimeInfo) *HelmCollector {
 	return &HelmCollector{
@@ -55,10 +67,6 @@ func (collector *HelmCollector) GetName() string {
 }
 
 func (collector *HelmCollector) CheckSupported() error {
-	if !utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' not in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
@@ -79,10 +87,13 @@ func (collector *HelmCollector) ToRawKubeConfigLoader() clientcmd.ClientConfig {
 }
 
 // Collect implements the interface method
-func (collector *HelmCollector) Collect() error {
+func (collector *HelmCollector) Collect(ctx context.Context) error {
 	actionConfig := new(action.Configuration)
 
-	if err := actionConfig.Init(collector, "", "", log.Printf); err != nil {
+	debugLog := func(format string, v ...interface{}) {
+		slog.Debug(fmt.Sprintf(format, v...), logging.ComponentKey, collector.GetName())
+	}
+	if err := actionConfig.Init(collector, "", "", debugLog); err != nil {
 		return fmt.Errorf("init action configuration: %w", err)
 	}
 
@@ -94,6 +105,11 @@ func (collector *HelmCollector) Collect() error {
 	result := make([]HelmRelease, 0)
 
 	for _, release := range releases {
+		// The helm client doesn't accept a context, so the best we can do is check for cancellation between releases.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		release.Chart.AppVersion()
 		r := HelmRelease{
 			Name:      release.Name,
@@ -105,7 +121,7 @@ func (collector *HelmCollector) Collect() error {
 		histories, err := action.NewHistory(actionConfig).Run(release.Name)
 
 		if err != nil {
-			log.Printf("Get release %s history failed: %v", release.Name, err)
+			slog.Warn("Failed to get Helm release history", logging.ComponentKey, collector.GetName(), "release", release.Name, logging.Error(err))
 		} else {
 			r.History = make([]HelmReleaseHistory, 0)
 			for _, history := range histories {
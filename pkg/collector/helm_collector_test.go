@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -64,8 +65,7 @@ func TestHelmCollectorCheckSupported(t *testing.T) {
 		runtimeInfo := &utils.RuntimeInfo{
 			CollectorList: tt.collectorList,
 		}
-		c := NewHelmCollector(nil, runtimeInfo)
-		err := c.CheckSupported()
+		err := checkSelected("helm", utils.Linux, runtimeInfo)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
@@ -95,7 +95,7 @@ func TestHelmCollectorCollect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.Collect()
+			err := c.Collect(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -1,8 +1,7 @@
 package collector
 
 import (
-	"fmt"
-	"strings"
+	"context"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -10,17 +9,27 @@ import (
 
 // IPTablesCollector defines a IPTables Collector struct
 type IPTablesCollector struct {
-	data         map[string]string
-	osIdentifier utils.OSIdentifier
-	runtimeInfo  *utils.RuntimeInfo
+	data        map[string]string
+	runtimeInfo *utils.RuntimeInfo
+}
+
+func init() {
+	Register(&Registration{
+		Name: "iptables",
+		// There's no obvious alternative to `iptables` on Windows.
+		OSes:             []utils.OSIdentifier{utils.Linux},
+		ExcludedProfiles: []string{"connectedCluster"},
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewIPTablesCollector(deps.RuntimeInfo)
+		},
+	})
 }
 
 // NewIPTablesCollector is a constructor
-func NewIPTablesCollector(osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo) *IPTablesCollector {
+func NewIPTablesCollector(runtimeInfo *utils.RuntimeInfo) *IPTablesCollector {
 	return &IPTablesCollector{
-		data:         make(map[string]string),
-		osIdentifier: osIdentifier,
-		runtimeInfo:  runtimeInfo,
+		data:        make(map[string]string),
+		runtimeInfo: runtimeInfo,
 	}
 }
 
@@ -29,21 +38,12 @@ func (collector *IPTablesCollector) GetName() string {
 }
 
 func (collector *IPTablesCollector) CheckSupported() error {
-	// There's no obvious alternative to `iptables` on Windows.
-	if collector.osIdentifier != utils.Linux {
-		return fmt.Errorf("unsupported OS: %s", collector.osIdentifier)
-	}
-
-	if utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' is in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *IPTablesCollector) Collect() error {
-	output, err := utils.RunCommandOnHost("iptables", "-t", "nat", "-L")
+func (collector *IPTablesCollector) Collect(ctx context.Context) error {
+	output, err := utils.RunCommandOnHost(ctx, "iptables", "-t", "nat", "-L")
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -9,7 +10,7 @@ import (
 func TestIPTablesCollectorGetName(t *testing.T) {
 	const expectedName = "iptables"
 
-	c := NewIPTablesCollector("", nil)
+	c := NewIPTablesCollector(nil)
 	actualName := c.GetName()
 	if actualName != expectedName {
 		t.Errorf("unexpected name: expected %s, found %s", expectedName, actualName)
@@ -47,8 +48,7 @@ func TestIPTablesCollectorCheckSupported(t *testing.T) {
 		runtimeInfo := &utils.RuntimeInfo{
 			CollectorList: tt.collectorList,
 		}
-		c := NewIPTablesCollector(tt.osIdentifier, runtimeInfo)
-		err := c.CheckSupported()
+		err := checkSelected("iptables", tt.osIdentifier, runtimeInfo)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
@@ -71,11 +71,11 @@ func TestIPTablesCollectorCollect(t *testing.T) {
 	runtimeInfo := &utils.RuntimeInfo{
 		CollectorList: []string{},
 	}
-	c := NewIPTablesCollector(utils.Linux, runtimeInfo)
+	c := NewIPTablesCollector(runtimeInfo)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.Collect()
+			err := c.Collect(context.Background())
 			if (err != nil) == tt.wantErr {
 				t.Logf("Collect() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -1,8 +1,7 @@
 package collector
 
 import (
-	"fmt"
-	"strings"
+	"context"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -11,15 +10,27 @@ import (
 // KubeletCmdCollector defines a KubeletCmd Collector struct
 type KubeletCmdCollector struct {
 	KubeletCommand string
-	osIdentifier   utils.OSIdentifier
 	runtimeInfo    *utils.RuntimeInfo
 }
 
+func init() {
+	Register(&Registration{
+		Name: "kubeletcmd",
+		// This looks to be impossible on Windows, since Windows containers don't support shared process namespaces,
+		// and hence processes on the host are completely isolated from the container. See:
+		// https://docs.microsoft.com/en-us/virtualization/windowscontainers/manage-containers/hyperv-container#piercing-the-isolation-boundary
+		OSes:             []utils.OSIdentifier{utils.Linux},
+		ExcludedProfiles: []string{"connectedCluster"},
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewKubeletCmdCollector(deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewKubeletCmdCollector is a constructor
-func NewKubeletCmdCollector(osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo) *KubeletCmdCollector {
+func NewKubeletCmdCollector(runtimeInfo *utils.RuntimeInfo) *KubeletCmdCollector {
 	return &KubeletCmdCollector{
 		KubeletCommand: "",
-		osIdentifier:   osIdentifier,
 		runtimeInfo:    runtimeInfo,
 	}
 }
@@ -29,23 +40,12 @@ func (collector *KubeletCmdCollector) GetName() string {
 }
 
 func (collector *KubeletCmdCollector) CheckSupported() error {
-	// This looks to be impossible on Windows, since Windows containers don't support shared process namespaces,
-	// and hence processes on the host are completely isolated from the container. See:
-	// https://docs.microsoft.com/en-us/virtualization/windowscontainers/manage-containers/hyperv-container#piercing-the-isolation-boundary
-	if collector.osIdentifier != utils.Linux {
-		return fmt.Errorf("unsupported OS: %s", collector.osIdentifier)
-	}
-
-	if utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' is in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *KubeletCmdCollector) Collect() error {
-	output, err := utils.RunCommandOnHost("ps", "-o", "cmd=", "-C", "kubelet")
+func (collector *KubeletCmdCollector) Collect(ctx context.Context) error {
+	output, err := utils.RunCommandOnHost(ctx, "ps", "-o", "cmd=", "-C", "kubelet")
 	if err != nil {
 		return err
 	}
@@ -55,6 +55,11 @@ func (collector *KubeletCmdCollector) Collect() error {
 	return nil
 }
 
+// GetKubeletCommand implements the interfaces.KubeletCommandOutput method
+func (collector *KubeletCmdCollector) GetKubeletCommand() string {
+	return collector.KubeletCommand
+}
+
 func (collector *KubeletCmdCollector) GetData() map[string]interfaces.DataValue {
 	return map[string]interfaces.DataValue{
 		"kubeletcmd": utils.NewStringDataValue(collector.KubeletCommand),
@@ -1,11 +1,13 @@
 package collector
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
 	"github.com/Azure/aks-periscope/pkg/utils"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,6 +27,16 @@ type KubeObjectsCollector struct {
 	runtimeInfo   *utils.RuntimeInfo
 }
 
+func init() {
+	Register(&Registration{
+		Name:  "kubeobjects",
+		Scope: ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewKubeObjectsCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewKubeObjectsCollector is a constructor
 func NewKubeObjectsCollector(config *restclient.Config, runtimeInfo *utils.RuntimeInfo) *KubeObjectsCollector {
 	return &KubeObjectsCollector{
@@ -43,73 +55,134 @@ func (collector *KubeObjectsCollector) CheckSupported() error {
 	return nil
 }
 
+// kubeObjectSelection is a DIAGNOSTIC_KUBEOBJECTS_LIST entry resolved to the Kind and names of the objects it selects.
+type kubeObjectSelection struct {
+	namespace        string
+	groupResource    schema.GroupResource
+	groupVersionKind schema.GroupVersionKind
+	resourceNames    []string
+}
+
+// getKey gets the key the description of the named object is collected under.
+func (selection *kubeObjectSelection) getKey(resourceName string) string {
+	return fmt.Sprintf("%s_%s_%s", selection.namespace, selection.groupResource.String(), resourceName)
+}
+
 // Collect implements the interface method
-func (collector *KubeObjectsCollector) Collect() error {
-	// Create a discovery client for querying resource metadata
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(collector.kubeconfig)
+func (collector *KubeObjectsCollector) Collect(ctx context.Context) error {
+	mapper, err := collector.getRESTMapper()
 	if err != nil {
-		return fmt.Errorf("error creating discovery client: %w", err)
+		return err
 	}
 
-	// Create a RESTMapper to handle the mapping between GroupKind and GroupVersionResource
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
-
 	for _, kubernetesObject := range collector.runtimeInfo.KubernetesObjects {
-		kubernetesObjectParts := strings.Split(kubernetesObject, "/")
-		if len(kubernetesObjectParts) < 2 {
-			log.Printf("Invalid kube-objects value: %s", kubernetesObject)
-			continue
+		// Describers don't accept a context, so check for cancellation between objects.
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
 
-		namespace := kubernetesObjectParts[0]
-		groupResource := schema.ParseGroupResource(kubernetesObjectParts[1])
-
-		groupVersionKind, err := mapper.KindFor(groupResource.WithVersion(""))
+		selection, err := collector.resolve(ctx, mapper, kubernetesObject)
 		if err != nil {
-			log.Printf("Unable to determine Kind for resource %s: %v", groupResource.String(), err)
+			slog.Warn("Failed to resolve Kubernetes objects", logging.ComponentKey, collector.GetName(), "objects", kubernetesObject, logging.Error(err))
 			continue
 		}
 
-		describer, ok := describe.DescriberFor(groupVersionKind.GroupKind(), collector.kubeconfig)
+		describer, ok := describe.DescriberFor(selection.groupVersionKind.GroupKind(), collector.kubeconfig)
 		if !ok {
-			log.Printf("Unable to create Describer for Kind %s", groupVersionKind.String())
+			slog.Warn("Unable to create Describer", logging.ComponentKey, collector.GetName(), "kind", selection.groupVersionKind.String())
 			continue
 		}
 
-		// Get the resources within the namespace to describe
-		var resourceNames []string
-		if len(kubernetesObjectParts) > 2 {
-			resourceNames = []string{kubernetesObjectParts[2]}
-		} else {
-			resourceNames, err = collector.getResourcesInNamespace(mapper, &groupResource, namespace)
+		for _, resourceName := range selection.resourceNames {
+			output, err := describer.Describe(selection.namespace, resourceName, describe.DescriberSettings{ShowEvents: true})
 			if err != nil {
-				log.Printf("Unable to get %s resources in %s: %v", groupResource.String(), namespace, err)
+				slog.Warn("Error describing Kubernetes object", logging.ComponentKey, collector.GetName(), "kind", selection.groupVersionKind.String(), "namespace", selection.namespace, "name", resourceName, logging.Error(err))
 				continue
 			}
+
+			collector.data[selection.getKey(resourceName)] = output
 		}
+	}
 
-		for _, resourceName := range resourceNames {
-			output, err := describer.Describe(namespace, resourceName, describe.DescriberSettings{ShowEvents: true})
-			if err != nil {
-				log.Printf("Error describing %s %s in namespace %s: %v", groupVersionKind.String(), resourceName, namespace, err)
-				continue
-			}
+	return nil
+}
 
-			key := fmt.Sprintf("%s_%s_%s", namespace, groupResource.String(), resourceName)
-			collector.data[key] = output
+// Plan implements the Planner interface, resolving each DIAGNOSTIC_KUBEOBJECTS_LIST entry to the objects that would be
+// described. The size of the descriptions is not known in advance.
+func (collector *KubeObjectsCollector) Plan(ctx context.Context) ([]*Target, error) {
+	mapper, err := collector.getRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []*Target{}
+	for _, kubernetesObject := range collector.runtimeInfo.KubernetesObjects {
+		selection, err := collector.resolve(ctx, mapper, kubernetesObject)
+		if err != nil {
+			targets = append(targets, &Target{Source: kubernetesObject, Error: err.Error()})
+			continue
+		}
+
+		for _, resourceName := range selection.resourceNames {
+			targets = append(targets, &Target{
+				Name:   selection.getKey(resourceName),
+				Source: fmt.Sprintf("%s/%s/%s", selection.namespace, selection.groupResource.String(), resourceName),
+			})
 		}
 	}
 
-	return nil
+	return targets, nil
+}
+
+// getRESTMapper creates a RESTMapper to handle the mapping between GroupKind and GroupVersionResource, using a
+// discovery client for querying resource metadata.
+func (collector *KubeObjectsCollector) getRESTMapper() (meta.RESTMapper, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(collector.kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating discovery client: %w", err)
+	}
+
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)), nil
+}
+
+// resolve resolves a namespace/resource-type[/resource] entry to the Kind and names of the objects it selects.
+func (collector *KubeObjectsCollector) resolve(ctx context.Context, mapper meta.RESTMapper, kubernetesObject string) (*kubeObjectSelection, error) {
+	kubernetesObjectParts := strings.Split(kubernetesObject, "/")
+	if len(kubernetesObjectParts) < 2 {
+		return nil, fmt.Errorf("invalid kube-objects value: %s", kubernetesObject)
+	}
+
+	selection := &kubeObjectSelection{
+		namespace:     kubernetesObjectParts[0],
+		groupResource: schema.ParseGroupResource(kubernetesObjectParts[1]),
+	}
+
+	groupVersionKind, err := mapper.KindFor(selection.groupResource.WithVersion(""))
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine Kind for resource %s: %w", selection.groupResource.String(), err)
+	}
+	selection.groupVersionKind = groupVersionKind
+
+	// Get the resources within the namespace to describe
+	if len(kubernetesObjectParts) > 2 {
+		selection.resourceNames = []string{kubernetesObjectParts[2]}
+	} else {
+		selection.resourceNames, err = collector.getResourcesInNamespace(ctx, mapper, &selection.groupResource, selection.namespace)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get %s resources in %s: %w", selection.groupResource.String(), selection.namespace, err)
+		}
+	}
+
+	return selection, nil
 }
 
-func (collector *KubeObjectsCollector) getResourcesInNamespace(mapper meta.RESTMapper, groupResource *schema.GroupResource, namespace string) ([]string, error) {
+func (collector *KubeObjectsCollector) getResourcesInNamespace(ctx context.Context, mapper meta.RESTMapper, groupResource *schema.GroupResource, namespace string) ([]string, error) {
 	groupVersionResource, err := mapper.ResourceFor(groupResource.WithVersion(""))
 	if err != nil {
 		return []string{}, fmt.Errorf("error determining Version for resource %s: %v", groupResource.String(), err)
 	}
 
-	resources, err := collector.commandRunner.GetUnstructuredList(&groupVersionResource, namespace, &metav1.ListOptions{})
+	resources, err := collector.commandRunner.GetUnstructuredList(ctx, &groupVersionResource, namespace, &metav1.ListOptions{})
 	if err != nil {
 		return []string{}, fmt.Errorf("error listing %s: %v", groupVersionResource.String(), err)
 	}
@@ -23,8 +23,7 @@ func TestKubeObjectsCollectorGetName(t *testing.T) {
 }
 
 func TestKubeObjectsCollectorCheckSupported(t *testing.T) {
-	c := NewKubeObjectsCollector(nil, nil)
-	err := c.CheckSupported()
+	err := checkSelected("kubeobjects", utils.Linux, &utils.RuntimeInfo{})
 	if err != nil {
 		t.Errorf("error checking supported: %v", err)
 	}
@@ -217,7 +216,7 @@ func TestKubeObjectsCollectorCollect(t *testing.T) {
 
 			c := NewKubeObjectsCollector(tt.config, runtimeInfo)
 
-			err := c.Collect()
+			err := c.Collect(context.Background())
 
 			if tt.wantErr {
 				if err == nil {
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -27,6 +28,15 @@ type NetworkOutboundCollector struct {
 	data map[string]string
 }
 
+func init() {
+	Register(&Registration{
+		Name: "networkoutbound",
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewNetworkOutboundCollector()
+		},
+	})
+}
+
 // NewNetworkOutboundCollector is a constructor
 func NewNetworkOutboundCollector() *NetworkOutboundCollector {
 	return &NetworkOutboundCollector{
@@ -43,7 +53,7 @@ func (collector *NetworkOutboundCollector) CheckSupported() error {
 }
 
 // Collect implements the interface method
-func (collector *NetworkOutboundCollector) Collect() error {
+func (collector *NetworkOutboundCollector) Collect(ctx context.Context) error {
 	outboundTypes := []networkOutboundType{}
 	outboundTypes = append(outboundTypes,
 		networkOutboundType{
@@ -71,8 +81,16 @@ func (collector *NetworkOutboundCollector) Collect() error {
 	)
 
 	for _, outboundType := range outboundTypes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		timeout := time.Duration(5 * time.Second)
-		_, err := net.DialTimeout("tcp", outboundType.URL, timeout)
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", outboundType.URL)
+		if err == nil {
+			conn.Close()
+		}
 
 		status := "Connected"
 		if err != nil {
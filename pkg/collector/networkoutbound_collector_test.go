@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"context"
 	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/utils"
 )
 
 func TestNetworkOutboundCollectorGetName(t *testing.T) {
@@ -15,8 +18,7 @@ func TestNetworkOutboundCollectorGetName(t *testing.T) {
 }
 
 func TestNetworkOutboundCollectorCheckSupported(t *testing.T) {
-	c := NewNetworkOutboundCollector()
-	err := c.CheckSupported()
+	err := checkSelected("networkoutbound", utils.Linux, &utils.RuntimeInfo{})
 	if err != nil {
 		t.Errorf("error checking supported: %v", err)
 	}
@@ -39,7 +41,7 @@ func TestNetworkOutboundCollectorCollect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.Collect()
+			err := c.Collect(context.Background())
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -15,6 +16,18 @@ type NodeLogsCollector struct {
 	fileSystem  interfaces.FileSystemAccessor
 }
 
+func init() {
+	Register(&Registration{
+		Name: "nodelogs",
+		// Although the files read by this collector may be different between Windows and Linux,
+		// they are defined in a ConfigMap which is expected to be populated correctly for the OS.
+		ExcludedProfiles: []string{"connectedCluster"},
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewNodeLogsCollector(deps.RuntimeInfo, deps.FileSystem)
+		},
+	})
+}
+
 // NewNodeLogsCollector is a constructor
 func NewNodeLogsCollector(runtimeInfo *utils.RuntimeInfo, fileSystem interfaces.FileSystemAccessor) *NodeLogsCollector {
 	return &NodeLogsCollector{
@@ -29,34 +42,49 @@ func (collector *NodeLogsCollector) GetName() string {
 }
 
 func (collector *NodeLogsCollector) CheckSupported() error {
-	if utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' is in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
-	// Although the files read by this collector may be different between Windows and Linux,
-	// they are defined in a ConfigMap which is expected to be populated correctly for the OS.
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *NodeLogsCollector) Collect() error {
+func (collector *NodeLogsCollector) Collect(ctx context.Context) error {
 	for _, nodeLog := range collector.runtimeInfo.NodeLogs {
-		normalizedNodeLog := strings.Replace(nodeLog, "/", "_", -1)
-		if normalizedNodeLog[0] == '_' {
-			normalizedNodeLog = normalizedNodeLog[1:]
-		}
-
 		size, err := collector.fileSystem.GetFileSize(nodeLog)
 		if err != nil {
 			return fmt.Errorf("error getting file size for %s: %w", nodeLog, err)
 		}
 
-		collector.data[normalizedNodeLog] = utils.NewFilePathDataValue(collector.fileSystem, nodeLog, size)
+		collector.data[getNodeLogKey(nodeLog)] = utils.NewFilePathDataValue(collector.fileSystem, nodeLog, size)
 	}
 
 	return nil
 }
 
+// Plan implements the Planner interface, resolving the node log paths to their current sizes without reading them.
+func (collector *NodeLogsCollector) Plan(ctx context.Context) ([]*Target, error) {
+	targets := []*Target{}
+	for _, nodeLog := range collector.runtimeInfo.NodeLogs {
+		target := &Target{Name: getNodeLogKey(nodeLog), Source: nodeLog}
+		size, err := collector.fileSystem.GetFileSize(nodeLog)
+		if err != nil {
+			target.Error = fmt.Sprintf("error getting file size: %v", err)
+		} else {
+			target.EstimatedSize = &size
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// getNodeLogKey gets the key the node log at the specified path is collected under, e.g. var_log_syslog.
+func getNodeLogKey(nodeLog string) string {
+	normalizedNodeLog := strings.Replace(nodeLog, "/", "_", -1)
+	if normalizedNodeLog[0] == '_' {
+		normalizedNodeLog = normalizedNodeLog[1:]
+	}
+	return normalizedNodeLog
+}
+
 func (collector *NodeLogsCollector) GetData() map[string]interfaces.DataValue {
 	return collector.data
 }
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/aks-periscope/pkg/test"
@@ -39,8 +40,7 @@ func TestNodeLogsCollectorCheckSupported(t *testing.T) {
 		runtimeInfo := &utils.RuntimeInfo{
 			CollectorList: tt.collectorList,
 		}
-		c := NewNodeLogsCollector(runtimeInfo, nil)
-		err := c.CheckSupported()
+		err := checkSelected("nodelogs", utils.Linux, runtimeInfo)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
@@ -101,7 +101,7 @@ func TestNodeLogsCollectorCollect(t *testing.T) {
 				CollectorList: []string{},
 			}
 			c := NewNodeLogsCollector(runtimeInfo, fs)
-			err := c.Collect()
+			err := c.Collect(context.Background())
 
 			if err != nil {
 				if !tt.wantErr {
@@ -125,3 +125,33 @@ func TestNodeLogsCollectorCollect(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeLogsCollectorPlan(t *testing.T) {
+	fs := test.NewFakeFileSystem(map[string]string{
+		"/var/log/test1.log": "Test 1 Content",
+	})
+
+	runtimeInfo := &utils.RuntimeInfo{
+		NodeLogs: []string{"/var/log/test1.log", "/var/log/missing.log"},
+	}
+	c := NewNodeLogsCollector(runtimeInfo, fs)
+	targets, err := c.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, found %d", len(targets))
+	}
+	if target := targets[0]; target.Name != "var_log_test1.log" || target.Source != "/var/log/test1.log" || target.EstimatedSize == nil || *target.EstimatedSize != 14 || target.Error != "" {
+		t.Errorf("unexpected target for existing file: %+v", target)
+	}
+	if target := targets[1]; target.Name != "var_log_missing.log" || target.EstimatedSize != nil || target.Error == "" {
+		t.Errorf("unexpected target for missing file: %+v", target)
+	}
+
+	// Nothing is read while planning.
+	if len(c.GetData()) != 0 {
+		t.Errorf("expected no data after planning, found %d values", len(c.GetData()))
+	}
+}
@@ -6,13 +6,14 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
 	"github.com/Azure/aks-periscope/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
@@ -33,6 +34,17 @@ type OsmCollector struct {
 	runtimeInfo   *utils.RuntimeInfo
 }
 
+func init() {
+	Register(&Registration{
+		Name:     "osm",
+		Profiles: []string{"OSM"},
+		Scope:    ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewOsmCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewOsmCollector is a constructor
 func NewOsmCollector(config *rest.Config, runtimeInfo *utils.RuntimeInfo) *OsmCollector {
 	return &OsmCollector{
@@ -48,22 +60,18 @@ func (collector *OsmCollector) GetName() string {
 }
 
 func (collector *OsmCollector) CheckSupported() error {
-	if !utils.Contains(collector.runtimeInfo.CollectorList, "OSM") {
-		return fmt.Errorf("not included because 'OSM' not in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *OsmCollector) Collect() error {
+func (collector *OsmCollector) Collect(ctx context.Context) error {
 	clientset, err := kubernetes.NewForConfig(collector.kubeconfig)
 	if err != nil {
 		return fmt.Errorf("getting access to K8S failed: %w", err)
 	}
 
 	// Get all OSM deployments in order to collect information for various resources across all meshes in the cluster
-	meshDeploymentList, err := clientset.AppsV1().Deployments("").List(context.Background(), metav1.ListOptions{
+	meshDeploymentList, err := clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
 		LabelSelector: "app=osm-controller",
 	})
 	if err != nil {
@@ -77,13 +85,13 @@ func (collector *OsmCollector) Collect() error {
 		}
 
 		monitoredNamespaces := []string{}
-		monitoredNamespaceList, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{
+		monitoredNamespaceList, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
 			LabelSelector: fmt.Sprintf("openservicemesh.io/monitored-by=%s", meshName),
 		})
 		if err != nil {
 			// If no monitored namespaces are found, just log and continue - this is not an error.
 			if k8sErrors.IsNotFound(err) {
-				log.Printf("Failed to find any namespaces monitored by OSM named '%s'\n", meshName)
+				slog.Info("Found no namespaces monitored by OSM mesh", logging.ComponentKey, collector.GetName(), "mesh", meshName)
 				continue
 			}
 			return fmt.Errorf("error listing namespaces monitored by OSM named %s: %w", meshName, err)
@@ -93,39 +101,39 @@ func (collector *OsmCollector) Collect() error {
 			}
 		}
 
-		collector.callNamespaceCollectors(clientset, monitoredNamespaces, deployment.Namespace, meshName)
-		collector.collectGroundTruth(clientset, meshName)
+		collector.callNamespaceCollectors(ctx, clientset, monitoredNamespaces, deployment.Namespace, meshName)
+		collector.collectGroundTruth(ctx, clientset, meshName)
 	}
 
 	return nil
 }
 
 // callNamespaceCollectors calls functions to collect data for osm-controller namespace and namespaces monitored by a given mesh
-func (collector *OsmCollector) callNamespaceCollectors(clientset *kubernetes.Clientset, monitoredNamespaces []string, controllerNamespace string, meshName string) {
+func (collector *OsmCollector) callNamespaceCollectors(ctx context.Context, clientset *kubernetes.Clientset, monitoredNamespaces []string, controllerNamespace string, meshName string) {
 	for _, namespace := range monitoredNamespaces {
-		if err := collector.collectDataFromEnvoys(clientset, namespace, meshName); err != nil {
-			log.Printf("Failed to collect Envoy configs in OSM monitored namespace %s: %+v", namespace, err)
+		if err := collector.collectDataFromEnvoys(ctx, clientset, namespace, meshName); err != nil {
+			slog.Warn("Failed to collect Envoy configs in OSM monitored namespace", logging.ComponentKey, collector.GetName(), "namespace", namespace, logging.Error(err))
 		}
-		collector.collectNamespaceResources(namespace, meshName)
+		collector.collectNamespaceResources(ctx, namespace, meshName)
 	}
 
-	if err := collector.collectPodLogs(clientset, controllerNamespace, meshName); err != nil {
-		log.Printf("Failed to collect pod logs for controller namespace %s: %+v", controllerNamespace, err)
+	if err := collector.collectPodLogs(ctx, clientset, controllerNamespace, meshName); err != nil {
+		slog.Warn("Failed to collect pod logs for OSM controller namespace", logging.ComponentKey, collector.GetName(), "namespace", controllerNamespace, logging.Error(err))
 	}
-	collector.collectNamespaceResources(controllerNamespace, meshName)
+	collector.collectNamespaceResources(ctx, controllerNamespace, meshName)
 }
 
 // collectNamespaceResources collects information about general resources in a given namespace
-func (collector *OsmCollector) collectNamespaceResources(namespace string, meshName string) {
-	if err := collector.collectPodConfigs(namespace, meshName); err != nil {
-		log.Printf("Failed to collect pod configs for ns %s: %+v", namespace, err)
+func (collector *OsmCollector) collectNamespaceResources(ctx context.Context, namespace string, meshName string) {
+	if err := collector.collectPodConfigs(ctx, namespace, meshName); err != nil {
+		slog.Warn("Failed to collect pod configs", logging.ComponentKey, collector.GetName(), "namespace", namespace, logging.Error(err))
 	}
 
 	key := fmt.Sprintf("%s/%s_%s", meshName, namespace, "metadata")
-	value, err := collector.commandRunner.GetJsonObjectOutput(&schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}, "", namespace)
+	value, err := collector.commandRunner.GetJsonObjectOutput(ctx, &schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}, "", namespace)
 	if err != nil {
 		value = fmt.Sprintf("Failed to collect metadata for namespace %s: %+v\n", namespace, err)
-		log.Print(value)
+		slog.Warn("Failed to collect namespace metadata", logging.ComponentKey, collector.GetName(), "namespace", namespace, logging.Error(err))
 	}
 	collector.data[key] = value
 
@@ -151,22 +159,22 @@ func (collector *OsmCollector) collectNamespaceResources(namespace string, meshN
 		key = fmt.Sprintf("%s/%s_%s", meshName, namespace, defn.collectorKey)
 		listOptions := &metav1.ListOptions{}
 		if defn.asJson {
-			value, err = collector.commandRunner.GetJsonListOutput(&defn.GroupVersionResource, namespace, listOptions)
+			value, err = collector.commandRunner.GetJsonListOutput(ctx, &defn.GroupVersionResource, namespace, listOptions)
 		} else {
-			value, err = collector.commandRunner.GetTableOutput(&defn.GroupVersionResource, namespace, listOptions, &printers.PrintOptions{Wide: true})
+			value, err = collector.commandRunner.GetTableOutput(ctx, &defn.GroupVersionResource, namespace, listOptions, &printers.PrintOptions{Wide: true})
 		}
 		if err != nil {
 			value = fmt.Sprintf("Failed to collect %s for namespace %s: %+v\n", defn.GroupVersionResource.Resource, namespace, err)
-			log.Print(value)
+			slog.Warn("Failed to collect namespace resources", logging.ComponentKey, collector.GetName(), "namespace", namespace, "resource", defn.GroupVersionResource.Resource, logging.Error(err))
 		}
 		collector.data[key] = value
 	}
 }
 
 // collectPodConfigs collects configs for pods in given namespace
-func (collector *OsmCollector) collectPodConfigs(namespace string, meshName string) error {
+func (collector *OsmCollector) collectPodConfigs(ctx context.Context, namespace string, meshName string) error {
 	listOptions := &metav1.ListOptions{}
-	list, err := collector.commandRunner.GetUnstructuredList(&schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}, namespace, listOptions)
+	list, err := collector.commandRunner.GetUnstructuredList(ctx, &schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}, namespace, listOptions)
 	if err != nil {
 		return err
 	}
@@ -174,8 +182,8 @@ func (collector *OsmCollector) collectPodConfigs(namespace string, meshName stri
 		podName := item.GetName()
 		value, err := collector.commandRunner.PrintAsJson(&item)
 		if err != nil {
-			value := fmt.Sprintf("Failed to read JSON for pod %s in %s: %+v\n", podName, namespace, err)
-			log.Print(value)
+			value = fmt.Sprintf("Failed to read JSON for pod %s in %s: %+v\n", podName, namespace, err)
+			slog.Warn("Failed to read pod JSON", logging.ComponentKey, collector.GetName(), "namespace", namespace, "pod", podName, logging.Error(err))
 		}
 		key := fmt.Sprintf("%s/%s_podConfig", meshName, podName)
 		collector.data[key] = value
@@ -184,14 +192,14 @@ func (collector *OsmCollector) collectPodConfigs(namespace string, meshName stri
 }
 
 // collectDataFromEnvoys collects Envoy proxy config for pods in monitored namespace: port-forward and curl config dump
-func (collector *OsmCollector) collectDataFromEnvoys(clientset *kubernetes.Clientset, namespace string, meshName string) error {
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+func (collector *OsmCollector) collectDataFromEnvoys(ctx context.Context, clientset *kubernetes.Clientset, namespace string, meshName string) error {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
 	for _, pod := range pods.Items {
-		err = collector.portForwardAndRunEnvoyQueries(meshName, namespace, pod.Name)
+		err = collector.portForwardAndRunEnvoyQueries(ctx, meshName, namespace, pod.Name)
 		if err != nil {
 			return err
 		}
@@ -199,7 +207,7 @@ func (collector *OsmCollector) collectDataFromEnvoys(clientset *kubernetes.Clien
 	return nil
 }
 
-func (collector *OsmCollector) portForwardAndRunEnvoyQueries(meshName, namespace, podName string) error {
+func (collector *OsmCollector) portForwardAndRunEnvoyQueries(ctx context.Context, meshName, namespace, podName string) error {
 	var buffOut, buffErr bytes.Buffer
 	readyChan := make(chan struct{})
 	stopChan := make(chan struct{}, 1)
@@ -227,20 +235,23 @@ func (collector *OsmCollector) portForwardAndRunEnvoyQueries(meshName, namespace
 	select {
 	case err := <-errorChan:
 		return err
+	case <-ctx.Done():
+		// Closing stopChan (deferred above) stops the port-forward.
+		return ctx.Err()
 	case <-readyChan:
-		collector.runEnvoyQueries(meshName, namespace, podName, localPort)
+		collector.runEnvoyQueries(ctx, meshName, namespace, podName, localPort)
 	}
 
 	return nil
 }
 
-func (collector *OsmCollector) runEnvoyQueries(meshName, namespace, podName string, localPort int) {
+func (collector *OsmCollector) runEnvoyQueries(ctx context.Context, meshName, namespace, podName string, localPort int) {
 	envoyQueries := [5]string{"config_dump", "clusters", "listeners", "ready", "stats"}
 	for _, query := range envoyQueries {
 		queryUrl := fmt.Sprintf("http://localhost:%d/%s", localPort, query)
-		responseBody, err := utils.GetUrlWithRetries(queryUrl, 5)
+		responseBody, err := utils.GetUrlWithRetries(ctx, queryUrl, 5)
 		if err != nil {
-			log.Printf("Failed to collect Envoy %s for pod %s in OSM monitored namespace %s: %+v", query, podName, namespace, err)
+			slog.Warn("Failed to collect Envoy data", logging.ComponentKey, collector.GetName(), "namespace", namespace, "pod", podName, "query", query, logging.Error(err))
 			continue
 		}
 		// Remove certificate secrets from Envoy config i.e., "inline_bytes" field from response
@@ -285,17 +296,17 @@ func (collector *OsmCollector) portForward(params *portForwardParams) error {
 }
 
 // collectPodLogs collects logs of every pod in a given namespace
-func (collector *OsmCollector) collectPodLogs(clientset *kubernetes.Clientset, namespace string, meshName string) error {
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+func (collector *OsmCollector) collectPodLogs(ctx context.Context, clientset *kubernetes.Clientset, namespace string, meshName string) error {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
 	for _, pod := range pods.Items {
-		output, err := collector.getSinglePodLogs(clientset, namespace, pod.Name)
+		output, err := collector.getSinglePodLogs(ctx, clientset, namespace, pod.Name)
 		if err != nil {
 			output = fmt.Sprintf("Failed to collect logs for pod %s: %+v\n", pod.Name, err)
-			log.Print(output)
+			slog.Warn("Failed to collect pod logs", logging.ComponentKey, collector.GetName(), "namespace", namespace, "pod", pod.Name, logging.Error(err))
 		}
 		filePath := meshName + "/" + pod.Name + "_podLogs"
 		collector.data[filePath] = output
@@ -303,9 +314,9 @@ func (collector *OsmCollector) collectPodLogs(clientset *kubernetes.Clientset, n
 	return nil
 }
 
-func (collector *OsmCollector) getSinglePodLogs(clientset *kubernetes.Clientset, namespace, podName string) (string, error) {
+func (collector *OsmCollector) getSinglePodLogs(ctx context.Context, clientset *kubernetes.Clientset, namespace, podName string) (string, error) {
 	req := clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{})
-	podLogs, err := req.Stream(context.Background())
+	podLogs, err := req.Stream(ctx)
 	if err != nil {
 		return "", fmt.Errorf("error getting log stream for %s/%s", namespace, podName)
 	}
@@ -320,7 +331,7 @@ func (collector *OsmCollector) getSinglePodLogs(clientset *kubernetes.Clientset,
 }
 
 // collectGroundTruth collects ground truth on resources in given mesh
-func (collector *OsmCollector) collectGroundTruth(clientset *kubernetes.Clientset, meshName string) {
+func (collector *OsmCollector) collectGroundTruth(ctx context.Context, clientset *kubernetes.Clientset, meshName string) {
 	type groupVersionResourceKind struct {
 		schema.GroupVersionResource
 		kind string
@@ -360,14 +371,14 @@ func (collector *OsmCollector) collectGroundTruth(clientset *kubernetes.Clientse
 	}
 
 	// Add another query definition for meshconfigs, if we can retrieve the metadata for them.
-	gvrForMeshConfig, err := collector.commandRunner.GetGVRForCRD("meshconfigs.config.openservicemesh.io")
+	gvrForMeshConfig, err := collector.commandRunner.GetGVRForCRD(ctx, "meshconfigs.config.openservicemesh.io")
 	if err == nil {
 		gvrksForMeshConfig := []groupVersionResourceKind{
 			{GroupVersionResource: *gvrForMeshConfig, kind: "MeshConfig"},
 		}
 		queryDefinitions = append(queryDefinitions, queryDefinition{collectorKey: "mesh_configs", gvrks: gvrksForMeshConfig, labelSelector: "", asJson: true})
 	} else {
-		log.Printf("Failed to read MeshConfig CRD: %v", err)
+		slog.Warn("Failed to read MeshConfig CRD", logging.ComponentKey, collector.GetName(), logging.Error(err))
 	}
 
 	for _, defn := range queryDefinitions {
@@ -376,9 +387,9 @@ func (collector *OsmCollector) collectGroundTruth(clientset *kubernetes.Clientse
 			listOptions := &metav1.ListOptions{LabelSelector: defn.labelSelector}
 			var output string
 			if defn.asJson {
-				output, err = collector.commandRunner.GetJsonListOutput(&gvrk.GroupVersionResource, "", listOptions)
+				output, err = collector.commandRunner.GetJsonListOutput(ctx, &gvrk.GroupVersionResource, "", listOptions)
 			} else {
-				output, err = collector.commandRunner.GetTableOutput(&gvrk.GroupVersionResource, "", listOptions, &printers.PrintOptions{
+				output, err = collector.commandRunner.GetTableOutput(ctx, &gvrk.GroupVersionResource, "", listOptions, &printers.PrintOptions{
 					Wide:          true,
 					WithNamespace: true,
 					WithKind:      true,
@@ -387,7 +398,7 @@ func (collector *OsmCollector) collectGroundTruth(clientset *kubernetes.Clientse
 			}
 			if err != nil {
 				output = fmt.Sprintf("Error retrieving %s for mesh %s: %+v\n", gvrk.kind, meshName, err)
-				log.Print(output)
+				slog.Warn("Failed to retrieve OSM control plane resources", logging.ComponentKey, collector.GetName(), "mesh", meshName, "kind", gvrk.kind, logging.Error(err))
 			}
 			sb.WriteString(output)
 			sb.WriteString("\n")
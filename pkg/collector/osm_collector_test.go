@@ -45,8 +45,7 @@ func TestOsmCollectorCheckSupported(t *testing.T) {
 		runtimeInfo := &utils.RuntimeInfo{
 			CollectorList: tt.collectors,
 		}
-		c := NewOsmCollector(nil, runtimeInfo)
-		err := c.CheckSupported()
+		err := checkSelected("osm", utils.Linux, runtimeInfo)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("CheckSupported() for %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
@@ -102,7 +101,7 @@ func TestOsmCollectorCollect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.Collect()
+			err := c.Collect(context.Background())
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
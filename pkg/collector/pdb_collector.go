@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -27,6 +26,18 @@ type PDBCollector struct {
 	runtimeInfo *utils.RuntimeInfo
 }
 
+func init() {
+	Register(&Registration{
+		Name:             "poddisruptionbudget",
+		Aliases:          []string{"pdb"},
+		ExcludedProfiles: []string{"connectedCluster"},
+		Scope:            ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewPDBCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewPDBCollector is a constructor
 func NewPDBCollector(config *restclient.Config, runtimeInfo *utils.RuntimeInfo) *PDBCollector {
 	return &PDBCollector{
@@ -41,30 +52,24 @@ func (collector *PDBCollector) GetName() string {
 }
 
 func (collector *PDBCollector) CheckSupported() error {
-	if utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' is in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *PDBCollector) Collect() error {
+func (collector *PDBCollector) Collect(ctx context.Context) error {
 	// Creates the clientset
 	clientset, err := kubernetes.NewForConfig(collector.kubeconfig)
 	if err != nil {
 		return fmt.Errorf("getting access to K8S failed: %w", err)
 	}
 
-	ctxBackground := context.Background()
-
-	namespacesList, err := clientset.CoreV1().Namespaces().List(ctxBackground, metav1.ListOptions{})
+	namespacesList, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("unable to list namespaces in the cluster: %w", err)
 	}
 
 	for _, namespace := range namespacesList.Items {
-		podDistInterface, err := clientset.PolicyV1().PodDisruptionBudgets(namespace.Name).List(ctxBackground, metav1.ListOptions{})
+		podDistInterface, err := clientset.PolicyV1().PodDisruptionBudgets(namespace.Name).List(ctx, metav1.ListOptions{})
 		if err != nil {
 			return fmt.Errorf("listing PDB error: %w", err)
 		}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// PlanFileName is the name of the file a dry run exports its plan to.
+const PlanFileName = "plan.json"
+
+// Target is a concrete item a collector would read, resolved without reading its content.
+type Target struct {
+	// Name is the key the data would be exported under.
+	Name string `json:"name"`
+	// Source is where the data would be read from, e.g. a file path or namespace/pod/container.
+	Source string `json:"source"`
+	// EstimatedSize is the size of the data in bytes, if it can be determined without reading it.
+	EstimatedSize *int64 `json:"estimatedSize,omitempty"`
+	// Error is set if the target was found not to be readable, so that collecting it would fail.
+	Error string `json:"error,omitempty"`
+}
+
+// Planner is implemented by collectors that can resolve their configuration to the concrete targets they would read,
+// without reading any content, for dry runs.
+type Planner interface {
+	Plan(ctx context.Context) ([]*Target, error)
+}
+
+// CollectorPlan describes whether a collector would run, and what it would read.
+type CollectorPlan struct {
+	Name  string `json:"name"`
+	Scope Scope  `json:"scope"`
+	// SkipReason is set if the collector would not run, either because it is not selected or not supported.
+	SkipReason string `json:"skipReason,omitempty"`
+	// Targets are set if the collector would run and resolved its targets. Collectors that don't implement Planner
+	// read a fixed set of data, described in the README.
+	Targets []*Target `json:"targets,omitempty"`
+	// Error is set if the collector's targets could not be resolved.
+	Error string `json:"error,omitempty"`
+	// EstimatedSize is the total size of the targets whose size is known.
+	EstimatedSize int64 `json:"estimatedSize,omitempty"`
+}
+
+// Plan describes what a run would collect, without reading any content or exporting anything other than the plan.
+type Plan struct {
+	RunId        string           `json:"runId"`
+	HostNodeName string           `json:"hostNodeName"`
+	Collectors   []*CollectorPlan `json:"collectors"`
+	// EstimatedSize is the total size of all the targets whose size is known. It is a lower bound, since the size of
+	// most API data (and of collectors that don't implement Planner) is not known in advance.
+	EstimatedSize int64 `json:"estimatedSize"`
+}
+
+// NewPlan evaluates CheckSupported for every selection, and resolves the targets of the collectors that would run,
+// each within its configured timeout.
+func NewPlan(ctx context.Context, runtimeInfo *utils.RuntimeInfo, selections []*Selection) *Plan {
+	plan := &Plan{
+		RunId:        runtimeInfo.RunId,
+		HostNodeName: runtimeInfo.HostNodeName,
+		Collectors:   []*CollectorPlan{},
+	}
+
+	for _, selection := range selections {
+		collectorPlan := &CollectorPlan{Name: selection.Registration.Name, Scope: selection.Registration.GetScope()}
+		plan.Collectors = append(plan.Collectors, collectorPlan)
+
+		if err := selection.CheckSupported(); err != nil {
+			collectorPlan.SkipReason = err.Error()
+			continue
+		}
+
+		planner, ok := selection.Collector.(Planner)
+		if !ok {
+			continue
+		}
+
+		planCtx, cancel := context.WithTimeout(ctx, runtimeInfo.GetCollectorTimeout(collectorPlan.Name))
+		targets, err := planner.Plan(planCtx)
+		cancel()
+		if err != nil {
+			collectorPlan.Error = err.Error()
+		}
+
+		collectorPlan.Targets = targets
+		for _, target := range targets {
+			if target.EstimatedSize != nil {
+				collectorPlan.EstimatedSize += *target.EstimatedSize
+			}
+		}
+		plan.EstimatedSize += collectorPlan.EstimatedSize
+	}
+
+	return plan
+}
+
+// Marshal serializes the plan as formatted JSON.
+func (plan *Plan) Marshal() ([]byte, error) {
+	return json.MarshalIndent(plan, "", "  ")
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// planningCollector is a collector that resolves a fixed set of targets, and fails if its data is read.
+type planningCollector struct {
+	name      string
+	supported error
+	targets   []*Target
+	planErr   error
+}
+
+func (c *planningCollector) GetName() string       { return c.name }
+func (c *planningCollector) CheckSupported() error { return c.supported }
+func (c *planningCollector) Collect(ctx context.Context) error {
+	return errors.New("collect called in a dry run")
+}
+func (c *planningCollector) GetData() map[string]interfaces.DataValue { return nil }
+func (c *planningCollector) Plan(ctx context.Context) ([]*Target, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, errors.New("expected a deadline for planning")
+	}
+	return c.targets, c.planErr
+}
+
+// fixedCollector is a collector that doesn't implement Planner.
+type fixedCollector struct {
+	name string
+}
+
+func (c *fixedCollector) GetName() string                          { return c.name }
+func (c *fixedCollector) CheckSupported() error                    { return nil }
+func (c *fixedCollector) Collect(ctx context.Context) error        { return nil }
+func (c *fixedCollector) GetData() map[string]interfaces.DataValue { return nil }
+
+func TestNewPlan(t *testing.T) {
+	size := func(size int64) *int64 { return &size }
+
+	selections := []*Selection{
+		{
+			Registration: &Registration{Name: "files"},
+			Collector: &planningCollector{name: "files", targets: []*Target{
+				{Name: "var_log_a.log", Source: "/var/log/a.log", EstimatedSize: size(100)},
+				{Name: "var_log_b.log", Source: "/var/log/b.log", Error: "missing"},
+				{Name: "var_log_c.log", Source: "/var/log/c.log", EstimatedSize: size(20)},
+			}},
+		},
+		{
+			Registration: &Registration{Name: "objects", Scope: ClusterScope},
+			Collector: &planningCollector{name: "objects", targets: []*Target{
+				{Name: "ns_pods_a", Source: "ns/pods/a"},
+			}, planErr: errors.New("forbidden")},
+		},
+		{
+			Registration: &Registration{Name: "excluded"},
+			Collector:    &planningCollector{name: "excluded"},
+			SkipReason:   errors.New("excluded by '-excluded' in COLLECTOR_LIST variable"),
+		},
+		{
+			Registration: &Registration{Name: "unsupported"},
+			Collector:    &planningCollector{name: "unsupported", supported: errors.New("unsupported")},
+		},
+		{
+			Registration: &Registration{Name: "fixed"},
+			Collector:    &fixedCollector{name: "fixed"},
+		},
+	}
+
+	plan := NewPlan(context.Background(), &utils.RuntimeInfo{RunId: "run1", HostNodeName: "node1"}, selections)
+	if plan.RunId != "run1" || plan.HostNodeName != "node1" || plan.EstimatedSize != 120 {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+
+	tests := []struct {
+		name          string
+		scope         Scope
+		skipReason    string
+		targets       int
+		err           string
+		estimatedSize int64
+	}{
+		{"files", NodeScope, "", 3, "", 120},
+		{"objects", ClusterScope, "", 1, "forbidden", 0},
+		{"excluded", NodeScope, "excluded by '-excluded' in COLLECTOR_LIST variable", 0, "", 0},
+		{"unsupported", NodeScope, "unsupported", 0, "", 0},
+		{"fixed", NodeScope, "", 0, "", 0},
+	}
+
+	if len(plan.Collectors) != len(tests) {
+		t.Fatalf("expected %d collectors, found %d", len(tests), len(plan.Collectors))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectorPlan := plan.Collectors[i]
+			if collectorPlan.Name != tt.name || collectorPlan.Scope != tt.scope || collectorPlan.SkipReason != tt.skipReason || len(collectorPlan.Targets) != tt.targets || collectorPlan.Error != tt.err || collectorPlan.EstimatedSize != tt.estimatedSize {
+				t.Errorf("unexpected collector plan %+v", collectorPlan)
+			}
+		})
+	}
+
+	content, err := plan.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	unmarshalled := &Plan{}
+	if err := json.Unmarshal(content, unmarshalled); err != nil {
+		t.Fatalf("error unmarshalling plan: %v", err)
+	}
+	if target := unmarshalled.Collectors[0].Targets[1]; target.EstimatedSize != nil || target.Error != "missing" {
+		t.Errorf("unexpected target without a size %+v", target)
+	}
+}
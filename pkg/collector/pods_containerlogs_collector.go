@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"strings"
 	"time"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
@@ -34,7 +33,18 @@ type PodsContainerStruct struct {
 	ContainerLog  string        `json:"containerLog"`
 }
 
-// NewPodsContainerLogs is a constructor
+func init() {
+	Register(&Registration{
+		Name:     "podscontainerlogs",
+		Profiles: []string{"connectedCluster"},
+		Scope:    ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewPodsContainerLogsCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
+// NewPodsContainerLogsCollector is a constructor
 func NewPodsContainerLogsCollector(config *restclient.Config, runtimeInfo *utils.RuntimeInfo) *PodsContainerLogsCollector {
 	return &PodsContainerLogsCollector{
 		data:        make(map[string]string),
@@ -48,15 +58,11 @@ func (collector *PodsContainerLogsCollector) GetName() string {
 }
 
 func (collector *PodsContainerLogsCollector) CheckSupported() error {
-	if !utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' not in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *PodsContainerLogsCollector) Collect() error {
+func (collector *PodsContainerLogsCollector) Collect(ctx context.Context) error {
 	// Creates the clientset
 	clientset, err := kubernetes.NewForConfig(collector.kubeconfig)
 	if err != nil {
@@ -65,7 +71,7 @@ func (collector *PodsContainerLogsCollector) Collect() error {
 
 	for _, namespace := range collector.runtimeInfo.ContainerLogsNamespaces {
 		// List the pods in the given namespace
-		podList, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+		podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 
 		if err != nil {
 			return fmt.Errorf("getting pods failed: %w", err)
@@ -94,7 +100,7 @@ func (collector *PodsContainerLogsCollector) Collect() error {
 			for _, containerItem := range pod.Spec.Containers {
 				containerName := containerItem.Name
 				// Get pods container logs
-				containerLogs, err := getPodContainerLogs(namespace, pod.Name, containerName, clientset)
+				containerLogs, err := getPodContainerLogs(ctx, namespace, pod.Name, containerName, clientset)
 
 				if err != nil {
 					return fmt.Errorf("getting container logs failed: %w", err)
@@ -124,11 +130,40 @@ func (collector *PodsContainerLogsCollector) Collect() error {
 	return nil
 }
 
+// Plan implements the Planner interface, resolving the DIAGNOSTIC_CONTAINERLOGS_LIST namespaces to the containers whose
+// logs would be read. Only the last 100 lines of each log are read, so their size is not known in advance.
+func (collector *PodsContainerLogsCollector) Plan(ctx context.Context) ([]*Target, error) {
+	clientset, err := kubernetes.NewForConfig(collector.kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("getting access to K8S failed: %w", err)
+	}
+
+	targets := []*Target{}
+	for _, namespace := range collector.runtimeInfo.ContainerLogsNamespaces {
+		podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return targets, fmt.Errorf("getting pods failed: %w", err)
+		}
+
+		for _, pod := range podList.Items {
+			for _, container := range pod.Spec.Containers {
+				targets = append(targets, &Target{
+					Name:   pod.Name + "-" + container.Name,
+					Source: fmt.Sprintf("%s/%s/%s", namespace, pod.Name, container.Name),
+				})
+			}
+		}
+	}
+
+	return targets, nil
+}
+
 func (collector *PodsContainerLogsCollector) GetData() map[string]interfaces.DataValue {
 	return utils.ToDataValueMap(collector.data)
 }
 
 func getPodContainerLogs(
+	ctx context.Context,
 	namespace string,
 	podName string,
 	containerName string,
@@ -143,7 +178,7 @@ func getPodContainerLogs(
 	podLogRequest := clientset.CoreV1().
 		Pods(namespace).
 		GetLogs(podName, &podLogOptions)
-	stream, err := podLogRequest.Stream(context.Background())
+	stream, err := podLogRequest.Stream(ctx)
 
 	if err != nil {
 		return "", fmt.Errorf("getting pod logs request failed: %w", err)
@@ -0,0 +1,242 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+	restclient "k8s.io/client-go/rest"
+)
+
+// Dependencies holds everything that may be needed to construct a registered collector.
+// Collectors that only use the Kubernetes API can be created without the file system dependencies.
+type Dependencies struct {
+	OSIdentifier   utils.OSIdentifier
+	KnownFilePaths *utils.KnownFilePaths
+	FileSystem     interfaces.FileSystemAccessor
+	Config         *restclient.Config
+	RuntimeInfo    *utils.RuntimeInfo
+}
+
+// Scope is the extent of the data a collector collects, which determines where it is run.
+type Scope string
+
+const (
+	// NodeScope collectors collect data from the node they run on, and so are run on every node.
+	NodeScope Scope = "node"
+	// ClusterScope collectors only query cluster-wide APIs, and so are run once per cluster.
+	ClusterScope Scope = "cluster"
+)
+
+// Registration describes a collector and the conditions under which it is run.
+type Registration struct {
+	// Name is the name returned by the collector's GetName method, and used in COLLECTOR_LIST include/exclude entries.
+	Name string
+
+	// Aliases are alternative names that can be used in COLLECTOR_LIST include/exclude entries.
+	Aliases []string
+
+	// OSes are the operating systems the collector supports. If empty, all are supported.
+	OSes []utils.OSIdentifier
+
+	// Features must all be enabled for the collector to run.
+	Features []utils.Feature
+
+	// Profiles are the COLLECTOR_LIST profiles, at least one of which must be present for the collector to run.
+	// If empty, the collector runs by default.
+	Profiles []string
+
+	// ExcludedProfiles are the COLLECTOR_LIST profiles that stop the collector from running by default.
+	ExcludedProfiles []string
+
+	// Scope is the extent of the collector's data. If empty, the collector is node-scoped.
+	Scope Scope
+
+	// New creates the collector.
+	New func(deps *Dependencies) interfaces.Collector
+}
+
+var registrations = map[string]*Registration{}
+
+// Register makes a collector available to be selected. It is expected to be called from the init function
+// of the file that defines the collector. Names and aliases are matched case-insensitively.
+func Register(registration *Registration) {
+	for _, name := range append([]string{registration.Name}, registration.Aliases...) {
+		key := strings.ToLower(name)
+		if _, exists := registrations[key]; exists {
+			panic(fmt.Sprintf("collector %s is already registered", name))
+		}
+		registrations[key] = registration
+	}
+}
+
+// GetRegistrations returns all registered collectors, ordered by name.
+func GetRegistrations() []*Registration {
+	result := make([]*Registration, 0, len(registrations))
+	for name, registration := range registrations {
+		if name == strings.ToLower(registration.Name) {
+			result = append(result, registration)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// GetScope returns the extent of the collector's data.
+func (registration *Registration) GetScope() Scope {
+	if registration.Scope == "" {
+		return NodeScope
+	}
+	return registration.Scope
+}
+
+// GetRegistration returns the registered collector with the specified name or alias (in any case), or nil if there is
+// none.
+func GetRegistration(name string) *Registration {
+	return registrations[strings.ToLower(name)]
+}
+
+// collectorList holds the entries of the COLLECTOR_LIST variable. An entry prefixed with '+' or '-' explicitly
+// includes or excludes the named collector, and any other entry is a profile (e.g. 'connectedCluster' or 'OSM').
+// Like the names of collectors, profiles are matched case-insensitively, so the entries are stored in lower case.
+type collectorList struct {
+	values   []string
+	profiles map[string]bool
+	includes map[string]bool
+	excludes map[string]bool
+}
+
+func parseCollectorList(values []string) *collectorList {
+	list := &collectorList{
+		values:   values,
+		profiles: map[string]bool{},
+		includes: map[string]bool{},
+		excludes: map[string]bool{},
+	}
+
+	for _, value := range values {
+		switch {
+		case strings.HasPrefix(value, "+"):
+			list.includes[strings.ToLower(value[1:])] = true
+		case strings.HasPrefix(value, "-"):
+			list.excludes[strings.ToLower(value[1:])] = true
+		default:
+			list.profiles[strings.ToLower(value)] = true
+		}
+	}
+
+	return list
+}
+
+// CheckSelected returns an error describing why the collector should not be run, or nil if it should.
+// OS and feature requirements always apply. After those, an explicit exclude or include entry in
+// COLLECTOR_LIST takes precedence over the collector's profiles.
+func (registration *Registration) CheckSelected(osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo) error {
+	return registration.checkSelected(osIdentifier, runtimeInfo, parseCollectorList(runtimeInfo.CollectorList))
+}
+
+func (registration *Registration) checkSelected(osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo, list *collectorList) error {
+	if len(registration.OSes) > 0 && !containsOS(registration.OSes, osIdentifier) {
+		return fmt.Errorf("unsupported OS: %s", osIdentifier)
+	}
+
+	for _, feature := range registration.Features {
+		if !runtimeInfo.HasFeature(feature) {
+			return fmt.Errorf("feature not set: %s", feature)
+		}
+	}
+
+	for _, name := range append([]string{registration.Name}, registration.Aliases...) {
+		if list.excludes[strings.ToLower(name)] {
+			return fmt.Errorf("excluded by '-%s' in COLLECTOR_LIST variable", name)
+		}
+	}
+
+	for _, name := range append([]string{registration.Name}, registration.Aliases...) {
+		if list.includes[strings.ToLower(name)] {
+			return nil
+		}
+	}
+
+	for _, profile := range registration.ExcludedProfiles {
+		if list.profiles[strings.ToLower(profile)] {
+			return fmt.Errorf("not included because '%s' is in COLLECTOR_LIST variable. Included values: %s", profile, strings.Join(list.values, " "))
+		}
+	}
+
+	if len(registration.Profiles) == 0 {
+		return nil
+	}
+
+	for _, profile := range registration.Profiles {
+		if list.profiles[strings.ToLower(profile)] {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("not included because none of '%s' are in COLLECTOR_LIST variable. Included values: %s", strings.Join(registration.Profiles, "', '"), strings.Join(list.values, " "))
+}
+
+func containsOS(osIdentifiers []utils.OSIdentifier, osIdentifier utils.OSIdentifier) bool {
+	for _, value := range osIdentifiers {
+		if value == osIdentifier {
+			return true
+		}
+	}
+	return false
+}
+
+// Selection is a registered collector, along with the reason it is not being run (if any).
+type Selection struct {
+	Registration *Registration
+	Collector    interfaces.Collector
+	SkipReason   error
+}
+
+// CheckSupported returns the reason the collector was not selected, or otherwise the result of the
+// collector's own check, so that all skip reasons are reported in the same way.
+func (selection *Selection) CheckSupported() error {
+	if selection.SkipReason != nil {
+		return selection.SkipReason
+	}
+	return selection.Collector.CheckSupported()
+}
+
+// Select creates all registered collectors, and determines which of them should be run based on the OS, the enabled
+// features and the COLLECTOR_LIST variable. The selections are returned in name order.
+func Select(deps *Dependencies) []*Selection {
+	list := parseCollectorList(deps.RuntimeInfo.CollectorList)
+	for name := range list.includes {
+		warnIfUnregistered(name)
+	}
+	for name := range list.excludes {
+		warnIfUnregistered(name)
+	}
+
+	selections := []*Selection{}
+	for _, registration := range GetRegistrations() {
+		selections = append(selections, registration.selectFor(deps, list))
+	}
+
+	return selections
+}
+
+func (registration *Registration) selectFor(deps *Dependencies, list *collectorList) *Selection {
+	return &Selection{
+		Registration: registration,
+		Collector:    registration.New(deps),
+		SkipReason:   registration.checkSelected(deps.OSIdentifier, deps.RuntimeInfo, list),
+	}
+}
+
+func warnIfUnregistered(name string) {
+	if GetRegistration(name) == nil {
+		slog.Warn("Ignoring unknown collector in COLLECTOR_LIST variable", logging.ComponentKey, name)
+	}
+}
@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// checkSelected creates the named collector and returns the reason it would be skipped, if any.
+func checkSelected(name string, osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo) error {
+	deps := &Dependencies{
+		OSIdentifier: osIdentifier,
+		RuntimeInfo:  runtimeInfo,
+	}
+	return GetRegistration(name).selectFor(deps, parseCollectorList(runtimeInfo.CollectorList)).CheckSupported()
+}
+
+func TestRegistrationNamesMatchCollectors(t *testing.T) {
+	deps := &Dependencies{RuntimeInfo: &utils.RuntimeInfo{}}
+	for _, registration := range GetRegistrations() {
+		c := registration.New(deps)
+		if c.GetName() != registration.Name {
+			t.Errorf("collector registered as %s has name %s", registration.Name, c.GetName())
+		}
+	}
+}
+
+func TestRegistrationCheckSelected(t *testing.T) {
+	registration := &Registration{
+		Name:             "test",
+		OSes:             []utils.OSIdentifier{utils.Linux},
+		Profiles:         []string{"profileA", "profileB"},
+		ExcludedProfiles: []string{"profileC"},
+	}
+
+	tests := []struct {
+		name          string
+		osIdentifier  utils.OSIdentifier
+		collectorList []string
+		wantErr       bool
+	}{
+		{
+			name:          "no profiles",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{},
+			wantErr:       true,
+		},
+		{
+			name:          "enabling profile",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileB"},
+			wantErr:       false,
+		},
+		{
+			name:          "enabling and excluding profiles",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileA", "profileC"},
+			wantErr:       true,
+		},
+		{
+			name:          "explicitly included",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileC", "+test"},
+			wantErr:       false,
+		},
+		{
+			name:          "explicitly excluded",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileA", "-test"},
+			wantErr:       true,
+		},
+		{
+			name:          "explicitly included on unsupported OS",
+			osIdentifier:  utils.Windows,
+			collectorList: []string{"+test"},
+			wantErr:       true,
+		},
+		{
+			name:          "other collectors included and excluded",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileA", "+other", "-another"},
+			wantErr:       false,
+		},
+		{
+			name:          "profile in a different case",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"PROFILEa"},
+			wantErr:       false,
+		},
+		{
+			name:          "excluding profile in a different case",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileA", "Profilec"},
+			wantErr:       true,
+		},
+		{
+			name:          "explicitly included in a different case",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"+Test"},
+			wantErr:       false,
+		},
+		{
+			name:          "explicitly excluded in a different case",
+			osIdentifier:  utils.Linux,
+			collectorList: []string{"profileA", "-TEST"},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		runtimeInfo := &utils.RuntimeInfo{
+			CollectorList: tt.collectorList,
+		}
+		err := registration.CheckSelected(tt.osIdentifier, runtimeInfo)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGetRegistrationIgnoresCase(t *testing.T) {
+	for _, name := range []string{"osm", "OSM", "pdb", "PDB", "PodDisruptionBudget"} {
+		if registration := GetRegistration(name); registration == nil {
+			t.Errorf("expected registration for %s", name)
+		}
+	}
+
+	// The connectedCluster profile enables helm, whatever its case.
+	runtimeInfo := &utils.RuntimeInfo{CollectorList: []string{"connectedcluster"}}
+	if err := GetRegistration("helm").CheckSelected(utils.Linux, runtimeInfo); err != nil {
+		t.Errorf("expected helm to be selected by 'connectedcluster', found %v", err)
+	}
+}
+
+func TestRegistrationCheckSelectedFeatures(t *testing.T) {
+	registration := &Registration{
+		Name:     "test",
+		Features: []utils.Feature{utils.WindowsHpc},
+	}
+
+	runtimeInfo := &utils.RuntimeInfo{
+		CollectorList: []string{"+test"},
+		Features:      map[utils.Feature]bool{},
+	}
+	if err := registration.CheckSelected(utils.Windows, runtimeInfo); err == nil {
+		t.Errorf("expected error when feature %s is not set", utils.WindowsHpc)
+	}
+
+	runtimeInfo.Features[utils.WindowsHpc] = true
+	if err := registration.CheckSelected(utils.Windows, runtimeInfo); err != nil {
+		t.Errorf("CheckSelected() error = %v", err)
+	}
+}
+
+func TestRegistrationScope(t *testing.T) {
+	clusterScoped := map[string]bool{
+		"helm":                true,
+		"kubeobjects":         true,
+		"osm":                 true,
+		"poddisruptionbudget": true,
+		"podscontainerlogs":   true,
+		"smi":                 true,
+		"systemperf":          true,
+	}
+
+	for _, registration := range GetRegistrations() {
+		wantScope := NodeScope
+		if clusterScoped[registration.Name] {
+			wantScope = ClusterScope
+		}
+		if scope := registration.GetScope(); scope != wantScope {
+			t.Errorf("unexpected scope for %s: expected %s, found %s", registration.Name, wantScope, scope)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	runtimeInfo := &utils.RuntimeInfo{
+		CollectorList: []string{"connectedCluster", "+iptables", "-helm"},
+	}
+
+	selections := Select(&Dependencies{OSIdentifier: utils.Linux, RuntimeInfo: runtimeInfo})
+	if len(selections) != len(GetRegistrations()) {
+		t.Fatalf("unexpected number of selections: expected %d, found %d", len(GetRegistrations()), len(selections))
+	}
+
+	wantSelected := map[string]bool{
+		"dns":                 true,
+		"helm":                false,
+		"iptables":            true,
+		"kubeletcmd":          false,
+		"kubeobjects":         true,
+		"networkoutbound":     true,
+		"nodelogs":            false,
+		"osm":                 false,
+		"poddisruptionbudget": false,
+		"podscontainerlogs":   true,
+		"smi":                 false,
+		"systemlogs":          false,
+		"systemperf":          false,
+		"windowslogs":         false,
+	}
+
+	for _, selection := range selections {
+		want, ok := wantSelected[selection.Registration.Name]
+		if !ok {
+			continue
+		}
+		if (selection.SkipReason == nil) != want {
+			t.Errorf("%s: skip reason = %v, want selected %v", selection.Registration.Name, selection.SkipReason, want)
+		}
+	}
+}
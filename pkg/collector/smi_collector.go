@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -20,6 +21,17 @@ type SmiCollector struct {
 	runtimeInfo   *utils.RuntimeInfo
 }
 
+func init() {
+	Register(&Registration{
+		Name:     "smi",
+		Profiles: []string{"OSM", "SMI"},
+		Scope:    ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewSmiCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewSmiCollector is a constructor
 func NewSmiCollector(config *rest.Config, runtimeInfo *utils.RuntimeInfo) *SmiCollector {
 	return &SmiCollector{
@@ -35,10 +47,6 @@ func (collector *SmiCollector) GetName() string {
 }
 
 func (collector *SmiCollector) CheckSupported() error {
-	if !utils.Contains(collector.runtimeInfo.CollectorList, "OSM") && !utils.Contains(collector.runtimeInfo.CollectorList, "SMI") {
-		return fmt.Errorf("not included because neither 'OSM' or 'SMI' are in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
@@ -47,8 +55,8 @@ func (collector *SmiCollector) GetData() map[string]interfaces.DataValue {
 }
 
 // Collect implements the interface method
-func (collector *SmiCollector) Collect() error {
-	smiCrds, err := collector.getAllSmiCrds()
+func (collector *SmiCollector) Collect(ctx context.Context) error {
+	smiCrds, err := collector.getAllSmiCrds(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting SMI CRDs: %w", err)
 	}
@@ -75,7 +83,7 @@ func (collector *SmiCollector) Collect() error {
 	}
 
 	// Get the resources in all the namespaces for all possible versions of all the CRDs.
-	smiResources, err := collector.getSmiCustomResourcesFromAllNamespaces(gvrs)
+	smiResources, err := collector.getSmiCustomResourcesFromAllNamespaces(ctx, gvrs)
 	if err != nil {
 		return fmt.Errorf("error getting custom SMI resources for all namespaces: %w", err)
 	}
@@ -97,9 +105,9 @@ type smiResource struct {
 	yaml string
 }
 
-func (collector *SmiCollector) getAllSmiCrds() ([]unstructured.Unstructured, error) {
+func (collector *SmiCollector) getAllSmiCrds(ctx context.Context) ([]unstructured.Unstructured, error) {
 	// Get all the CRDs in the cluster (we'll filter them according to a pattern, so can't retrieve them by name).
-	crds, err := collector.commandRunner.GetCRDUnstructuredList()
+	crds, err := collector.commandRunner.GetCRDUnstructuredList(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error listing CRDs in cluster")
 	}
@@ -114,11 +122,11 @@ func (collector *SmiCollector) getAllSmiCrds() ([]unstructured.Unstructured, err
 	return results, nil
 }
 
-func (collector *SmiCollector) getSmiCustomResourcesFromAllNamespaces(gvrs []schema.GroupVersionResource) ([]smiResource, error) {
+func (collector *SmiCollector) getSmiCustomResourcesFromAllNamespaces(ctx context.Context, gvrs []schema.GroupVersionResource) ([]smiResource, error) {
 	result := []smiResource{}
 	for _, gvr := range gvrs {
 		// Find resources in all namespaces
-		resources, err := collector.commandRunner.GetUnstructuredList(&gvr, "", &metav1.ListOptions{})
+		resources, err := collector.commandRunner.GetUnstructuredList(ctx, &gvr, "", &metav1.ListOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("error listing %s resources: %w", gvr.String(), err)
 		}
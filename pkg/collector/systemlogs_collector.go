@@ -1,8 +1,7 @@
 package collector
 
 import (
-	"fmt"
-	"strings"
+	"context"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -10,17 +9,29 @@ import (
 
 // SystemLogsCollector defines a SystemLogs Collector struct
 type SystemLogsCollector struct {
-	data         map[string]string
-	osIdentifier utils.OSIdentifier
-	runtimeInfo  *utils.RuntimeInfo
+	data        map[string]string
+	runtimeInfo *utils.RuntimeInfo
+}
+
+func init() {
+	Register(&Registration{
+		Name: "systemlogs",
+		// This uses `journalctl` to retrieve system logs, which is not available on Windows.
+		// It may be possible in future to identify useful Windows log files and configure this to
+		// output those.
+		OSes:             []utils.OSIdentifier{utils.Linux},
+		ExcludedProfiles: []string{"connectedCluster"},
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewSystemLogsCollector(deps.RuntimeInfo)
+		},
+	})
 }
 
 // NewSystemLogsCollector is a constructor
-func NewSystemLogsCollector(osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo) *SystemLogsCollector {
+func NewSystemLogsCollector(runtimeInfo *utils.RuntimeInfo) *SystemLogsCollector {
 	return &SystemLogsCollector{
-		data:         make(map[string]string),
-		osIdentifier: osIdentifier,
-		runtimeInfo:  runtimeInfo,
+		data:        make(map[string]string),
+		runtimeInfo: runtimeInfo,
 	}
 }
 
@@ -29,26 +40,15 @@ func (collector *SystemLogsCollector) GetName() string {
 }
 
 func (collector *SystemLogsCollector) CheckSupported() error {
-	// This uses `journalctl` to retrieve system logs, which is not available on Windows.
-	// It may be possible in future to identify useful Windows log files and configure this to
-	// output those.
-	if collector.osIdentifier != utils.Linux {
-		return fmt.Errorf("unsupported OS: %s", collector.osIdentifier)
-	}
-
-	if utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' is in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *SystemLogsCollector) Collect() error {
+func (collector *SystemLogsCollector) Collect(ctx context.Context) error {
 	systemServices := []string{"docker", "kubelet"}
 
 	for _, systemService := range systemServices {
-		output, err := utils.RunCommandOnHost("journalctl", "-u", systemService)
+		output, err := utils.RunCommandOnHost(ctx, "journalctl", "-u", systemService)
 		if err != nil {
 			return err
 		}
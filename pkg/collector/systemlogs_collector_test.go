@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -9,7 +10,7 @@ import (
 func TestSystemLogsCollectorGetName(t *testing.T) {
 	const expectedName = "systemlogs"
 
-	c := NewSystemLogsCollector("", nil)
+	c := NewSystemLogsCollector(nil)
 	actualName := c.GetName()
 	if actualName != expectedName {
 		t.Errorf("unexpected name: expected %s, found %s", expectedName, actualName)
@@ -47,8 +48,7 @@ func TestSystemLogsCollectorCheckSupported(t *testing.T) {
 		runtimeInfo := &utils.RuntimeInfo{
 			CollectorList: tt.collectorList,
 		}
-		c := NewSystemLogsCollector(tt.osIdentifier, runtimeInfo)
-		err := c.CheckSupported()
+		err := checkSelected("systemlogs", tt.osIdentifier, runtimeInfo)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
@@ -72,11 +72,11 @@ func TestSystemLogsCollectorCollect(t *testing.T) {
 		CollectorList: []string{},
 	}
 
-	c := NewSystemLogsCollector(utils.Linux, runtimeInfo)
+	c := NewSystemLogsCollector(runtimeInfo)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.Collect()
+			err := c.Collect(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
 			}
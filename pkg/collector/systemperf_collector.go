@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/Azure/aks-periscope/pkg/utils"
@@ -32,6 +31,17 @@ type PodMetrics struct {
 	MemoryUsage   int64  `json:"memoryUsage"`
 }
 
+func init() {
+	Register(&Registration{
+		Name:             "systemperf",
+		ExcludedProfiles: []string{"connectedCluster"},
+		Scope:            ClusterScope,
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewSystemPerfCollector(deps.Config, deps.RuntimeInfo)
+		},
+	})
+}
+
 // NewSystemPerfCollector is a constructor
 func NewSystemPerfCollector(config *restclient.Config, runtimeInfo *utils.RuntimeInfo) *SystemPerfCollector {
 	return &SystemPerfCollector{
@@ -46,21 +56,17 @@ func (collector *SystemPerfCollector) GetName() string {
 }
 
 func (collector *SystemPerfCollector) CheckSupported() error {
-	if utils.Contains(collector.runtimeInfo.CollectorList, "connectedCluster") {
-		return fmt.Errorf("not included because 'connectedCluster' is in COLLECTOR_LIST variable. Included values: %s", strings.Join(collector.runtimeInfo.CollectorList, " "))
-	}
-
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *SystemPerfCollector) Collect() error {
+func (collector *SystemPerfCollector) Collect(ctx context.Context) error {
 	metric, err := metrics.NewForConfig(collector.kubeconfig)
 	if err != nil {
 		return fmt.Errorf("metrics for config error: %w", err)
 	}
 
-	nodeMetrics, err := metric.MetricsV1beta1().NodeMetricses().List(context.TODO(), metav1.ListOptions{})
+	nodeMetrics, err := metric.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("node metrics error: %w", err)
 	}
@@ -89,7 +95,7 @@ func (collector *SystemPerfCollector) Collect() error {
 
 	collector.data["nodes"] = string(jsonNodeResult)
 
-	podMetrics, err := metric.MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	podMetrics, err := metric.MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("pod metrics failure: %w", err)
 	}
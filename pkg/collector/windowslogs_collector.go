@@ -17,7 +17,6 @@ const windowsLogsCollectorPrefix = "collect-windows-logs/"
 
 type WindowsLogsCollector struct {
 	data         map[string]interfaces.DataValue
-	osIdentifier utils.OSIdentifier
 	runtimeInfo  *utils.RuntimeInfo
 	filePaths    *utils.KnownFilePaths
 	fileSystem   interfaces.FileSystemAccessor
@@ -25,10 +24,24 @@ type WindowsLogsCollector struct {
 	timeout      time.Duration
 }
 
-func NewWindowsLogsCollector(osIdentifier utils.OSIdentifier, runtimeInfo *utils.RuntimeInfo, filePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor, pollInterval, timeout time.Duration) *WindowsLogsCollector {
+func init() {
+	Register(&Registration{
+		Name: "windowslogs",
+		// This is specifically for Windows.
+		OSes: []utils.OSIdentifier{utils.Windows},
+		// Even for Windows, this is only supported on kubernetes v1.23 or higher. It is up to consumers
+		// to deploy the resources needed to support this. To ensure consumers have explicitly specified
+		// this to run, we check for a well-known runtime variable.
+		Features: []utils.Feature{utils.WindowsHpc},
+		New: func(deps *Dependencies) interfaces.Collector {
+			return NewWindowsLogsCollector(deps.RuntimeInfo, deps.KnownFilePaths, deps.FileSystem, 10*time.Second, 20*time.Minute)
+		},
+	})
+}
+
+func NewWindowsLogsCollector(runtimeInfo *utils.RuntimeInfo, filePaths *utils.KnownFilePaths, fileSystem interfaces.FileSystemAccessor, pollInterval, timeout time.Duration) *WindowsLogsCollector {
 	return &WindowsLogsCollector{
 		data:         make(map[string]interfaces.DataValue),
-		osIdentifier: osIdentifier,
 		runtimeInfo:  runtimeInfo,
 		filePaths:    filePaths,
 		fileSystem:   fileSystem,
@@ -42,34 +55,27 @@ func (collector *WindowsLogsCollector) GetName() string {
 }
 
 func (collector *WindowsLogsCollector) CheckSupported() error {
-	// This is specifically for Windows.
-	if collector.osIdentifier != utils.Windows {
-		return fmt.Errorf("unsupported OS: %s", collector.osIdentifier)
-	}
-
-	// Even for Windows, this is only supported on kubernetes v1.23 or higher. It is up to consumers
-	// to deploy the resources needed to support this. To ensure consumers have explicitly specified
-	// this to run, we check for a well-known runtime variable.
-	if !collector.runtimeInfo.HasFeature(utils.WindowsHpc) {
-		return fmt.Errorf("feature not set: %s", utils.WindowsHpc)
-	}
-
 	// This relies on us having a known 'run ID'.
 	if len(collector.runtimeInfo.RunId) == 0 {
 		return errors.New("diagnostic run ID not set")
 	}
 
+	// The separate process exporting the logs is only triggered by a change to the configured run ID.
+	if collector.runtimeInfo.Scheduled {
+		return errors.New("not supported for scheduled runs")
+	}
+
 	return nil
 }
 
 // Collect implements the interface method
-func (collector *WindowsLogsCollector) Collect() error {
+func (collector *WindowsLogsCollector) Collect(ctx context.Context) error {
 	// Exporting the logs is done by a separate process, which will place an empty file in a known
 	// location to indicate completion. The name of that file is the current 'run ID'.
 	completionNotificationPath := path.Join(collector.filePaths.WindowsLogsOutput, collector.runtimeInfo.RunId)
 
 	// Poll to check existence of this file.
-	err := wait.PollUntilContextTimeout(context.Background(), collector.pollInterval, collector.timeout, false,
+	err := wait.PollUntilContextTimeout(ctx, collector.pollInterval, collector.timeout, false,
 		func(context.Context) (bool, error) {
 			return collector.fileSystem.FileExists(completionNotificationPath)
 		})
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -12,7 +13,7 @@ import (
 func TestWindowsLogsCollectorGetName(t *testing.T) {
 	const expectedName = "windowslogs"
 
-	c := NewWindowsLogsCollector("", nil, nil, nil, 0, 0)
+	c := NewWindowsLogsCollector(nil, nil, nil, 0, 0)
 	actualName := c.GetName()
 	if actualName != expectedName {
 		t.Errorf("unexpected name: expected %s, found %s", expectedName, actualName)
@@ -23,6 +24,7 @@ func TestWindowsLogsCollectorCheckSupported(t *testing.T) {
 	tests := []struct {
 		name         string
 		runId        string
+		scheduled    bool
 		features     []utils.Feature
 		osIdentifier utils.OSIdentifier
 		wantErr      bool
@@ -48,6 +50,14 @@ func TestWindowsLogsCollectorCheckSupported(t *testing.T) {
 			osIdentifier: utils.Linux,
 			wantErr:      true,
 		},
+		{
+			name:         "Scheduled run",
+			runId:        "this_run",
+			scheduled:    true,
+			features:     []utils.Feature{utils.WindowsHpc},
+			osIdentifier: utils.Windows,
+			wantErr:      true,
+		},
 		{
 			name:         "Supported",
 			runId:        "this_run",
@@ -60,15 +70,15 @@ func TestWindowsLogsCollectorCheckSupported(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			runtimeInfo := &utils.RuntimeInfo{
-				RunId:    tt.runId,
-				Features: map[utils.Feature]bool{},
+				RunId:     tt.runId,
+				Scheduled: tt.scheduled,
+				Features:  map[utils.Feature]bool{},
 			}
 			for _, feature := range tt.features {
 				runtimeInfo.Features[feature] = true
 			}
 
-			c := NewWindowsLogsCollector(tt.osIdentifier, runtimeInfo, nil, nil, 0, 0)
-			err := c.CheckSupported()
+			err := checkSelected("windowslogs", tt.osIdentifier, runtimeInfo)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckSupported() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -152,7 +162,7 @@ func TestWindowsLogsCollectorCollect(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			fs := test.NewFakeFileSystem(map[string]string{})
 
-			c := NewWindowsLogsCollector(utils.Windows, runtimeInfo, filePaths, fs, time.Microsecond, time.Second)
+			c := NewWindowsLogsCollector(runtimeInfo, filePaths, fs, time.Microsecond, time.Second)
 
 			for path, content := range tt.exportedFiles {
 				fs.AddOrUpdateFile(path, content)
@@ -162,7 +172,7 @@ func TestWindowsLogsCollectorCollect(t *testing.T) {
 				fs.SetFileAccessError(path, fmt.Errorf("expected error accessing %s", path))
 			}
 
-			err := c.Collect()
+			err := c.Collect(context.Background())
 
 			if err != nil {
 				if !tt.wantErr {
@@ -1,12 +1,12 @@
 package diagnoser
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 
-	"github.com/Azure/aks-periscope/pkg/collector"
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/Azure/aks-periscope/pkg/utils"
 )
@@ -21,19 +21,37 @@ type networkConfigDiagnosticDatum struct {
 
 // NetworkConfigDiagnoser defines a NetworkConfig Diagnoser struct
 type NetworkConfigDiagnoser struct {
-	runtimeInfo         *utils.RuntimeInfo
-	dnsCollector        *collector.DNSCollector
-	kubeletCmdCollector *collector.KubeletCmdCollector
-	data                map[string]string
+	runtimeInfo    *utils.RuntimeInfo
+	dnsConfig      interfaces.DNSConfigOutput
+	kubeletCommand interfaces.KubeletCommandOutput
+	data           map[string]string
+}
+
+func init() {
+	Register(&Registration{
+		Name:         "networkconfig",
+		Dependencies: []string{"dns", "kubeletcmd"},
+		New: func(runtimeInfo *utils.RuntimeInfo, collectors map[string]interfaces.Collector) (interfaces.Diagnoser, error) {
+			dnsConfig, err := getDependency[interfaces.DNSConfigOutput](collectors, "dns")
+			if err != nil {
+				return nil, err
+			}
+			kubeletCommand, err := getDependency[interfaces.KubeletCommandOutput](collectors, "kubeletcmd")
+			if err != nil {
+				return nil, err
+			}
+			return NewNetworkConfigDiagnoser(runtimeInfo, dnsConfig, kubeletCommand), nil
+		},
+	})
 }
 
 // NewNetworkConfigDiagnoser is a constructor
-func NewNetworkConfigDiagnoser(runtimeInfo *utils.RuntimeInfo, dnsCollector *collector.DNSCollector, kubeletCmdCollector *collector.KubeletCmdCollector) *NetworkConfigDiagnoser {
+func NewNetworkConfigDiagnoser(runtimeInfo *utils.RuntimeInfo, dnsConfig interfaces.DNSConfigOutput, kubeletCommand interfaces.KubeletCommandOutput) *NetworkConfigDiagnoser {
 	return &NetworkConfigDiagnoser{
-		runtimeInfo:         runtimeInfo,
-		dnsCollector:        dnsCollector,
-		kubeletCmdCollector: kubeletCmdCollector,
-		data:                make(map[string]string),
+		runtimeInfo:    runtimeInfo,
+		dnsConfig:      dnsConfig,
+		kubeletCommand: kubeletCommand,
+		data:           make(map[string]string),
 	}
 }
 
@@ -42,13 +60,13 @@ func (collector *NetworkConfigDiagnoser) GetName() string {
 }
 
 // Diagnose implements the interface method
-func (diagnoser *NetworkConfigDiagnoser) Diagnose() error {
+func (diagnoser *NetworkConfigDiagnoser) Diagnose(ctx context.Context) error {
 	networkConfigDiagnosticData := networkConfigDiagnosticDatum{HostName: diagnoser.runtimeInfo.HostNodeName}
 
-	networkConfigDiagnosticData.VirtualMachineDNS = diagnoser.getDns(diagnoser.dnsCollector.HostConf)
-	networkConfigDiagnosticData.KubernetesDNS = diagnoser.getDns(diagnoser.dnsCollector.ContainerConf)
+	networkConfigDiagnosticData.VirtualMachineDNS = diagnoser.getDns(diagnoser.dnsConfig.GetHostConf())
+	networkConfigDiagnosticData.KubernetesDNS = diagnoser.getDns(diagnoser.dnsConfig.GetContainerConf())
 
-	parts := strings.Split(diagnoser.kubeletCmdCollector.KubeletCommand, " ")
+	parts := strings.Split(diagnoser.kubeletCommand.GetKubeletCommand(), " ")
 	for _, part := range parts {
 		if strings.HasPrefix(part, "--network-plugin=") {
 			networkPlugin := part[17:]
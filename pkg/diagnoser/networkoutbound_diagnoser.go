@@ -1,15 +1,17 @@
 package diagnoser
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/Azure/aks-periscope/pkg/collector"
 	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
 	"github.com/Azure/aks-periscope/pkg/utils"
 )
 
@@ -23,17 +25,32 @@ type networkOutboundDiagnosticDatum struct {
 
 // NetworkOutboundDiagnoser defines a NetworkOutbound Diagnoser struct
 type NetworkOutboundDiagnoser struct {
-	runtimeInfo              *utils.RuntimeInfo
-	networkOutboundCollector *collector.NetworkOutboundCollector
-	data                     map[string]string
+	runtimeInfo     *utils.RuntimeInfo
+	networkOutbound interfaces.DataProducer
+	data            map[string]string
 }
 
-// NewNetworkOutboundDiagnoser is a constructor
-func NewNetworkOutboundDiagnoser(runtimeInfo *utils.RuntimeInfo, networkOutboundCollector *collector.NetworkOutboundCollector) *NetworkOutboundDiagnoser {
+func init() {
+	Register(&Registration{
+		Name:         "networkoutbound",
+		Dependencies: []string{"networkoutbound"},
+		New: func(runtimeInfo *utils.RuntimeInfo, collectors map[string]interfaces.Collector) (interfaces.Diagnoser, error) {
+			networkOutbound, err := getDependency[interfaces.DataProducer](collectors, "networkoutbound")
+			if err != nil {
+				return nil, err
+			}
+			return NewNetworkOutboundDiagnoser(runtimeInfo, networkOutbound), nil
+		},
+	})
+}
+
+// NewNetworkOutboundDiagnoser is a constructor. The output of the network outbound collector
+// is read from its data values, which contain serialized NetworkOutboundDatum entries.
+func NewNetworkOutboundDiagnoser(runtimeInfo *utils.RuntimeInfo, networkOutbound interfaces.DataProducer) *NetworkOutboundDiagnoser {
 	return &NetworkOutboundDiagnoser{
-		runtimeInfo:              runtimeInfo,
-		networkOutboundCollector: networkOutboundCollector,
-		data:                     make(map[string]string),
+		runtimeInfo:     runtimeInfo,
+		networkOutbound: networkOutbound,
+		data:            make(map[string]string),
 	}
 }
 
@@ -42,10 +59,10 @@ func (collector *NetworkOutboundDiagnoser) GetName() string {
 }
 
 // Diagnose implements the interface method
-func (diagnoser *NetworkOutboundDiagnoser) Diagnose() error {
+func (diagnoser *NetworkOutboundDiagnoser) Diagnose(ctx context.Context) error {
 	outboundDiagnosticData := []networkOutboundDiagnosticDatum{}
 
-	for _, value := range diagnoser.networkOutboundCollector.GetData() {
+	for _, value := range diagnoser.networkOutbound.GetData() {
 		dataPoint := networkOutboundDiagnosticDatum{HostName: diagnoser.runtimeInfo.HostNodeName}
 
 		// TODO: We could read this directly from the collector object, rather than deserializing it from the output.
@@ -57,7 +74,7 @@ func (diagnoser *NetworkOutboundDiagnoser) Diagnose() error {
 		data, err := utils.GetContent(func() (io.ReadCloser, error) { return value.GetReader() })
 
 		if err != nil {
-			log.Printf("Retrieving data failed: %v", err)
+			slog.Warn("Retrieving data failed", logging.ComponentKey, diagnoser.GetName(), logging.Error(err))
 			continue
 		}
 
@@ -66,7 +83,7 @@ func (diagnoser *NetworkOutboundDiagnoser) Diagnose() error {
 			var outboundDatum collector.NetworkOutboundDatum
 			err := json.Unmarshal([]byte(line), &outboundDatum)
 			if err != nil {
-				log.Printf("Unmarshal failed: %v", err)
+				slog.Warn("Unmarshal failed", logging.ComponentKey, diagnoser.GetName(), logging.Error(err))
 				continue
 			}
 
@@ -0,0 +1,63 @@
+package diagnoser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// Registration describes a diagnoser and the collectors it depends on.
+type Registration struct {
+	// Name is the name returned by the diagnoser's GetName method.
+	Name string
+
+	// Dependencies are the names of the collectors whose output the diagnoser reads. The diagnoser is run once
+	// all of these have completed, and only if they all succeeded.
+	Dependencies []string
+
+	// New creates the diagnoser from its dependencies, which are passed by name. Only collectors that
+	// succeeded are included.
+	New func(runtimeInfo *utils.RuntimeInfo, collectors map[string]interfaces.Collector) (interfaces.Diagnoser, error)
+}
+
+var registrations = map[string]*Registration{}
+
+// Register makes a diagnoser available to be run. It is expected to be called from the init function
+// of the file that defines the diagnoser.
+func Register(registration *Registration) {
+	if _, exists := registrations[registration.Name]; exists {
+		panic(fmt.Sprintf("diagnoser %s is already registered", registration.Name))
+	}
+	registrations[registration.Name] = registration
+}
+
+// GetRegistrations returns all registered diagnosers, ordered by name.
+func GetRegistrations() []*Registration {
+	result := make([]*Registration, 0, len(registrations))
+	for _, registration := range registrations {
+		result = append(result, registration)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// getDependency returns the named collector as the output type the diagnoser needs, or an error if the collector
+// did not succeed or does not provide that type of output.
+func getDependency[T any](collectors map[string]interfaces.Collector, name string) (T, error) {
+	var output T
+	c, ok := collectors[name]
+	if !ok {
+		return output, fmt.Errorf("collector %s was not run successfully", name)
+	}
+
+	output, ok = c.(T)
+	if !ok {
+		return output, fmt.Errorf("collector %s does not provide %T output", name, (*T)(nil))
+	}
+
+	return output, nil
+}
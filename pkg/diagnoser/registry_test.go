@@ -0,0 +1,108 @@
+package diagnoser
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+type testCollector struct {
+	name string
+}
+
+func (c *testCollector) GetName() string                          { return c.name }
+func (c *testCollector) CheckSupported() error                    { return nil }
+func (c *testCollector) Collect(ctx context.Context) error        { return nil }
+func (c *testCollector) GetData() map[string]interfaces.DataValue { return nil }
+
+type testDNSCollector struct {
+	testCollector
+}
+
+func (c *testDNSCollector) GetHostConf() string      { return "nameserver 168.63.129.16\n" }
+func (c *testDNSCollector) GetContainerConf() string { return "nameserver 10.0.0.10\n" }
+
+type testKubeletCmdCollector struct {
+	testCollector
+}
+
+func (c *testKubeletCmdCollector) GetKubeletCommand() string {
+	return "/usr/local/bin/kubelet --network-plugin=cni --max-pods=30"
+}
+
+func TestRegistrationsHaveDependencies(t *testing.T) {
+	for _, registration := range GetRegistrations() {
+		if len(registration.Dependencies) == 0 {
+			t.Errorf("diagnoser %s has no dependencies", registration.Name)
+		}
+	}
+}
+
+func TestNetworkConfigDiagnoserRegistration(t *testing.T) {
+	registration := registrations["networkconfig"]
+	runtimeInfo := &utils.RuntimeInfo{HostNodeName: "node1"}
+
+	tests := []struct {
+		name       string
+		collectors map[string]interfaces.Collector
+		wantErr    bool
+	}{
+		{
+			name:       "no collectors succeeded",
+			collectors: map[string]interfaces.Collector{},
+			wantErr:    true,
+		},
+		{
+			name: "kubeletcmd not succeeded",
+			collectors: map[string]interfaces.Collector{
+				"dns": &testDNSCollector{testCollector{"dns"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dns collector without DNS config output",
+			collectors: map[string]interfaces.Collector{
+				"dns":        &testCollector{"dns"},
+				"kubeletcmd": &testKubeletCmdCollector{testCollector{"kubeletcmd"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "all dependencies succeeded",
+			collectors: map[string]interfaces.Collector{
+				"dns":        &testDNSCollector{testCollector{"dns"}},
+				"kubeletcmd": &testKubeletCmdCollector{testCollector{"kubeletcmd"}},
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		d, err := registration.New(runtimeInfo, tt.collectors)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if err := d.Diagnose(context.Background()); err != nil {
+			t.Errorf("%s Diagnose() error = %v", tt.name, err)
+			continue
+		}
+
+		const expected = `{"HostName":"node1","NetworkPlugin":"azurecni","VirtualMachineDNS":["168.63.129.16"],"KubernetesDNS":["10.0.0.10"],"MaxPodsPerNode":30}`
+		value, err := utils.GetContent(func() (io.ReadCloser, error) { return d.GetData()["networkconfig"].GetReader() })
+		if err != nil {
+			t.Errorf("%s error reading data: %v", tt.name, err)
+			continue
+		}
+		if value != expected {
+			t.Errorf("%s unexpected data.\nExpected '%s'\nFound '%s'", tt.name, expected, value)
+		}
+	}
+}
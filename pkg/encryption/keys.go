@@ -0,0 +1,94 @@
+package encryption
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Recipient is a public key that can decrypt the data encrypted for it.
+type Recipient struct {
+	// Id identifies the key, so that the holder of the private key can find the data key encrypted for them.
+	// It is the hex-encoded SHA-256 hash of the public key's PKIX (SubjectPublicKeyInfo) encoding.
+	Id        string
+	Subject   string
+	publicKey *rsa.PublicKey
+}
+
+// ParseRecipients reads the PEM-encoded X.509 certificates of the recipients. Only RSA keys are supported.
+func ParseRecipients(pemData []byte) ([]*Recipient, error) {
+	recipients := []*Recipient{}
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+
+		publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("certificate %s has unsupported public key type %T (only RSA is supported)", certificate.Subject, certificate.PublicKey)
+		}
+
+		id, err := getKeyId(publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, &Recipient{Id: id, Subject: certificate.Subject.String(), publicKey: publicKey})
+	}
+
+	if len(recipients) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+
+	return recipients, nil
+}
+
+// ParsePrivateKey reads a PEM-encoded RSA private key, in either PKCS #1 or PKCS #8 form.
+func ParsePrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			return nil, errors.New("no private key found")
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T (only RSA is supported)", key)
+			}
+			return rsaKey, nil
+		}
+	}
+}
+
+func getKeyId(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("marshal public key: %w", err)
+	}
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,274 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted data has the following format:
+//
+//	magic        "periscope-encrypted/v1\n"
+//	header size  uint32, big-endian
+//	header       JSON, containing the data key encrypted for each recipient (using RSA-OAEP with SHA-256)
+//	chunks       each chunk of up to ChunkSize bytes of data, encrypted using AES-256-GCM with the data key:
+//	               final    byte, 1 for the last chunk and 0 otherwise
+//	               size     uint32, big-endian, the size of the encrypted chunk
+//	               content  the encrypted chunk, including the GCM tag
+//
+// Each chunk's nonce is its sequence number followed by its final flag, so chunks can't be reordered, and the data
+// can't be truncated without detection. A new random data key is generated for every encrypted stream.
+const (
+	magic          = "periscope-encrypted/v1\n"
+	ChunkSize      = 64 * 1024
+	maxHeaderSize  = 1024 * 1024
+	dataKeySize    = 32
+	keyAlgorithm   = "RSA-OAEP-SHA256"
+	dataAlgorithm  = "AES-256-GCM"
+	FileExtension  = ".enc"
+	chunkFrameSize = 5
+)
+
+type header struct {
+	Algorithm  string             `json:"algorithm"`
+	ChunkSize  int                `json:"chunkSize"`
+	Recipients []*recipientHeader `json:"recipients"`
+}
+
+type recipientHeader struct {
+	Id           string `json:"id"`
+	Subject      string `json:"subject,omitempty"`
+	Algorithm    string `json:"algorithm"`
+	EncryptedKey []byte `json:"encryptedKey"`
+}
+
+// NewEncryptingReader returns a reader of the encrypted content of the source, which can be decrypted by any of the
+// recipients. The source is read (and encrypted) as the returned reader is read.
+func NewEncryptingReader(source io.Reader, recipients []*Recipient) (io.Reader, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+
+	h := &header{Algorithm: dataAlgorithm, ChunkSize: ChunkSize}
+	for _, recipient := range recipients {
+		encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, recipient.publicKey, dataKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt data key for %s: %w", recipient.Subject, err)
+		}
+		h.Recipients = append(h.Recipients, &recipientHeader{
+			Id:           recipient.Id,
+			Subject:      recipient.Subject,
+			Algorithm:    keyAlgorithm,
+			EncryptedKey: encryptedKey,
+		})
+	}
+
+	headerContent, err := json.Marshal(h)
+	if err != nil {
+		return nil, fmt.Errorf("serialize header: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := bytes.NewBufferString(magic)
+	_ = binary.Write(pending, binary.BigEndian, uint32(len(headerContent)))
+	pending.Write(headerContent)
+
+	return &encryptingReader{
+		source:  source,
+		aead:    aead,
+		chunk:   make([]byte, ChunkSize),
+		pending: pending.Bytes(),
+	}, nil
+}
+
+// NewDecryptingReader returns a reader of the decrypted content of the source, which must have been encrypted for
+// the public key of the private key. An error is returned when reading if the data has been modified or truncated.
+func NewDecryptingReader(source io.Reader, privateKey *rsa.PrivateKey) (io.Reader, error) {
+	prefix := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(source, prefix); err != nil || string(prefix[:len(magic)]) != magic {
+		return nil, errors.New("data is not in the Periscope encrypted format")
+	}
+
+	headerSize := binary.BigEndian.Uint32(prefix[len(magic):])
+	if headerSize > maxHeaderSize {
+		return nil, fmt.Errorf("invalid header size %d", headerSize)
+	}
+
+	headerContent := make([]byte, headerSize)
+	if _, err := io.ReadFull(source, headerContent); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	h := &header{}
+	if err := json.Unmarshal(headerContent, h); err != nil {
+		return nil, fmt.Errorf("parse header: %w", err)
+	}
+	if h.Algorithm != dataAlgorithm || h.ChunkSize <= 0 || h.ChunkSize > ChunkSize {
+		return nil, fmt.Errorf("unsupported algorithm %s or chunk size %d", h.Algorithm, h.ChunkSize)
+	}
+
+	id, err := getKeyId(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataKey []byte
+	for _, recipient := range h.Recipients {
+		if recipient.Id == id && recipient.Algorithm == keyAlgorithm {
+			dataKey, err = rsa.DecryptOAEP(sha256.New(), nil, privateKey, recipient.EncryptedKey, nil)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt data key: %w", err)
+			}
+			break
+		}
+	}
+	if dataKey == nil {
+		return nil, errors.New("data was not encrypted for this key")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		source:       source,
+		aead:         aead,
+		maxChunkSize: h.ChunkSize + aead.Overhead(),
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// getNonce returns the nonce for the chunk with the specified sequence number.
+func getNonce(aead cipher.AEAD, counter uint64, final bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type encryptingReader struct {
+	source  io.Reader
+	aead    cipher.AEAD
+	chunk   []byte
+	pending []byte
+	counter uint64
+	done    bool
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.source, r.chunk)
+		final := false
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			final = true
+		default:
+			return 0, err
+		}
+
+		sealed := r.aead.Seal(nil, getNonce(r.aead, r.counter, final), r.chunk[:n], nil)
+		frame := make([]byte, chunkFrameSize, chunkFrameSize+len(sealed))
+		if final {
+			frame[0] = 1
+		}
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(sealed)))
+
+		r.pending = append(frame, sealed...)
+		r.counter++
+		r.done = final
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+type decryptingReader struct {
+	source       io.Reader
+	aead         cipher.AEAD
+	maxChunkSize int
+	pending      []byte
+	counter      uint64
+	done         bool
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		frame := make([]byte, chunkFrameSize)
+		if _, err := io.ReadFull(r.source, frame); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return 0, errors.New("encrypted data is truncated")
+			}
+			return 0, err
+		}
+
+		final := frame[0] == 1
+		size := int(binary.BigEndian.Uint32(frame[1:]))
+		if frame[0] > 1 || size > r.maxChunkSize {
+			return 0, fmt.Errorf("invalid chunk %d", r.counter)
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(r.source, sealed); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return 0, errors.New("encrypted data is truncated")
+			}
+			return 0, err
+		}
+
+		chunk, err := r.aead.Open(nil, getNonce(r.aead, r.counter, final), sealed, nil)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt chunk %d: data has been modified or corrupted", r.counter)
+		}
+
+		if final {
+			if n, _ := r.source.Read(make([]byte, 1)); n > 0 {
+				return 0, errors.New("unexpected data after the final chunk")
+			}
+		}
+
+		r.pending = chunk
+		r.counter++
+		r.done = final
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,172 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// createTestKey creates an RSA key, and a PEM-encoded self-signed certificate for it.
+func createTestKey(t *testing.T, name string) (*rsa.PrivateKey, []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	return privateKey, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encrypt(t *testing.T, data []byte, recipients []*Recipient) []byte {
+	reader, err := NewEncryptingReader(bytes.NewReader(data), recipients)
+	if err != nil {
+		t.Fatalf("error creating encrypting reader: %v", err)
+	}
+
+	encrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error encrypting: %v", err)
+	}
+	return encrypted
+}
+
+func decrypt(encrypted []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	reader, err := NewDecryptingReader(bytes.NewReader(encrypted), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	privateKey1, certificate1 := createTestKey(t, "support1")
+	privateKey2, certificate2 := createTestKey(t, "support2")
+
+	recipients, err := ParseRecipients(append(certificate1, certificate2...))
+	if err != nil {
+		t.Fatalf("error parsing recipients: %v", err)
+	}
+	if len(recipients) != 2 || recipients[0].Subject != "CN=support1" {
+		t.Fatalf("unexpected recipients: %+v", recipients)
+	}
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "small", size: 100},
+		{name: "exactly one chunk", size: ChunkSize},
+		{name: "multiple chunks", size: 3*ChunkSize + 17},
+	}
+
+	for _, tt := range tests {
+		data := make([]byte, tt.size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatalf("error generating data: %v", err)
+		}
+
+		encrypted := encrypt(t, data, recipients)
+		if tt.size > 0 && bytes.Contains(encrypted, data) {
+			t.Errorf("%s: encrypted data contains the plain data", tt.name)
+		}
+
+		for _, privateKey := range []*rsa.PrivateKey{privateKey1, privateKey2} {
+			decrypted, err := decrypt(encrypted, privateKey)
+			if err != nil {
+				t.Errorf("%s: error decrypting: %v", tt.name, err)
+				continue
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("%s: decrypted data does not match (expected %d bytes, found %d)", tt.name, len(data), len(decrypted))
+			}
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	privateKey, certificate := createTestKey(t, "support")
+	otherPrivateKey, _ := createTestKey(t, "other")
+
+	recipients, err := ParseRecipients(certificate)
+	if err != nil {
+		t.Fatalf("error parsing recipients: %v", err)
+	}
+
+	encrypted := encrypt(t, bytes.Repeat([]byte("node logs\n"), ChunkSize/5), recipients)
+
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)-100] ^= 1
+
+	tests := []struct {
+		name       string
+		data       []byte
+		privateKey *rsa.PrivateKey
+	}{
+		{name: "not encrypted", data: []byte("plain text"), privateKey: privateKey},
+		{name: "wrong key", data: encrypted, privateKey: otherPrivateKey},
+		{name: "modified", data: tampered, privateKey: privateKey},
+		{name: "truncated", data: encrypted[:len(encrypted)-50], privateKey: privateKey},
+		// The data is exactly two chunks, so the final chunk is empty, consisting of just the frame and the GCM tag.
+		{name: "final chunk removed", data: encrypted[:len(encrypted)-chunkFrameSize-16], privateKey: privateKey},
+		{name: "trailing data", data: append(append([]byte(nil), encrypted...), 0), privateKey: privateKey},
+	}
+
+	for _, tt := range tests {
+		if _, err := decrypt(tt.data, tt.privateKey); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	privateKey, certificate := createTestKey(t, "support")
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		pemData []byte
+		wantErr bool
+	}{
+		{name: "PKCS #1", pemData: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})},
+		{name: "PKCS #8", pemData: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+		{name: "certificate only", pemData: certificate, wantErr: true},
+		{name: "not PEM", pemData: []byte("key"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		parsed, err := ParsePrivateKey(tt.pemData)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !parsed.Equal(privateKey) {
+			t.Errorf("%s: parsed key does not match", tt.name)
+		}
+	}
+
+	if _, err := ParseRecipients([]byte("no certificates")); err == nil {
+		t.Errorf("expected error parsing recipients without certificates")
+	}
+}
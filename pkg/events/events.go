@@ -0,0 +1,106 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Component is the source component of the Events.
+const Component = "aks-periscope"
+
+// Reasons of the Events recorded for runs.
+const (
+	RunStartedReason         = "PeriscopeRunStarted"
+	RunCompletedReason       = "PeriscopeRunCompleted"
+	RunPartiallyFailedReason = "PeriscopeRunPartiallyFailed"
+	RunCancelledReason       = "PeriscopeRunCancelled"
+	RunFailedReason          = "PeriscopeRunFailed"
+	ProducersSkippedReason   = "PeriscopeProducersSkipped"
+	ProducerFailedReason     = "PeriscopeProducerFailed"
+)
+
+// Recorder records Events for the progress of runs against the Node that Periscope is running on, so that they are
+// shown by `kubectl describe node` and `kubectl get events`. Skipped producers are recorded in a single Event, since
+// similar Events for the same object are rate limited. A nil Recorder records nothing.
+type Recorder struct {
+	recorder record.EventRecorder
+	node     *corev1.ObjectReference
+}
+
+// NewRecorder creates a Recorder recording Events for the specified node with the EventRecorder.
+func NewRecorder(recorder record.EventRecorder, nodeName string) *Recorder {
+	return &Recorder{
+		recorder: recorder,
+		// Like the kubelet's Events for the node, the UID is the node name, so that `kubectl describe node` shows them.
+		node: &corev1.ObjectReference{Kind: "Node", Name: nodeName, UID: types.UID(nodeName)},
+	}
+}
+
+// NewBroadcastingRecorder creates a Recorder which creates the Events through the Kubernetes API, along with a function
+// to stop broadcasting them once no more Events will be recorded.
+func NewBroadcastingRecorder(client kubernetes.Interface, nodeName string) (*Recorder, func()) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: Component, Host: nodeName})
+	return NewRecorder(recorder, nodeName), broadcaster.Shutdown
+}
+
+// RunStarted records that a run has started.
+func (r *Recorder) RunStarted(runId string, scheduled bool) {
+	trigger := "the run ID changed"
+	if scheduled {
+		trigger = "scheduled"
+	}
+	r.event(corev1.EventTypeNormal, RunStartedReason, "Started Periscope run %s (%s)", runId, trigger)
+}
+
+// RunCompleted records the result of a run: completed, partially failed (listing the producers that failed, if any),
+// or cancelled.
+func (r *Recorder) RunCompleted(runId string, result metrics.RunResult, duration time.Duration, failedProducers []string) {
+	duration = duration.Round(time.Second)
+	switch result {
+	case metrics.RunSucceeded:
+		r.event(corev1.EventTypeNormal, RunCompletedReason, "Completed Periscope run %s in %s", runId, duration)
+	case metrics.RunCancelled:
+		r.event(corev1.EventTypeWarning, RunCancelledReason, "Cancelled Periscope run %s after %s, partial data exported", runId, duration)
+	default:
+		failures := ""
+		if len(failedProducers) > 0 {
+			failures = ": " + strings.Join(failedProducers, ", ") + " failed"
+		}
+		r.event(corev1.EventTypeWarning, RunPartiallyFailedReason, "Periscope run %s completed with failures in %s%s", runId, duration, failures)
+	}
+}
+
+// RunFailed records that a run failed before running any producers, e.g. because of an invalid config value.
+func (r *Recorder) RunFailed(runId string, err error) {
+	r.event(corev1.EventTypeWarning, RunFailedReason, "Periscope run %s failed: %v", runId, err)
+}
+
+// ProducersSkipped records the collectors and diagnosers that were skipped in a run (e.g. because they are not
+// supported on the node). The reasons are recorded in the run's manifest and log.
+func (r *Recorder) ProducersSkipped(runId string, names []string) {
+	r.event(corev1.EventTypeNormal, ProducersSkippedReason, "Skipped %s in Periscope run %s", strings.Join(names, ", "), runId)
+}
+
+// ProducerFailed records that a step of a collector or diagnoser failed in a run.
+func (r *Recorder) ProducerFailed(runId string, producerType string, name string, step string, err error) {
+	r.event(corev1.EventTypeWarning, ProducerFailedReason, "Failed to %s data for %s %s in Periscope run %s: %v", step, producerType, name, runId, err)
+}
+
+func (r *Recorder) event(eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.recorder.Event(r.node, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/metrics"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(r *Recorder)
+		want   string
+	}{
+		{
+			name:   "run started",
+			record: func(r *Recorder) { r.RunStarted("run1", false) },
+			want:   "Normal PeriscopeRunStarted Started Periscope run run1 (the run ID changed)",
+		},
+		{
+			name:   "scheduled run started",
+			record: func(r *Recorder) { r.RunStarted("run1", true) },
+			want:   "Normal PeriscopeRunStarted Started Periscope run run1 (scheduled)",
+		},
+		{
+			name:   "run succeeded",
+			record: func(r *Recorder) { r.RunCompleted("run1", metrics.RunSucceeded, 90300*time.Millisecond, []string{}) },
+			want:   "Normal PeriscopeRunCompleted Completed Periscope run run1 in 1m30s",
+		},
+		{
+			name:   "run failed",
+			record: func(r *Recorder) { r.RunCompleted("run1", metrics.RunFailed, time.Minute, []string{"dns", "helm"}) },
+			want:   "Warning PeriscopeRunPartiallyFailed Periscope run run1 completed with failures in 1m0s: dns, helm failed",
+		},
+		{
+			name:   "archive failed",
+			record: func(r *Recorder) { r.RunCompleted("run1", metrics.RunFailed, time.Minute, []string{}) },
+			want:   "Warning PeriscopeRunPartiallyFailed Periscope run run1 completed with failures in 1m0s",
+		},
+		{
+			name:   "run cancelled",
+			record: func(r *Recorder) { r.RunCompleted("run1", metrics.RunCancelled, time.Minute, []string{}) },
+			want:   "Warning PeriscopeRunCancelled Cancelled Periscope run run1 after 1m0s, partial data exported",
+		},
+		{
+			name:   "run failed to start",
+			record: func(r *Recorder) { r.RunFailed("run1", errors.New("invalid config")) },
+			want:   "Warning PeriscopeRunFailed Periscope run run1 failed: invalid config",
+		},
+		{
+			name:   "producers skipped",
+			record: func(r *Recorder) { r.ProducersSkipped("run1", []string{"iptables", "windowslogs"}) },
+			want:   "Normal PeriscopeProducersSkipped Skipped iptables, windowslogs in Periscope run run1",
+		},
+		{
+			name:   "producer failed",
+			record: func(r *Recorder) { r.ProducerFailed("run1", "collector", "dns", "collect", errors.New("timed out")) },
+			want:   "Warning PeriscopeProducerFailed Failed to collect data for collector dns in Periscope run run1: timed out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeRecorder := record.NewFakeRecorder(1)
+			tt.record(NewRecorder(fakeRecorder, "node1"))
+
+			select {
+			case event := <-fakeRecorder.Events:
+				if event != tt.want {
+					t.Errorf("expected event %q, found %q", tt.want, event)
+				}
+			default:
+				t.Errorf("expected event %q, none recorded", tt.want)
+			}
+		})
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	// A nil Recorder is used when Events cannot be recorded, and records nothing.
+	var recorder *Recorder
+	recorder.RunStarted("run1", false)
+	recorder.ProducersSkipped("run1", []string{"dns"})
+	recorder.ProducerFailed("run1", "collector", "dns", "collect", errors.New("failed"))
+	recorder.RunCompleted("run1", metrics.RunFailed, time.Minute, []string{"dns"})
+	recorder.RunFailed("run1", errors.New("failed"))
+}
+
+func TestNodeReference(t *testing.T) {
+	recorder := NewRecorder(record.NewFakeRecorder(1), "node1")
+	if recorder.node.Kind != "Node" || recorder.node.Name != "node1" || string(recorder.node.UID) != "node1" {
+		t.Errorf("unexpected node reference %+v", recorder.node)
+	}
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+)
+
+// ArchiveFormat is the format of the archive of all the data from a run. Its value is also the archive's file extension.
+type ArchiveFormat string
+
+const (
+	ZipArchiveFormat     ArchiveFormat = "zip"
+	TarGzipArchiveFormat ArchiveFormat = "tar.gz"
+	TarZstdArchiveFormat ArchiveFormat = "tar.zst"
+)
+
+// DefaultArchiveFormat is used when no archive format is configured.
+const DefaultArchiveFormat = ZipArchiveFormat
+
+// ParseArchiveFormat gets the archive format with the specified name, or the default format if the name is empty.
+func ParseArchiveFormat(name string) (ArchiveFormat, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DefaultArchiveFormat, nil
+	}
+
+	for _, format := range []ArchiveFormat{ZipArchiveFormat, TarGzipArchiveFormat, TarZstdArchiveFormat} {
+		if name == string(format) {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown archive format %q (expected %s, %s or %s)", name, ZipArchiveFormat, TarGzipArchiveFormat, TarZstdArchiveFormat)
+}
+
+// ArchiveWriter writes entries to an archive in a specific format.
+type ArchiveWriter interface {
+	// WriteEntry writes an entry with the content of the reader, returning the number of bytes read. If reading fails
+	// part way through, the entry is still written with the data read up to that point.
+	WriteEntry(name string, reader io.Reader) (int64, error)
+
+	// Close finishes writing the archive, but does not close the underlying writer.
+	Close() error
+}
+
+// NewArchiveWriter creates an ArchiveWriter writing the specified format to the writer.
+func NewArchiveWriter(w io.Writer, format ArchiveFormat) (ArchiveWriter, error) {
+	switch format {
+	case ZipArchiveFormat:
+		return newZipArchiveWriter(w), nil
+	case TarGzipArchiveFormat:
+		return newTarArchiveWriter(newGzipCompressor(w)), nil
+	case TarZstdArchiveFormat:
+		compressor, err := newZstdCompressor(w)
+		if err != nil {
+			return nil, err
+		}
+		return newTarArchiveWriter(compressor), nil
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+// WriteArchive writes the data from all the specified producers to the writer as an archive in the specified format.
+// Every value is recorded as an artifact in the manifest, which is written as the final entry in the archive.
+func WriteArchive(w io.Writer, format ArchiveFormat, data []interfaces.DataProducer, manifest *Manifest) error {
+	archive, err := NewArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	for _, prd := range data {
+		for name, value := range prd.GetData() {
+			artifact := &ArtifactRecord{Key: name, Length: value.GetLength()}
+			manifest.AddArtifact(prd, artifact)
+
+			key := prd.GetName() + "/" + name
+			hash := sha256.New()
+			err := func() error {
+				valueReader, err := value.GetReader()
+				if err != nil {
+					return err
+				}
+
+				defer valueReader.Close()
+
+				// The written length can differ from the value's length if the data was transformed (e.g. redacted).
+				artifact.Length, err = archive.WriteEntry(key, io.TeeReader(valueReader, hash))
+				return err
+			}()
+
+			if err != nil {
+				// If there's an error writing one value, log the error and continue.
+				// We don't want this to prevent all the other data from being exported.
+				slog.Error("Error writing archive entry", logging.ComponentKey, "archive", "key", key, logging.Error(err))
+				artifact.Error = err.Error()
+				continue
+			}
+
+			artifact.Sha256 = hex.EncodeToString(hash.Sum(nil))
+		}
+	}
+
+	manifestContent, err := manifest.Marshal()
+	if err != nil {
+		return fmt.Errorf("error serializing manifest: %w", err)
+	}
+
+	if _, err := archive.WriteEntry(ManifestFileName, bytes.NewReader(manifestContent)); err != nil {
+		return fmt.Errorf("error writing archive entry %q: %w", ManifestFileName, err)
+	}
+
+	// Closing writes the end of the archive (e.g. the zip central directory), without which it is unreadable.
+	return archive.Close()
+}
+
+// compressedExtensions are the file extensions of formats which are already compressed.
+var compressedExtensions = []string{".gz", ".tgz", ".zst", ".zip", ".xz", ".bz2", ".lz4", ".7z"}
+
+// compressedSignatures are the leading bytes of formats which are already compressed.
+var compressedSignatures = [][]byte{
+	{0x1f, 0x8b},                         // gzip
+	{0x28, 0xb5, 0x2f, 0xfd},             // zstd
+	{0x50, 0x4b, 0x03, 0x04},             // zip
+	{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}, // xz
+	{0x42, 0x5a, 0x68},                   // bzip2
+	{0x04, 0x22, 0x4d, 0x18},             // lz4
+}
+
+// detectCompressed returns true if the named data is already compressed (e.g. rotated logs), so compressing it
+// again would only take time. This is determined by the name's extension, or the leading bytes of the data, so the
+// returned reader must be used in place of the original.
+func detectCompressed(name string, reader io.Reader) (io.Reader, bool) {
+	extension := strings.ToLower(path.Ext(name))
+	for _, compressedExtension := range compressedExtensions {
+		if extension == compressedExtension {
+			return reader, true
+		}
+	}
+
+	bufferedReader := bufio.NewReader(reader)
+	header, _ := bufferedReader.Peek(6)
+	for _, signature := range compressedSignatures {
+		if bytes.HasPrefix(header, signature) {
+			return bufferedReader, true
+		}
+	}
+
+	return bufferedReader, false
+}
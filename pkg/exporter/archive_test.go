@@ -0,0 +1,204 @@
+package exporter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/klauspost/compress/zstd"
+)
+
+// readTarArchive reads all the entries of a tar archive from the decompressed stream.
+func readTarArchive(t *testing.T, reader io.Reader) map[string]string {
+	entries := map[string]string{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("error reading tar header: %v", err)
+		}
+
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			t.Fatalf("error reading tar entry %s: %v", header.Name, err)
+		}
+		entries[header.Name] = string(content)
+	}
+}
+
+func readZipArchive(t *testing.T, content []byte) map[string]string {
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("error opening zip output: %v", err)
+	}
+
+	entries := map[string]string{}
+	for _, file := range zipReader.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("error opening entry %s: %v", file.Name, err)
+		}
+		entryContent, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("error reading entry %s: %v", file.Name, err)
+		}
+		entries[file.Name] = string(entryContent)
+	}
+	return entries
+}
+
+func gzipContent(t *testing.T, content string) string {
+	buffer := new(bytes.Buffer)
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatalf("error compressing: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("error compressing: %v", err)
+	}
+	return buffer.String()
+}
+
+func TestWriteArchive(t *testing.T) {
+	// Large enough to be spooled to a temporary file for tar archives, and to span multiple raw zstd blocks.
+	largeContent := make([]byte, maxSpoolMemory+3*zstdMaxBlockSize/2)
+	if _, err := rand.Read(largeContent); err != nil {
+		t.Fatalf("error generating content: %v", err)
+	}
+
+	rotatedLog := gzipContent(t, strings.Repeat("kubelet log line\n", 50))
+
+	producers := []interfaces.DataProducer{
+		&testDataProducer{
+			name: "nodelogs",
+			data: map[string]string{
+				"kubelet.log":      strings.Repeat("kubelet log line\n", 1000),
+				"kubelet.log.1.gz": rotatedLog,
+				"rotated":          rotatedLog,
+			},
+		},
+		&testDataProducer{
+			name: "systemperf",
+			data: map[string]string{
+				"large": string(largeContent),
+				"empty": "",
+			},
+		},
+	}
+
+	wantEntries := map[string]string{
+		"nodelogs/kubelet.log":      strings.Repeat("kubelet log line\n", 1000),
+		"nodelogs/kubelet.log.1.gz": rotatedLog,
+		"nodelogs/rotated":          rotatedLog,
+		"systemperf/large":          string(largeContent),
+		"systemperf/empty":          "",
+	}
+
+	tests := []struct {
+		format ArchiveFormat
+		read   func(t *testing.T, content []byte) map[string]string
+	}{
+		{
+			format: ZipArchiveFormat,
+			read:   readZipArchive,
+		},
+		{
+			format: TarGzipArchiveFormat,
+			read: func(t *testing.T, content []byte) map[string]string {
+				reader, err := gzip.NewReader(bytes.NewReader(content))
+				if err != nil {
+					t.Fatalf("error opening gzip stream: %v", err)
+				}
+				return readTarArchive(t, reader)
+			},
+		},
+		{
+			format: TarZstdArchiveFormat,
+			read: func(t *testing.T, content []byte) map[string]string {
+				reader, err := zstd.NewReader(bytes.NewReader(content))
+				if err != nil {
+					t.Fatalf("error opening zstd stream: %v", err)
+				}
+				defer reader.Close()
+				return readTarArchive(t, reader)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		manifest := NewManifest("run1", "node1")
+		for _, producer := range producers {
+			manifest.AddProducer(CollectorProducer, producer)
+		}
+
+		buffer := new(bytes.Buffer)
+		if err := WriteArchive(buffer, tt.format, producers, manifest); err != nil {
+			t.Fatalf("%s: WriteArchive() error = %v", tt.format, err)
+		}
+
+		entries := tt.read(t, buffer.Bytes())
+		if len(entries) != len(wantEntries)+1 {
+			t.Errorf("%s: unexpected number of entries: expected %d, found %d", tt.format, len(wantEntries)+1, len(entries))
+		}
+
+		for name, expectedContent := range wantEntries {
+			if actualContent, ok := entries[name]; !ok {
+				t.Errorf("%s: missing entry %s", tt.format, name)
+			} else if actualContent != expectedContent {
+				t.Errorf("%s: unexpected content for %s (expected %d bytes, found %d)", tt.format, name, len(expectedContent), len(actualContent))
+			}
+		}
+
+		actualManifest := &Manifest{}
+		if err := json.Unmarshal([]byte(entries[ManifestFileName]), actualManifest); err != nil {
+			t.Errorf("%s: error decoding manifest: %v", tt.format, err)
+		} else if len(actualManifest.Producers) != 2 || len(actualManifest.Producers[1].Artifacts) != 2 {
+			t.Errorf("%s: unexpected manifest producers: %+v", tt.format, actualManifest.Producers)
+		}
+
+		// Already compressed data is stored as it is, rather than being compressed again.
+		if !bytes.Contains(buffer.Bytes(), []byte(rotatedLog)) {
+			t.Errorf("%s: expected compressed data to be stored without compression", tt.format)
+		}
+		if bytes.Contains(buffer.Bytes(), []byte(strings.Repeat("kubelet log line\n", 100))) {
+			t.Errorf("%s: expected uncompressed data to be compressed", tt.format)
+		}
+	}
+}
+
+func TestParseArchiveFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    ArchiveFormat
+		wantErr bool
+	}{
+		{name: "default", value: "", want: ZipArchiveFormat},
+		{name: "zip", value: "zip", want: ZipArchiveFormat},
+		{name: "tar.gz", value: " tar.gz\n", want: TarGzipArchiveFormat},
+		{name: "tar.zst", value: "tar.zst", want: TarZstdArchiveFormat},
+		{name: "unknown", value: "rar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		format, err := ParseArchiveFormat(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if format != tt.want {
+			t.Errorf("%s: expected %s, found %s", tt.name, tt.want, format)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// Supported values for the storage authentication mode.
+const (
+	SasAuthMode               = "sas"
+	WorkloadIdentityAuthMode  = "workloadidentity"
+	ManagedIdentityAuthMode   = "managedidentity"
+	defaultAuthorityHost      = "https://login.microsoftonline.com/"
+	defaultIMDSTokenEndpoint  = "http://169.254.169.254/metadata/identity/oauth2/token"
+	storageResource           = "https://storage.azure.com/"
+	tokenRefreshMargin        = 5 * time.Minute
+	tokenRefreshRetryInterval = 30 * time.Second
+)
+
+// Environment variables injected into pods by the AKS workload identity webhook, see:
+// https://azure.github.io/azure-workload-identity/docs/topics/service-account-labels-and-annotations.html
+const (
+	authorityHostEnvVar      = "AZURE_AUTHORITY_HOST"
+	tenantIdEnvVar           = "AZURE_TENANT_ID"
+	clientIdEnvVar           = "AZURE_CLIENT_ID"
+	federatedTokenFileEnvVar = "AZURE_FEDERATED_TOKEN_FILE"
+)
+
+type accessToken struct {
+	value     string
+	expiresOn time.Time
+}
+
+// tokenProvider obtains a new access token for the storage service.
+type tokenProvider func(ctx context.Context) (*accessToken, error)
+
+// createBlobCredential returns the credential to use for blob storage requests, based on the configured authentication
+// mode. SAS authentication uses an anonymous credential, because the SAS token is part of the container URL.
+// If a token cannot be obtained for the other modes, SAS authentication is used instead if a SAS token is available.
+func createBlobCredential(runtimeInfo *utils.RuntimeInfo, client *http.Client) (azblob.Credential, bool, error) {
+	var provider tokenProvider
+	switch strings.ToLower(runtimeInfo.StorageAuthMode) {
+	case "", SasAuthMode:
+		return azblob.NewAnonymousCredential(), true, nil
+	case WorkloadIdentityAuthMode:
+		provider = newWorkloadIdentityTokenProvider(client, runtimeInfo.StorageClientId)
+	case ManagedIdentityAuthMode:
+		provider = newManagedIdentityTokenProvider(client, defaultIMDSTokenEndpoint, runtimeInfo.StorageClientId)
+	default:
+		return nil, false, fmt.Errorf("unknown storage authentication mode: %s", runtimeInfo.StorageAuthMode)
+	}
+
+	credential, err := newRefreshingTokenCredential(provider)
+	if err != nil {
+		if runtimeInfo.StorageSasKey != "" {
+			slog.Warn("Unable to obtain storage access token, falling back to SAS authentication", logging.ComponentKey, "azureblob", "authMode", runtimeInfo.StorageAuthMode, logging.Error(err))
+			return azblob.NewAnonymousCredential(), true, nil
+		}
+		return nil, false, fmt.Errorf("obtain %s token: %w", runtimeInfo.StorageAuthMode, err)
+	}
+
+	return credential, false, nil
+}
+
+// newRefreshingTokenCredential obtains an initial token (so that any error is surfaced immediately), and returns a
+// credential that refreshes the token shortly before it expires.
+func newRefreshingTokenCredential(provider tokenProvider) (azblob.TokenCredential, error) {
+	token, err := provider(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	// The refresher is called immediately when the credential is created, at which point we already have a token.
+	initial := true
+	refresher := func(credential azblob.TokenCredential) time.Duration {
+		if !initial {
+			newToken, err := provider(context.Background())
+			if err != nil {
+				// Keep using the current token, which may still be valid, and try again soon.
+				slog.Warn("Unable to refresh storage access token", logging.ComponentKey, "azureblob", logging.Error(err))
+				return tokenRefreshRetryInterval
+			}
+			token = newToken
+			credential.SetToken(token.value)
+		}
+		initial = false
+
+		return getRefreshInterval(token.expiresOn, time.Now())
+	}
+
+	return azblob.NewTokenCredential(token.value, refresher), nil
+}
+
+func getRefreshInterval(expiresOn time.Time, now time.Time) time.Duration {
+	interval := expiresOn.Sub(now) - tokenRefreshMargin
+	if interval < tokenRefreshRetryInterval {
+		return tokenRefreshRetryInterval
+	}
+	return interval
+}
+
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+// newWorkloadIdentityTokenProvider returns a provider that exchanges the federated service account token projected
+// into the pod for an Azure AD token, using the client credentials flow.
+func newWorkloadIdentityTokenProvider(client *http.Client, clientIdOverride string) tokenProvider {
+	return func(ctx context.Context) (*accessToken, error) {
+		authorityHost := os.Getenv(authorityHostEnvVar)
+		if authorityHost == "" {
+			authorityHost = defaultAuthorityHost
+		}
+
+		tenantId := os.Getenv(tenantIdEnvVar)
+		clientId := clientIdOverride
+		if clientId == "" {
+			clientId = os.Getenv(clientIdEnvVar)
+		}
+		tokenFile := os.Getenv(federatedTokenFileEnvVar)
+		if tenantId == "" || clientId == "" || tokenFile == "" {
+			return nil, fmt.Errorf("workload identity is not configured for this pod (%s, %s and %s are required)", tenantIdEnvVar, clientIdEnvVar, federatedTokenFileEnvVar)
+		}
+
+		// The projected token is rotated by the kubelet, so it's read every time.
+		assertion, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read federated token file %s: %w", tokenFile, err)
+		}
+
+		form := url.Values{
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+			"client_id":             {clientId},
+			"grant_type":            {"client_credentials"},
+			"scope":                 {storageResource + ".default"},
+		}
+
+		tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authorityHost, "/"), tenantId)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("create token request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		return requestToken(client, req)
+	}
+}
+
+// newManagedIdentityTokenProvider returns a provider that requests a token for the node's managed identity from the
+// Azure Instance Metadata Service. If no client ID is specified, the node must have a single assigned identity.
+func newManagedIdentityTokenProvider(client *http.Client, endpoint string, clientId string) tokenProvider {
+	return func(ctx context.Context) (*accessToken, error) {
+		query := url.Values{
+			"api-version": {"2018-02-01"},
+			"resource":    {storageResource},
+		}
+		if clientId != "" {
+			query.Set("client_id", clientId)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("create token request: %w", err)
+		}
+		req.Header.Set("Metadata", "true")
+
+		return requestToken(client, req)
+	}
+}
+
+func requestToken(client *http.Client, req *http.Request) (*accessToken, error) {
+	requestTime := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request token from %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("request token from %s: unexpected status %s: %s", req.URL.Host, resp.Status, string(body))
+	}
+
+	response := &tokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("token response from %s contains no access token", req.URL.Host)
+	}
+
+	// Azure AD returns expires_in as a number, whereas IMDS returns it as a string.
+	expiresIn, err := strconv.Atoi(strings.Trim(string(response.ExpiresIn), `"`))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expires_in value in token response: %s", string(response.ExpiresIn))
+	}
+
+	return &accessToken{
+		value:     response.AccessToken,
+		expiresOn: requestTime.Add(time.Duration(expiresIn) * time.Second),
+	}, nil
+}
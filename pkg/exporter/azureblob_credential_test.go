@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/utils"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestManagedIdentityTokenProvider(t *testing.T) {
+	tests := []struct {
+		name          string
+		clientId      string
+		status        int
+		response      string
+		wantErr       bool
+		wantToken     string
+		wantExpiresIn time.Duration
+	}{
+		{
+			name:          "system-assigned identity",
+			status:        http.StatusOK,
+			response:      `{"access_token":"token1","expires_in":"3599","token_type":"Bearer"}`,
+			wantToken:     "token1",
+			wantExpiresIn: 3599 * time.Second,
+		},
+		{
+			name:          "user-assigned identity",
+			clientId:      "00000000-0000-0000-0000-000000000001",
+			status:        http.StatusOK,
+			response:      `{"access_token":"token2","expires_in":"86399","token_type":"Bearer"}`,
+			wantToken:     "token2",
+			wantExpiresIn: 86399 * time.Second,
+		},
+		{
+			name:     "identity not found",
+			clientId: "00000000-0000-0000-0000-000000000002",
+			status:   http.StatusBadRequest,
+			response: `{"error":"invalid_request","error_description":"Identity not found"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "no access token",
+			status:   http.StatusOK,
+			response: `{"expires_in":"3599"}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if r.Header.Get("Metadata") != "true" || query.Get("resource") != storageResource || query.Get("client_id") != tt.clientId {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.response)
+		}))
+
+		provider := newManagedIdentityTokenProvider(server.Client(), server.URL, tt.clientId)
+		checkToken(t, tt.name, provider, tt.wantErr, tt.wantToken, tt.wantExpiresIn)
+		server.Close()
+	}
+}
+
+func TestWorkloadIdentityTokenProvider(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "azure-identity-token")
+	if err := os.WriteFile(tokenFile, []byte("federated-token\n"), 0644); err != nil {
+		t.Fatalf("error writing token file: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/tenant1/oauth2/v2.0/token" || r.PostForm.Get("client_assertion") != "federated-token" || r.PostForm.Get("scope") != storageResource+".default" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"token-%s"}`, r.PostForm.Get("client_id"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		tokenFile        string
+		clientIdOverride string
+		wantErr          bool
+		wantToken        string
+	}{
+		{
+			name:      "client ID from environment",
+			tokenFile: tokenFile,
+			wantToken: "token-client1",
+		},
+		{
+			name:             "client ID from secret",
+			tokenFile:        tokenFile,
+			clientIdOverride: "client2",
+			wantToken:        "token-client2",
+		},
+		{
+			name:    "not configured",
+			wantErr: true,
+		},
+		{
+			name:      "missing token file",
+			tokenFile: filepath.Join(t.TempDir(), "missing"),
+			wantErr:   true,
+		},
+	}
+
+	t.Setenv(authorityHostEnvVar, server.URL+"/")
+	t.Setenv(tenantIdEnvVar, "tenant1")
+	t.Setenv(clientIdEnvVar, "client1")
+
+	for _, tt := range tests {
+		t.Setenv(federatedTokenFileEnvVar, tt.tokenFile)
+		provider := newWorkloadIdentityTokenProvider(server.Client(), tt.clientIdOverride)
+		checkToken(t, tt.name, provider, tt.wantErr, tt.wantToken, 3599*time.Second)
+	}
+}
+
+func checkToken(t *testing.T, name string, provider tokenProvider, wantErr bool, wantToken string, wantExpiresIn time.Duration) {
+	start := time.Now()
+	token, err := provider(context.Background())
+	if (err != nil) != wantErr {
+		t.Errorf("%s error = %v, wantErr %v", name, err, wantErr)
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if token.value != wantToken {
+		t.Errorf("%s unexpected token: expected %s, found %s", name, wantToken, token.value)
+	}
+
+	expiresIn := token.expiresOn.Sub(start)
+	if expiresIn < wantExpiresIn || expiresIn > wantExpiresIn+time.Minute {
+		t.Errorf("%s unexpected expiry: expected %s, found %s", name, wantExpiresIn, expiresIn)
+	}
+}
+
+func TestGetRefreshInterval(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresOn time.Time
+		want      time.Duration
+	}{
+		{name: "long-lived token", expiresOn: now.Add(time.Hour), want: 55 * time.Minute},
+		{name: "nearly expired token", expiresOn: now.Add(time.Minute), want: tokenRefreshRetryInterval},
+		{name: "expired token", expiresOn: now.Add(-time.Minute), want: tokenRefreshRetryInterval},
+	}
+
+	for _, tt := range tests {
+		if got := getRefreshInterval(tt.expiresOn, now); got != tt.want {
+			t.Errorf("%s getRefreshInterval() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateBlobCredential(t *testing.T) {
+	// Ensure workload identity can't be configured from the environment the tests run in.
+	t.Setenv(federatedTokenFileEnvVar, "")
+
+	tests := []struct {
+		name        string
+		runtimeInfo *utils.RuntimeInfo
+		wantErr     bool
+		wantSas     bool
+	}{
+		{
+			name:        "default",
+			runtimeInfo: &utils.RuntimeInfo{StorageSasKey: "?sv=1"},
+			wantSas:     true,
+		},
+		{
+			name:        "sas",
+			runtimeInfo: &utils.RuntimeInfo{StorageAuthMode: "SAS", StorageSasKey: "?sv=1"},
+			wantSas:     true,
+		},
+		{
+			name:        "unknown mode",
+			runtimeInfo: &utils.RuntimeInfo{StorageAuthMode: "password"},
+			wantErr:     true,
+		},
+		{
+			name:        "workload identity falls back to SAS",
+			runtimeInfo: &utils.RuntimeInfo{StorageAuthMode: WorkloadIdentityAuthMode, StorageSasKey: "?sv=1"},
+			wantSas:     true,
+		},
+		{
+			name:        "workload identity without SAS",
+			runtimeInfo: &utils.RuntimeInfo{StorageAuthMode: WorkloadIdentityAuthMode},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		credential, useSas, err := createBlobCredential(tt.runtimeInfo, http.DefaultClient)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if useSas != tt.wantSas {
+			t.Errorf("%s useSas = %v, want %v", tt.name, useSas, tt.wantSas)
+		}
+		if _, isToken := credential.(azblob.TokenCredential); isToken == tt.wantSas {
+			t.Errorf("%s unexpected credential type %T", tt.name, credential)
+		}
+	}
+}
@@ -5,10 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
 	"github.com/Azure/aks-periscope/pkg/utils"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 )
@@ -18,6 +24,15 @@ type AzureBlobExporter struct {
 	runtimeInfo    *utils.RuntimeInfo
 	knownFilePaths *utils.KnownFilePaths
 	containerName  string
+	client         *http.Client
+	lock           sync.Mutex
+	containerURLs  *blobContainerURLs
+}
+
+// blobContainerURLs are URLs of the same storage container, with pipelines that either retry each request or not.
+type blobContainerURLs struct {
+	retrying  azblob.ContainerURL
+	singleTry azblob.ContainerURL
 }
 
 type StorageKeyType string
@@ -35,89 +50,163 @@ func NewAzureBlobExporter(runtimeInfo *utils.RuntimeInfo, knownFilePaths *utils.
 		runtimeInfo:    runtimeInfo,
 		knownFilePaths: knownFilePaths,
 		containerName:  containerName,
+		client:         &http.Client{Timeout: time.Minute},
 	}
 }
 
-func createContainerURL(runtimeInfo *utils.RuntimeInfo, knownFilePaths *utils.KnownFilePaths) (azblob.ContainerURL, error) {
-	if runtimeInfo.StorageAccountName == "" || runtimeInfo.StorageSasKey == "" || runtimeInfo.StorageContainerName == "" {
-		log.Print("Storage Account information were not provided. Export to Azure Storage Account will be skipped.")
-		return azblob.ContainerURL{}, errors.New("Storage not configured.")
+// getContainerURLs returns the URLs of the storage container, creating the container if needed. The URLs (and their
+// pipelines) are created once and shared by all exports in the run.
+func (exporter *AzureBlobExporter) getContainerURLs(ctx context.Context) (*blobContainerURLs, error) {
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+
+	if exporter.containerURLs != nil {
+		return exporter.containerURLs, nil
+	}
+
+	containerURLs, err := exporter.createContainerURLs(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx := context.Background()
+	exporter.containerURLs = containerURLs
+	return exporter.containerURLs, nil
+}
 
-	pipeline := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{})
+func (exporter *AzureBlobExporter) createContainerURLs(ctx context.Context) (*blobContainerURLs, error) {
+	runtimeInfo := exporter.runtimeInfo
+	usesToken := runtimeInfo.StorageAuthMode != "" && !strings.EqualFold(runtimeInfo.StorageAuthMode, SasAuthMode)
+	if runtimeInfo.StorageAccountName == "" || runtimeInfo.StorageContainerName == "" || (runtimeInfo.StorageSasKey == "" && !usesToken) {
+		slog.Warn("Storage account information was not provided, export to Azure Storage will be skipped", logging.ComponentKey, "azureblob")
+		return nil, errors.New("Storage not configured.")
+	}
 
-	ses := utils.GetStorageEndpointSuffix(knownFilePaths)
-	url, err := url.Parse(fmt.Sprintf("https://%s.blob.%s/%s%s", runtimeInfo.StorageAccountName, ses, runtimeInfo.StorageContainerName, runtimeInfo.StorageSasKey))
+	credential, useSas, err := createBlobCredential(runtimeInfo, exporter.client)
 	if err != nil {
-		return azblob.ContainerURL{}, fmt.Errorf("build blob container url: %w", err)
+		return nil, err
 	}
 
-	containerURL := azblob.NewContainerURL(*url, pipeline)
+	sasKey := ""
+	if useSas {
+		sasKey = runtimeInfo.StorageSasKey
+	}
+
+	ses := utils.GetStorageEndpointSuffix(exporter.knownFilePaths)
+	url, err := url.Parse(fmt.Sprintf("https://%s.blob.%s/%s%s", runtimeInfo.StorageAccountName, ses, runtimeInfo.StorageContainerName, sasKey))
+	if err != nil {
+		return nil, fmt.Errorf("build blob container url: %w", err)
+	}
+
+	// Uploads of each key are retried as a whole by uploadAll, so their requests are only tried once by the pipeline,
+	// and MaxTries is not applied at both levels. Uploads from a reader can't be retried as a whole, so each of their
+	// requests (e.g. uploading a single block) is retried by the pipeline instead.
+	options := runtimeInfo.GetUploadOptions()
+	newContainerURL := func(maxTries int) azblob.ContainerURL {
+		return azblob.NewContainerURL(*url, azblob.NewPipeline(credential, azblob.PipelineOptions{
+			Retry: azblob.RetryOptions{
+				Policy:        azblob.RetryPolicyExponential,
+				MaxTries:      int32(maxTries),
+				TryTimeout:    options.TryTimeout,
+				RetryDelay:    options.RetryDelay,
+				MaxRetryDelay: options.MaxRetryDelay,
+			},
+		}))
+	}
+
+	containerURLs := &blobContainerURLs{
+		retrying:  newContainerURL(options.MaxTries),
+		singleTry: newContainerURL(1),
+	}
 
 	if _, ok := storageKeyTypes[runtimeInfo.StorageSasKeyType]; ok {
-		return containerURL, nil
+		return containerURLs, nil
 	}
 
-	_, err = containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	_, err = containerURLs.retrying.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
 	if err != nil {
 		storageError, ok := err.(azblob.StorageError)
 		if ok {
 			switch storageError.ServiceCode() {
 			case azblob.ServiceCodeContainerAlreadyExists:
 			default:
-				return azblob.ContainerURL{}, fmt.Errorf("create container with storage error: %w", err)
+				return nil, fmt.Errorf("create container with storage error: %w", err)
 			}
 		} else {
-			return azblob.ContainerURL{}, fmt.Errorf("create container: %w", err)
+			return nil, fmt.Errorf("create container: %w", err)
 		}
 	}
 
-	return containerURL, nil
+	return containerURLs, nil
 }
 
 // Export implements the interface method
-func (exporter *AzureBlobExporter) Export(producer interfaces.DataProducer) error {
-	containerURL, err := createContainerURL(exporter.runtimeInfo, exporter.knownFilePaths)
+func (exporter *AzureBlobExporter) Export(ctx context.Context, producer interfaces.DataProducer) error {
+	_, err := exporter.ExportWithRecords(ctx, producer)
+	return err
+}
+
+// ExportWithRecords uploads each of the producer's data values to a separate blob, retrying uploads that fail with a
+// transient error, and returns a record of the outcome for each key.
+func (exporter *AzureBlobExporter) ExportWithRecords(ctx context.Context, producer interfaces.DataProducer) ([]*UploadRecord, error) {
+	containerURLs, err := exporter.getContainerURLs(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for key, value := range producer.GetData() {
-		blobURL := containerURL.NewBlockBlobURL(fmt.Sprintf("%s/%s/%s", exporter.containerName, exporter.runtimeInfo.HostNodeName, key))
-
-		log.Printf("\tAppend blob file: %s (of size %d bytes)", key, value.GetLength())
+	options := exporter.runtimeInfo.GetUploadOptions()
+	logger := slog.With(logging.ComponentKey, "azureblob")
+	return uploadAll(ctx, logger, producer, options, isTransientBlobError, func(ctx context.Context, key string, value interfaces.DataValue) error {
+		blobURL := containerURLs.singleTry.NewBlockBlobURL(fmt.Sprintf("%s/%s/%s", exporter.containerName, exporter.runtimeInfo.HostNodeName, key))
 
-		err = func() error {
-			valueReadCloser, err := value.GetReader()
-			if err != nil {
-				return err
-			}
-
-			defer valueReadCloser.Close()
-
-			_, err = azblob.UploadStreamToBlockBlob(context.Background(), valueReadCloser, blobURL, azblob.UploadStreamToBlockBlobOptions{})
-			return err
-		}()
+		logger.Info("Uploading blob", "key", key, "size", value.GetLength())
 
+		valueReadCloser, err := value.GetReader()
 		if err != nil {
-			return fmt.Errorf("append file %s to blob: %w", key, err)
+			return err
 		}
-	}
 
-	return nil
+		defer valueReadCloser.Close()
+
+		_, err = azblob.UploadStreamToBlockBlob(ctx, valueReadCloser, blobURL, getUploadStreamOptions(options))
+		return err
+	})
 }
 
-func (exporter *AzureBlobExporter) ExportReader(name string, reader io.ReadSeeker) error {
-	containerURL, err := createContainerURL(exporter.runtimeInfo, exporter.knownFilePaths)
+// ExportReader uploads the content of the reader to a single blob. The reader can't be re-read, so the upload isn't
+// retried as a whole, but each block is still retried by the pipeline.
+func (exporter *AzureBlobExporter) ExportReader(ctx context.Context, name string, reader io.Reader) error {
+	containerURLs, err := exporter.getContainerURLs(ctx)
 	if err != nil {
 		return err
 	}
 
-	blobUrl := containerURL.NewBlockBlobURL(fmt.Sprintf("%s/%s/%s", exporter.containerName, exporter.runtimeInfo.HostNodeName, name))
-	log.Printf("Uploading the file with blob name: %s\n", name)
-	_, err = azblob.UploadStreamToBlockBlob(context.Background(), reader, blobUrl, azblob.UploadStreamToBlockBlobOptions{})
+	blobUrl := containerURLs.retrying.NewBlockBlobURL(fmt.Sprintf("%s/%s/%s", exporter.containerName, exporter.runtimeInfo.HostNodeName, name))
+	slog.Info("Uploading blob", logging.ComponentKey, "azureblob", "key", name)
+	_, err = azblob.UploadStreamToBlockBlob(ctx, reader, blobUrl, getUploadStreamOptions(exporter.runtimeInfo.GetUploadOptions()))
 
 	return err
 }
+
+func getUploadStreamOptions(options *utils.UploadOptions) azblob.UploadStreamToBlockBlobOptions {
+	return azblob.UploadStreamToBlockBlobOptions{
+		BufferSize: int(options.BlockSize),
+		MaxBuffers: options.Parallelism,
+	}
+}
+
+// isTransientBlobError returns true if the error may not recur if the upload is retried, such as a throttling or
+// server error response, or a network error.
+func isTransientBlobError(err error) bool {
+	var storageError azblob.StorageError
+	if errors.As(err, &storageError) {
+		response := storageError.Response()
+		if response == nil {
+			return true
+		}
+		status := response.StatusCode
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxBlockSize is the largest block in a zstd frame with the window size used for raw frames.
+const zstdMaxBlockSize = 128 * 1024
+
+// zstdRawFrameHeader is the zstd magic number, followed by a frame header descriptor with no content size, checksum
+// or dictionary, and a window descriptor for a 128KiB window.
+var zstdRawFrameHeader = []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x38}
+
+// switchingCompressor compresses a stream as a sequence of separate gzip members or zstd frames, so that data which is
+// already compressed can be written to its own uncompressed member or frame, rather than being compressed again.
+// Decompressors treat the concatenated members or frames as a single stream.
+type switchingCompressor struct {
+	writer   io.Writer
+	compress bool
+	current  io.WriteCloser
+	create   func(w io.Writer, compress bool) io.WriteCloser
+}
+
+func newSwitchingCompressor(w io.Writer, create func(w io.Writer, compress bool) io.WriteCloser) *switchingCompressor {
+	return &switchingCompressor{
+		writer:   w,
+		compress: true,
+		create:   create,
+	}
+}
+
+// newGzipCompressor creates a compressor writing gzip members, using the stored (uncompressed) deflate block type
+// for data which is already compressed.
+func newGzipCompressor(w io.Writer) *switchingCompressor {
+	compressingWriter := gzip.NewWriter(nil)
+	storingWriter, _ := gzip.NewWriterLevel(nil, gzip.NoCompression)
+
+	return newSwitchingCompressor(w, func(w io.Writer, compress bool) io.WriteCloser {
+		gzipWriter := storingWriter
+		if compress {
+			gzipWriter = compressingWriter
+		}
+		gzipWriter.Reset(w)
+		return gzipWriter
+	})
+}
+
+// newZstdCompressor creates a compressor writing zstd frames, using raw (uncompressed) blocks for data which is
+// already compressed.
+func newZstdCompressor(w io.Writer) (*switchingCompressor, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSwitchingCompressor(w, func(w io.Writer, compress bool) io.WriteCloser {
+		if !compress {
+			return &zstdRawFrameWriter{writer: w}
+		}
+		encoder.Reset(w)
+		return encoder
+	}), nil
+}
+
+func (c *switchingCompressor) Write(p []byte) (int, error) {
+	if c.current == nil {
+		c.current = c.create(c.writer, c.compress)
+	}
+	return c.current.Write(p)
+}
+
+// SetCompression sets whether the data written after this is compressed, finishing the current member or frame if
+// this changes.
+func (c *switchingCompressor) SetCompression(compress bool) error {
+	if compress == c.compress {
+		return nil
+	}
+
+	c.compress = compress
+	return c.finish()
+}
+
+// Close finishes the current member or frame, but does not close the underlying writer.
+func (c *switchingCompressor) Close() error {
+	if c.current == nil {
+		// Write an empty member or frame, so that the output is valid even if nothing was written.
+		c.current = c.create(c.writer, c.compress)
+	}
+	return c.finish()
+}
+
+func (c *switchingCompressor) finish() error {
+	if c.current == nil {
+		return nil
+	}
+
+	err := c.current.Close()
+	c.current = nil
+	return err
+}
+
+// zstdRawFrameWriter writes a zstd frame containing the data in raw (uncompressed) blocks.
+type zstdRawFrameWriter struct {
+	writer  io.Writer
+	started bool
+}
+
+func (w *zstdRawFrameWriter) Write(p []byte) (int, error) {
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for written < len(p) {
+		block := p[written:]
+		if len(block) > zstdMaxBlockSize {
+			block = block[:zstdMaxBlockSize]
+		}
+
+		if _, err := w.writer.Write(getZstdRawBlockHeader(len(block), false)); err != nil {
+			return written, err
+		}
+		n, err := w.writer.Write(block)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Close ends the frame with an empty last block, but does not close the underlying writer.
+func (w *zstdRawFrameWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	_, err := w.writer.Write(getZstdRawBlockHeader(0, true))
+	return err
+}
+
+func (w *zstdRawFrameWriter) start() error {
+	if w.started {
+		return nil
+	}
+
+	w.started = true
+	_, err := w.writer.Write(zstdRawFrameHeader)
+	return err
+}
+
+// getZstdRawBlockHeader gets the 3-byte little-endian block header, consisting of the last block flag, the block type
+// (zero for raw blocks), and the block size.
+func getZstdRawBlockHeader(size int, last bool) []byte {
+	value := uint32(size) << 3
+	if last {
+		value |= 1
+	}
+	return []byte{byte(value), byte(value >> 8), byte(value >> 16)}
+}
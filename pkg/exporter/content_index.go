@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/logging"
+)
+
+// ContentIndex records the hash of the content last exported for each key, along with the run it was exported in.
+// It is stored in a local file, so that it is kept between runs. The index has a scope (e.g. identifying the export
+// destination), and its entries are discarded if the scope changes, since they would refer to data that may not be
+// available at the new destination.
+type ContentIndex struct {
+	path    string
+	scope   string
+	lock    sync.Mutex
+	entries map[string]*ContentIndexEntry
+}
+
+// ContentIndexEntry describes the content exported for a key.
+type ContentIndexEntry struct {
+	Sha256 string    `json:"sha256"`
+	RunId  string    `json:"runId"`
+	Time   time.Time `json:"time"`
+}
+
+type contentIndexFile struct {
+	Scope   string                        `json:"scope"`
+	Entries map[string]*ContentIndexEntry `json:"entries"`
+}
+
+// LoadContentIndex reads the index from the file at the specified path. A missing or unreadable file (or one with
+// a different scope) results in an empty index, since the index is only an optimization.
+func LoadContentIndex(path, scope string) *ContentIndex {
+	// Only a hash of the scope is stored, since it may be long (e.g. if it includes certificates).
+	scopeHash := sha256.Sum256([]byte(scope))
+	index := &ContentIndex{
+		path:    path,
+		scope:   hex.EncodeToString(scopeHash[:]),
+		entries: map[string]*ContentIndexEntry{},
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Could not read content index", logging.ComponentKey, "deduplication", "path", path, logging.Error(err))
+		}
+		return index
+	}
+
+	indexFile := &contentIndexFile{}
+	if err := json.Unmarshal(content, indexFile); err != nil {
+		slog.Warn("Could not parse content index", logging.ComponentKey, "deduplication", "path", path, logging.Error(err))
+		return index
+	}
+
+	if indexFile.Scope == index.scope && indexFile.Entries != nil {
+		index.entries = indexFile.Entries
+	}
+
+	return index
+}
+
+// Get gets the entry for the key, if there is one.
+func (index *ContentIndex) Get(key string) (*ContentIndexEntry, bool) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	entry, ok := index.entries[key]
+	return entry, ok
+}
+
+// Set sets the entry for the key. The index is not saved until Save is called.
+func (index *ContentIndex) Set(key string, entry *ContentIndexEntry) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	index.entries[key] = entry
+}
+
+// Save writes the index to its file, replacing the file only once it has been written in full.
+func (index *ContentIndex) Save() error {
+	// The lock is held while writing, so that concurrent saves don't write the temporary file at the same time.
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	content, err := json.Marshal(&contentIndexFile{Scope: index.scope, Entries: index.entries})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(index.path), 0700); err != nil {
+		return err
+	}
+
+	tempPath := index.path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0600); err != nil {
+		return fmt.Errorf("error writing content index: %w", err)
+	}
+
+	return os.Rename(tempPath, index.path)
+}
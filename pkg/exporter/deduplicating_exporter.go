@@ -0,0 +1,270 @@
+package exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// ReferenceFileExtension is appended to the key of a value which is unchanged since a previous run, for the reference
+// which is exported in its place.
+const ReferenceFileExtension = ".ref"
+
+// ContentReference is exported in place of a value which is unchanged since a previous run, identifying the run
+// in which the content was exported, under <runId>/<node>/.
+type ContentReference struct {
+	Node     string `json:"node"`
+	Producer string `json:"producer"`
+	Key      string `json:"key"`
+	RunId    string `json:"runId"`
+	Sha256   string `json:"sha256"`
+}
+
+// DeduplicatingExporter exports only the values which have changed since they were last exported, according to the
+// content index. Unchanged values are replaced by a reference to the run in which they were exported, as long as that
+// run is recent enough that its data is expected to still be available. The index is keyed by the node the data is
+// exported for (which is "cluster" for cluster-level data), so that the same producer's data for different nodes is
+// never confused.
+type DeduplicatingExporter struct {
+	exporter   interfaces.Exporter
+	index      *ContentIndex
+	nodeName   string
+	runId      string
+	maxAge     time.Duration
+	lock       sync.Mutex
+	references map[string]map[string]interfaces.DataValue
+}
+
+func NewDeduplicatingExporter(exporter interfaces.Exporter, index *ContentIndex, nodeName, runId string, maxAge time.Duration) *DeduplicatingExporter {
+	return &DeduplicatingExporter{
+		exporter:   exporter,
+		index:      index,
+		nodeName:   nodeName,
+		runId:      runId,
+		maxAge:     maxAge,
+		references: map[string]map[string]interfaces.DataValue{},
+	}
+}
+
+// Export implements the interface method
+func (exporter *DeduplicatingExporter) Export(ctx context.Context, producer interfaces.DataProducer) error {
+	_, err := exporter.ExportWithRecords(ctx, producer)
+	return err
+}
+
+// ExportWithRecords exports the changed values and references to the unchanged ones, returning the upload records
+// from the underlying exporter if it produces them, along with a record for each unchanged value. The index is
+// updated with the content of every value that was exported successfully.
+func (exporter *DeduplicatingExporter) ExportWithRecords(ctx context.Context, producer interfaces.DataProducer) ([]*UploadRecord, error) {
+	data := producer.GetData()
+	exportedData := make(map[string]interfaces.DataValue, len(data))
+	hashedValues := map[string]*hashingValue{}
+	unchangedRecords := []*UploadRecord{}
+	references := map[string]interfaces.DataValue{}
+
+	for key, value := range data {
+		indexKey := exporter.getIndexKey(producer.GetName(), key)
+		if entry, ok := exporter.getUnchanged(indexKey, value); ok {
+			slog.Info("Skipping unchanged value", logging.ComponentKey, "deduplication", "key", indexKey, "referenceRunId", entry.RunId)
+
+			reference, _ := json.Marshal(&ContentReference{
+				Node:     exporter.nodeName,
+				Producer: producer.GetName(),
+				Key:      key,
+				RunId:    entry.RunId,
+				Sha256:   entry.Sha256,
+			})
+			referenceValue := utils.NewStringDataValue(string(reference))
+			references[key] = referenceValue
+			exportedData[key+ReferenceFileExtension] = referenceValue
+			unchangedRecords = append(unchangedRecords, &UploadRecord{
+				Key:            key,
+				Succeeded:      true,
+				ReferenceRunId: entry.RunId,
+				Duration:       time.Duration(0).String(),
+			})
+			continue
+		}
+
+		hashedValue := &hashingValue{value: value}
+		hashedValues[key] = hashedValue
+		exportedData[key] = hashedValue
+	}
+
+	exporter.lock.Lock()
+	exporter.references[producer.GetName()] = references
+	exporter.lock.Unlock()
+
+	exportedProducer := &deduplicatedProducer{name: producer.GetName(), data: exportedData}
+
+	var records []*UploadRecord
+	var err error
+	recordingExporter, isRecording := exporter.exporter.(UploadRecordingExporter)
+	if isRecording {
+		records, err = recordingExporter.ExportWithRecords(ctx, exportedProducer)
+	} else {
+		err = exporter.exporter.Export(ctx, exportedProducer)
+	}
+
+	// Without upload records, the values are only known to have been exported if there was no error at all.
+	succeeded := map[string]bool{}
+	for _, record := range records {
+		succeeded[record.Key] = record.Succeeded
+	}
+
+	now := time.Now().UTC()
+	for key, hashedValue := range hashedValues {
+		exported := err == nil
+		if isRecording {
+			exported = succeeded[key]
+		}
+
+		if hash := hashedValue.getSha256(); exported && hash != "" {
+			exporter.index.Set(exporter.getIndexKey(producer.GetName(), key), &ContentIndexEntry{Sha256: hash, RunId: exporter.runId, Time: now})
+		}
+	}
+
+	if saveErr := exporter.index.Save(); saveErr != nil {
+		slog.Warn("Could not save content index", logging.ComponentKey, "deduplication", logging.Error(saveErr))
+	}
+
+	return append(records, unchangedRecords...), err
+}
+
+// ExportReader implements the interface method. The data is always exported, since it is not associated with a key
+// that could be compared with previous runs.
+func (exporter *DeduplicatingExporter) ExportReader(ctx context.Context, name string, reader io.Reader) error {
+	return exporter.exporter.ExportReader(ctx, name, reader)
+}
+
+// ReplaceUnchanged returns a producer with the same data as the specified one, except that the values which were
+// exported as references to a previous run are replaced by those references, as they were exported. This allows the
+// archive to contain only the data that changed in this run.
+func (exporter *DeduplicatingExporter) ReplaceUnchanged(producer interfaces.DataProducer) interfaces.DataProducer {
+	exporter.lock.Lock()
+	references := exporter.references[producer.GetName()]
+	exporter.lock.Unlock()
+
+	if len(references) == 0 {
+		return producer
+	}
+
+	data := producer.GetData()
+	replacedData := make(map[string]interfaces.DataValue, len(data))
+	for key, value := range data {
+		if reference, ok := references[key]; ok {
+			replacedData[key+ReferenceFileExtension] = reference
+			continue
+		}
+		replacedData[key] = value
+	}
+
+	return &deduplicatedProducer{name: producer.GetName(), data: replacedData}
+}
+
+// getIndexKey gets the key of the index entry for the producer's data with the specified key.
+func (exporter *DeduplicatingExporter) getIndexKey(producerName, key string) string {
+	return exporter.nodeName + "/" + producerName + "/" + key
+}
+
+// getUnchanged returns the index entry for the key if the value has the same content, and the entry is from
+// a different run which is recent enough to reference.
+func (exporter *DeduplicatingExporter) getUnchanged(indexKey string, value interfaces.DataValue) (*ContentIndexEntry, bool) {
+	entry, ok := exporter.index.Get(indexKey)
+	if !ok || entry.RunId == exporter.runId || time.Since(entry.Time) > exporter.maxAge {
+		return nil, false
+	}
+
+	hash, err := getSha256(value)
+	if err != nil || hash != entry.Sha256 {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func getSha256(value interfaces.DataValue) (string, error) {
+	reader, err := value.GetReader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type deduplicatedProducer struct {
+	name string
+	data map[string]interfaces.DataValue
+}
+
+func (p *deduplicatedProducer) GetName() string {
+	return p.name
+}
+
+func (p *deduplicatedProducer) GetData() map[string]interfaces.DataValue {
+	return p.data
+}
+
+// hashingValue records the hash of the content the last time it was read in full, which is the content that was
+// exported (the value may be read more than once if the upload is retried).
+type hashingValue struct {
+	value  interfaces.DataValue
+	lock   sync.Mutex
+	sha256 string
+}
+
+func (v *hashingValue) GetLength() int64 {
+	return v.value.GetLength()
+}
+
+func (v *hashingValue) GetReader() (io.ReadCloser, error) {
+	reader, err := v.value.GetReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &hashingReader{source: reader, hash: sha256.New(), value: v}, nil
+}
+
+func (v *hashingValue) getSha256() string {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.sha256
+}
+
+type hashingReader struct {
+	source io.ReadCloser
+	hash   hash.Hash
+	value  *hashingValue
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		r.value.lock.Lock()
+		r.value.sha256 = hex.EncodeToString(r.hash.Sum(nil))
+		r.value.lock.Unlock()
+	}
+	return n, err
+}
+
+func (r *hashingReader) Close() error {
+	return r.source.Close()
+}
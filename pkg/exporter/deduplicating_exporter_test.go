@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// failingRecordingExporter reads every value in full, and records a failed upload for the specified key.
+type failingRecordingExporter struct {
+	failingKey string
+}
+
+func (exporter *failingRecordingExporter) Export(ctx context.Context, producer interfaces.DataProducer) error {
+	_, err := exporter.ExportWithRecords(ctx, producer)
+	return err
+}
+
+func (exporter *failingRecordingExporter) ExportWithRecords(ctx context.Context, producer interfaces.DataProducer) ([]*UploadRecord, error) {
+	var err error
+	records := []*UploadRecord{}
+	for key, value := range producer.GetData() {
+		reader, readErr := value.GetReader()
+		if readErr == nil {
+			_, readErr = io.ReadAll(reader)
+			reader.Close()
+		}
+
+		succeeded := readErr == nil && key != exporter.failingKey
+		if !succeeded {
+			err = errors.New("upload failed")
+		}
+		records = append(records, &UploadRecord{Key: key, Succeeded: succeeded})
+	}
+	return records, err
+}
+
+func (exporter *failingRecordingExporter) ExportReader(ctx context.Context, name string, reader io.Reader) error {
+	return nil
+}
+
+func TestDeduplicatingExporter(t *testing.T) {
+	directory := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	runtimeInfo := &utils.RuntimeInfo{HostNodeName: "node1"}
+
+	export := func(runId, scope string, maxAge time.Duration, data map[string]string) map[string]*UploadRecord {
+		index := LoadContentIndex(indexPath, scope)
+		exporter := NewDeduplicatingExporter(NewLocalDirectoryExporter(runtimeInfo, directory, runId), index, runtimeInfo.HostNodeName, runId, maxAge)
+		records, err := exporter.ExportWithRecords(context.TODO(), &testDataProducer{name: "nodelogs", data: data})
+		if err != nil {
+			t.Fatalf("%s: ExportWithRecords() error = %v", runId, err)
+		}
+
+		result := map[string]*UploadRecord{}
+		for _, record := range records {
+			result[record.Key] = record
+		}
+		return result
+	}
+
+	readFile := func(relativePath string) (string, bool) {
+		content, err := os.ReadFile(filepath.Join(directory, filepath.FromSlash(relativePath)))
+		return string(content), err == nil
+	}
+
+	export("run1", "scope1", time.Hour, map[string]string{"kubelet": "kubelet v1", "syslog": "syslog v1"})
+	records := export("run2", "scope1", time.Hour, map[string]string{"kubelet": "kubelet v1", "syslog": "syslog v2"})
+
+	if content, ok := readFile("run2/node1/nodelogs/syslog"); !ok || content != "syslog v2" {
+		t.Errorf("expected changed value to be exported, found '%s'", content)
+	}
+	if _, ok := readFile("run2/node1/nodelogs/kubelet"); ok {
+		t.Errorf("expected unchanged value not to be exported")
+	}
+
+	referenceContent, ok := readFile("run2/node1/nodelogs/kubelet" + ReferenceFileExtension)
+	if !ok {
+		t.Fatalf("expected reference to be exported for unchanged value")
+	}
+	reference := &ContentReference{}
+	if err := json.Unmarshal([]byte(referenceContent), reference); err != nil {
+		t.Fatalf("error decoding reference: %v", err)
+	}
+	if reference.RunId != "run1" || reference.Node != "node1" || reference.Producer != "nodelogs" || reference.Key != "kubelet" || reference.Sha256 == "" {
+		t.Errorf("unexpected reference: %+v", reference)
+	}
+
+	if record := records["kubelet"]; record == nil || !record.Succeeded || record.ReferenceRunId != "run1" {
+		t.Errorf("unexpected record for unchanged value: %+v", record)
+	}
+
+	// References always point to the run where the content was exported, rather than to another reference.
+	records = export("run3", "scope1", time.Hour, map[string]string{"kubelet": "kubelet v1", "syslog": "syslog v2"})
+	if records["kubelet"].ReferenceRunId != "run1" || records["syslog"].ReferenceRunId != "run2" {
+		t.Errorf("unexpected references: kubelet %s, syslog %s", records["kubelet"].ReferenceRunId, records["syslog"].ReferenceRunId)
+	}
+
+	// A different scope (e.g. a different destination) doesn't use the existing index.
+	export("run4", "scope2", time.Hour, map[string]string{"kubelet": "kubelet v1"})
+	if content, ok := readFile("run4/node1/nodelogs/kubelet"); !ok || content != "kubelet v1" {
+		t.Errorf("expected value to be exported for new scope, found '%s'", content)
+	}
+
+	// Runs older than the maximum age are not referenced.
+	time.Sleep(10 * time.Millisecond)
+	export("run5", "scope2", time.Millisecond, map[string]string{"kubelet": "kubelet v1"})
+	if _, ok := readFile("run5/node1/nodelogs/kubelet"); !ok {
+		t.Errorf("expected value to be exported when the previous run is too old")
+	}
+}
+
+func TestDeduplicatingExporterFailedUpload(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	producer := &testDataProducer{name: "nodelogs", data: map[string]string{"kubelet": "kubelet", "syslog": "syslog"}}
+
+	exporter := NewDeduplicatingExporter(&failingRecordingExporter{failingKey: "syslog"}, LoadContentIndex(indexPath, ""), "node1", "run1", time.Hour)
+	if _, err := exporter.ExportWithRecords(context.TODO(), producer); err == nil {
+		t.Errorf("expected error from failed upload")
+	}
+
+	index := LoadContentIndex(indexPath, "")
+	if entry, ok := index.Get("node1/nodelogs/kubelet"); !ok || entry.RunId != "run1" {
+		t.Errorf("expected index entry for uploaded value, found %+v", entry)
+	}
+	if _, ok := index.Get("node1/nodelogs/syslog"); ok {
+		t.Errorf("expected no index entry for value that failed to upload")
+	}
+}
+
+func TestDeduplicatingExporterNodes(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	producer := &testDataProducer{name: "kubeobjects", data: map[string]string{"pods": "pods"}}
+
+	export := func(nodeName, runId string) *DeduplicatingExporter {
+		runtimeInfo := &utils.RuntimeInfo{HostNodeName: nodeName}
+		exporter := NewDeduplicatingExporter(NewLocalDirectoryExporter(runtimeInfo, t.TempDir(), runId), LoadContentIndex(indexPath, ""), nodeName, runId, time.Hour)
+		if _, err := exporter.ExportWithRecords(context.TODO(), producer); err != nil {
+			t.Fatalf("%s: ExportWithRecords() error = %v", runId, err)
+		}
+		return exporter
+	}
+
+	// The same data exported for a different node (or for the cluster) in the same index is not a reference.
+	export("node1", "run1")
+	exporter := export("cluster", "run2")
+	if replaced := exporter.ReplaceUnchanged(producer).GetData(); replaced["pods"] == nil {
+		t.Errorf("expected data exported for a different node not to be replaced, found %v", replaced)
+	}
+
+	exporter = export("cluster", "run3")
+	replaced := exporter.ReplaceUnchanged(producer).GetData()
+	if _, ok := replaced["pods"]; ok || len(replaced) != 1 {
+		t.Fatalf("expected unchanged value to be replaced by its reference, found %v", replaced)
+	}
+
+	reader, err := replaced["pods"+ReferenceFileExtension].GetReader()
+	if err != nil {
+		t.Fatalf("error reading reference: %v", err)
+	}
+	defer reader.Close()
+
+	reference := &ContentReference{}
+	if err := json.NewDecoder(reader).Decode(reference); err != nil {
+		t.Fatalf("error decoding reference: %v", err)
+	}
+	if reference.Node != "cluster" || reference.RunId != "run2" {
+		t.Errorf("unexpected reference: %+v", reference)
+	}
+}
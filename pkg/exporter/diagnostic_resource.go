@@ -0,0 +1,120 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// DiagnosticGVR identifies the Diagnostic custom resource defined in deployment/base/crd.yaml.
+var DiagnosticGVR = schema.GroupVersionResource{Group: "aks-periscope.azure.github.com", Version: "v1", Resource: "diagnostics"}
+
+// DiagnosticResourceWriter creates or updates the Diagnostic custom resource for a node, so that diagnoser
+// results can be read with `kubectl get apd` without needing access to exported data.
+type DiagnosticResourceWriter struct {
+	client    dynamic.Interface
+	namespace string
+	name      string
+}
+
+func NewDiagnosticResourceWriter(client dynamic.Interface, namespace string, name string) *DiagnosticResourceWriter {
+	return &DiagnosticResourceWriter{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// Write sets each of the specified spec fields of the Diagnostic resource to the data of the corresponding producer,
+// creating the resource if it does not exist. Fields not included are left unchanged.
+func (writer *DiagnosticResourceWriter) Write(ctx context.Context, fields map[string]interfaces.DataProducer) error {
+	if writer.namespace == "" {
+		return fmt.Errorf("namespace not known for Diagnostic %s", writer.name)
+	}
+
+	spec := map[string]interface{}{}
+	for field, producer := range fields {
+		value, err := getFieldValue(field, producer)
+		if err != nil {
+			return fmt.Errorf("error reading data from %s for field %s: %w", producer.GetName(), field, err)
+		}
+		spec[field] = value
+	}
+
+	resourceClient := writer.client.Resource(DiagnosticGVR).Namespace(writer.namespace)
+
+	patch, err := json.Marshal(map[string]interface{}{"spec": spec})
+	if err != nil {
+		return fmt.Errorf("error serializing Diagnostic spec: %w", err)
+	}
+
+	_, err = resourceClient.Patch(ctx, writer.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err == nil || !k8sErrors.IsNotFound(err) {
+		return wrapDiagnosticError("patch", writer.name, err)
+	}
+
+	slog.Info("Creating Diagnostic resource", logging.ComponentKey, "diagnostic", "name", writer.name, "namespace", writer.namespace)
+	diagnostic := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": DiagnosticGVR.GroupVersion().String(),
+		"kind":       "Diagnostic",
+		"metadata": map[string]interface{}{
+			"name":      writer.name,
+			"namespace": writer.namespace,
+		},
+		"spec": spec,
+	}}
+
+	_, err = resourceClient.Create(ctx, diagnostic, metav1.CreateOptions{})
+	if err != nil && k8sErrors.IsAlreadyExists(err) {
+		// Created by someone else since we tried to patch it.
+		_, err = resourceClient.Patch(ctx, writer.name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return wrapDiagnosticError("patch", writer.name, err)
+	}
+
+	return wrapDiagnosticError("create", writer.name, err)
+}
+
+// getFieldValue reads the data from the producer as a string. If the producer has a single value with the same
+// name as the field (as the diagnosers do), that value is used as-is. Otherwise all the values are combined into
+// a JSON object, so the field can always be read with tools such as `jq`.
+func getFieldValue(field string, producer interfaces.DataProducer) (string, error) {
+	data := producer.GetData()
+	if value, ok := data[field]; ok && len(data) == 1 {
+		return utils.GetContent(func() (io.ReadCloser, error) { return value.GetReader() })
+	}
+
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		content, err := utils.GetContent(func() (io.ReadCloser, error) { return value.GetReader() })
+		if err != nil {
+			return "", err
+		}
+		values[key] = content
+	}
+
+	content, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+func wrapDiagnosticError(operation string, name string, err error) error {
+	if err != nil {
+		return fmt.Errorf("error trying to %s Diagnostic %s: %w", operation, name, err)
+	}
+	return nil
+}
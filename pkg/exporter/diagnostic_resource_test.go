@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestDiagnosticResourceWriterWrite(t *testing.T) {
+	dnsProducer := &testDataProducer{
+		name: "dns",
+		data: map[string]string{
+			"virtualmachine": "nameserver 1.1.1.1",
+			"kubernetes":     "nameserver 10.0.0.10",
+		},
+	}
+	networkConfigProducer := &testDataProducer{
+		name: "networkconfig",
+		data: map[string]string{
+			"networkconfig": `{"NetworkPlugin":"kubenet"}`,
+		},
+	}
+
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	writer := NewDiagnosticResourceWriter(client, "aks-periscope", "node1")
+
+	// First write creates the resource.
+	err := writer.Write(context.Background(), map[string]interfaces.DataProducer{"dns": dnsProducer})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// Second write updates it, leaving the existing field in place.
+	err = writer.Write(context.Background(), map[string]interfaces.DataProducer{"networkconfig": networkConfigProducer})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	diagnostic, err := client.Resource(DiagnosticGVR).Namespace("aks-periscope").Get(context.Background(), "node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting Diagnostic: %v", err)
+	}
+
+	spec, ok := diagnostic.Object["spec"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Diagnostic has no spec: %v", diagnostic.Object)
+	}
+
+	if spec["networkconfig"] != `{"NetworkPlugin":"kubenet"}` {
+		t.Errorf("unexpected networkconfig value: %v", spec["networkconfig"])
+	}
+
+	dnsValue, ok := spec["dns"].(string)
+	if !ok {
+		t.Fatalf("unexpected dns value: %v", spec["dns"])
+	}
+
+	dns := map[string]string{}
+	if err := json.Unmarshal([]byte(dnsValue), &dns); err != nil {
+		t.Fatalf("dns value is not a JSON object: %v", err)
+	}
+	if dns["kubernetes"] != "nameserver 10.0.0.10" || dns["virtualmachine"] != "nameserver 1.1.1.1" {
+		t.Errorf("unexpected dns value: %v", dns)
+	}
+}
+
+func TestDiagnosticResourceWriterNoNamespace(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	writer := NewDiagnosticResourceWriter(client, "", "node1")
+
+	err := writer.Write(context.Background(), map[string]interfaces.DataProducer{})
+	if err == nil {
+		t.Errorf("expected error writing without a namespace")
+	}
+}
@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/Azure/aks-periscope/pkg/encryption"
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// EncryptingExporter encrypts all data for the recipients before passing it to another exporter. Each value (and the
+// archive) is encrypted separately, and exported with the encryption file extension appended to its key.
+type EncryptingExporter struct {
+	exporter   interfaces.Exporter
+	recipients []*encryption.Recipient
+}
+
+func NewEncryptingExporter(exporter interfaces.Exporter, recipients []*encryption.Recipient) *EncryptingExporter {
+	return &EncryptingExporter{
+		exporter:   exporter,
+		recipients: recipients,
+	}
+}
+
+// Export implements the interface method
+func (exporter *EncryptingExporter) Export(ctx context.Context, producer interfaces.DataProducer) error {
+	return exporter.exporter.Export(ctx, exporter.encryptProducer(producer))
+}
+
+// ExportWithRecords exports the encrypted data, returning the upload records from the underlying exporter if it
+// produces them. The records are for the producer's keys (without the encryption file extension), so that they can be
+// matched with its data.
+func (exporter *EncryptingExporter) ExportWithRecords(ctx context.Context, producer interfaces.DataProducer) ([]*UploadRecord, error) {
+	recordingExporter, ok := exporter.exporter.(UploadRecordingExporter)
+	if !ok {
+		return nil, exporter.Export(ctx, producer)
+	}
+
+	records, err := recordingExporter.ExportWithRecords(ctx, exporter.encryptProducer(producer))
+	for _, record := range records {
+		record.Key = strings.TrimSuffix(record.Key, encryption.FileExtension)
+	}
+	return records, err
+}
+
+// ExportReader implements the interface method
+func (exporter *EncryptingExporter) ExportReader(ctx context.Context, name string, reader io.Reader) error {
+	encryptingReader, err := encryption.NewEncryptingReader(reader, exporter.recipients)
+	if err != nil {
+		return err
+	}
+	return exporter.exporter.ExportReader(ctx, name+encryption.FileExtension, encryptingReader)
+}
+
+func (exporter *EncryptingExporter) encryptProducer(producer interfaces.DataProducer) interfaces.DataProducer {
+	return &encryptedProducer{producer: producer, recipients: exporter.recipients}
+}
+
+type encryptedProducer struct {
+	producer   interfaces.DataProducer
+	recipients []*encryption.Recipient
+}
+
+func (p *encryptedProducer) GetName() string {
+	return p.producer.GetName()
+}
+
+func (p *encryptedProducer) GetData() map[string]interfaces.DataValue {
+	data := p.producer.GetData()
+	result := make(map[string]interfaces.DataValue, len(data))
+	for key, value := range data {
+		result[key+encryption.FileExtension] = &encryptedValue{value: value, recipients: p.recipients}
+	}
+	return result
+}
+
+type encryptedValue struct {
+	value      interfaces.DataValue
+	recipients []*encryption.Recipient
+}
+
+// GetLength returns the length of the unencrypted value, since the encrypted length depends on the header size.
+func (v *encryptedValue) GetLength() int64 {
+	return v.value.GetLength()
+}
+
+func (v *encryptedValue) GetReader() (io.ReadCloser, error) {
+	reader, err := v.value.GetReader()
+	if err != nil {
+		return nil, err
+	}
+
+	encryptingReader, err := encryption.NewEncryptingReader(reader, v.recipients)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{encryptingReader, reader}, nil
+}
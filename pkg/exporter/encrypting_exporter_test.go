@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/encryption"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+func TestEncryptingExporter(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "support"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	recipients, err := encryption.ParseRecipients(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	if err != nil {
+		t.Fatalf("error parsing recipients: %v", err)
+	}
+
+	directory := t.TempDir()
+	runtimeInfo := &utils.RuntimeInfo{HostNodeName: "node1"}
+	exporter := NewEncryptingExporter(NewLocalDirectoryExporter(runtimeInfo, directory, "run1"), recipients)
+
+	producer := &testDataProducer{name: "systemlogs", data: map[string]string{"journal": "journal content"}}
+	if err := exporter.Export(context.TODO(), producer); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if err := exporter.ExportReader(context.TODO(), "node1.zip", strings.NewReader("zip content")); err != nil {
+		t.Fatalf("ExportReader() error = %v", err)
+	}
+
+	expectedFiles := map[string]string{
+		"run1/node1/systemlogs/journal.enc": "journal content",
+		"run1/node1/node1.zip.enc":          "zip content",
+	}
+
+	for relativePath, expectedContent := range expectedFiles {
+		file, err := os.Open(filepath.Join(directory, filepath.FromSlash(relativePath)))
+		if err != nil {
+			t.Errorf("error opening %s: %v", relativePath, err)
+			continue
+		}
+		defer file.Close()
+
+		reader, err := encryption.NewDecryptingReader(file, privateKey)
+		if err != nil {
+			t.Errorf("error decrypting %s: %v", relativePath, err)
+			continue
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Errorf("error decrypting %s: %v", relativePath, err)
+			continue
+		}
+		if string(content) != expectedContent {
+			t.Errorf("unexpected content for %s.\nExpected '%s'\nFound '%s'", relativePath, expectedContent, string(content))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// LocalDirectoryExporter defines an exporter that writes data to a directory on the local file system,
+// which may be a hostPath or persistent volume mounted into the container.
+type LocalDirectoryExporter struct {
+	runtimeInfo *utils.RuntimeInfo
+	directory   string
+	runId       string
+}
+
+func NewLocalDirectoryExporter(runtimeInfo *utils.RuntimeInfo, directory string, runId string) *LocalDirectoryExporter {
+	return &LocalDirectoryExporter{
+		runtimeInfo: runtimeInfo,
+		directory:   directory,
+		runId:       runId,
+	}
+}
+
+// Export implements the interface method. Each value is written to <directory>/<runId>/<node>/<producer>/<key>.
+func (exporter *LocalDirectoryExporter) Export(ctx context.Context, producer interfaces.DataProducer) error {
+	for key, value := range producer.GetData() {
+		filePath, err := exporter.getFilePath(producer.GetName(), key)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Writing file", logging.ComponentKey, "localdirectory", "path", filePath, "size", value.GetLength())
+
+		err = func() error {
+			valueReadCloser, err := value.GetReader()
+			if err != nil {
+				return err
+			}
+
+			defer valueReadCloser.Close()
+
+			return writeFile(ctx, filePath, valueReadCloser)
+		}()
+
+		if err != nil {
+			return fmt.Errorf("write file %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// ExportReader writes the content of the reader to <directory>/<runId>/<node>/<name>.
+func (exporter *LocalDirectoryExporter) ExportReader(ctx context.Context, name string, reader io.Reader) error {
+	filePath, err := exporter.getFilePath(name)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Writing file", logging.ComponentKey, "localdirectory", "path", filePath)
+	return writeFile(ctx, filePath, reader)
+}
+
+func (exporter *LocalDirectoryExporter) getFilePath(pathParts ...string) (string, error) {
+	if exporter.directory == "" {
+		slog.Warn("Local export directory was not provided, export to local directory will be skipped", logging.ComponentKey, "localdirectory")
+		return "", errors.New("Local export directory not configured.")
+	}
+
+	nodeDirectory := filepath.Join(exporter.directory, exporter.runId, exporter.runtimeInfo.HostNodeName)
+
+	// Keys are forward-slash-separated, and may come from data we don't control (e.g. pod names),
+	// so make sure they can't be used to write outside the node's own directory.
+	relativePath := filepath.FromSlash(strings.Join(pathParts, "/"))
+	filePath := filepath.Join(nodeDirectory, relativePath)
+	if !strings.HasPrefix(filePath, nodeDirectory+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside export directory %s", relativePath, nodeDirectory)
+	}
+
+	return filePath, nil
+}
+
+// writeFile writes the content of the reader to the file, stopping if the context is done (e.g. because the volume is
+// too slow to finish before the pod is terminated).
+func writeFile(ctx context.Context, filePath string, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", filePath, err)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %w", filePath, err)
+	}
+
+	if _, err := io.Copy(file, &contextReader{ctx: ctx, reader: reader}); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing data to file %s: %w", filePath, err)
+	}
+
+	// Write errors may only be reported when the file is closed (e.g. on network-backed volumes).
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error closing file %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
+// contextReader reads from the underlying reader until the context is done.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
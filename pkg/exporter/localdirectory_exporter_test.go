@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+type testDataProducer struct {
+	name string
+	data map[string]string
+}
+
+func (p *testDataProducer) GetName() string {
+	return p.name
+}
+
+func (p *testDataProducer) GetData() map[string]interfaces.DataValue {
+	return utils.ToDataValueMap(p.data)
+}
+
+func TestLocalDirectoryExporterExport(t *testing.T) {
+	tests := []struct {
+		name      string
+		producer  *testDataProducer
+		wantErr   bool
+		wantFiles map[string]string
+	}{
+		{
+			name: "flat keys",
+			producer: &testDataProducer{
+				name: "dns",
+				data: map[string]string{
+					"virtualmachine": "vm content",
+					"kubernetes":     "k8s content",
+				},
+			},
+			wantErr: false,
+			wantFiles: map[string]string{
+				"run1/node1/dns/virtualmachine": "vm content",
+				"run1/node1/dns/kubernetes":     "k8s content",
+			},
+		},
+		{
+			name: "nested keys",
+			producer: &testDataProducer{
+				name: "osm",
+				data: map[string]string{
+					"mesh/envoy/podconfig_dump": "envoy content",
+				},
+			},
+			wantErr: false,
+			wantFiles: map[string]string{
+				"run1/node1/osm/mesh/envoy/podconfig_dump": "envoy content",
+			},
+		},
+		{
+			name: "key outside node directory",
+			producer: &testDataProducer{
+				name: "bad",
+				data: map[string]string{
+					"../../../escaped": "content",
+				},
+			},
+			wantErr:   true,
+			wantFiles: map[string]string{},
+		},
+	}
+
+	runtimeInfo := &utils.RuntimeInfo{HostNodeName: "node1"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			exporter := NewLocalDirectoryExporter(runtimeInfo, directory, "run1")
+
+			err := exporter.Export(context.TODO(), tt.producer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Export() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for relativePath, expectedContent := range tt.wantFiles {
+				content, err := os.ReadFile(filepath.Join(directory, filepath.FromSlash(relativePath)))
+				if err != nil {
+					t.Errorf("error reading %s: %v", relativePath, err)
+					continue
+				}
+				if string(content) != expectedContent {
+					t.Errorf("unexpected content for %s.\nExpected '%s'\nFound '%s'", relativePath, expectedContent, string(content))
+				}
+			}
+		})
+	}
+}
+
+func TestLocalDirectoryExporterExportReader(t *testing.T) {
+	const expectedContent = "zip content"
+
+	directory := t.TempDir()
+	runtimeInfo := &utils.RuntimeInfo{HostNodeName: "node1"}
+	exporter := NewLocalDirectoryExporter(runtimeInfo, directory, "run1")
+
+	if err := exporter.ExportReader(context.TODO(), "node1.zip", strings.NewReader(expectedContent)); err != nil {
+		t.Fatalf("ExportReader() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(directory, "run1", "node1", "node1.zip"))
+	if err != nil {
+		t.Fatalf("error reading exported file: %v", err)
+	}
+	if string(content) != expectedContent {
+		t.Errorf("unexpected content.\nExpected '%s'\nFound '%s'", expectedContent, string(content))
+	}
+}
+
+func TestLocalDirectoryExporterNotConfigured(t *testing.T) {
+	runtimeInfo := &utils.RuntimeInfo{HostNodeName: "node1"}
+	exporter := NewLocalDirectoryExporter(runtimeInfo, "", "run1")
+
+	producer := &testDataProducer{name: "dns", data: map[string]string{"kubernetes": "content"}}
+	if err := exporter.Export(context.TODO(), producer); err == nil {
+		t.Errorf("expected error exporting without a configured directory")
+	}
+}
@@ -0,0 +1,298 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// ManifestFileName is the name of the archive entry containing the run manifest.
+const ManifestFileName = "manifest.json"
+
+type ProducerType string
+
+const (
+	CollectorProducer ProducerType = "collector"
+	DiagnoserProducer ProducerType = "diagnoser"
+	// LogProducer is the type of the producer of Periscope's own log for the run.
+	LogProducer ProducerType = "log"
+)
+
+// Manifest describes the outcome of a Periscope run, including every producer (whether or not it ran successfully)
+// and every artifact it produced. This allows a missing artifact to be distinguished from a failed producer.
+type Manifest struct {
+	RunId        string `json:"runId"`
+	HostNodeName string `json:"hostNodeName"`
+	// Partial is set if the run was cancelled (e.g. because the pod was terminated) before all producers completed.
+	Partial bool `json:"partial,omitempty"`
+	// Error is set if the run failed before running any producers, e.g. because of an invalid config value.
+	Error     string            `json:"error,omitempty"`
+	Producers []*ProducerRecord `json:"producers"`
+	// RedactionRules lists the rules applied to the data, and Redactions the number of values each rule redacted.
+	RedactionRules []string       `json:"redactionRules"`
+	Redactions     map[string]int `json:"redactions"`
+
+	lock            sync.Mutex
+	producerRecords map[interfaces.DataProducer]*ProducerRecord
+}
+
+// ProducerRecord describes the outcome of each step run for a single DataProducer, along with its artifacts.
+// Steps that were not run are omitted.
+type ProducerRecord struct {
+	Name           string              `json:"name"`
+	Type           ProducerType        `json:"type"`
+	CheckSupported *StepRecord         `json:"checkSupported,omitempty"`
+	Collect        *StepRecord         `json:"collect,omitempty"`
+	Diagnose       *StepRecord         `json:"diagnose,omitempty"`
+	Export         *StepRecord         `json:"export,omitempty"`
+	Artifacts      []*ArtifactRecord   `json:"artifacts"`
+	Uploads        []*UploadRecord     `json:"uploads,omitempty"`
+	Truncations    []*TruncationRecord `json:"truncations,omitempty"`
+	// Partial is set if the producer was cancelled, and the data it had produced up to that point was exported.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Succeeded returns true if the producer ran successfully, i.e. it was supported and its data was collected or
+// diagnosed without error. Export is not taken into account, since the data is still valid if export failed.
+func (r *ProducerRecord) Succeeded() bool {
+	if r.CheckSupported != nil && !r.CheckSupported.Succeeded {
+		return false
+	}
+
+	switch r.Type {
+	case CollectorProducer:
+		return r.Collect != nil && r.Collect.Succeeded
+	case DiagnoserProducer:
+		return r.Diagnose != nil && r.Diagnose.Succeeded
+	default:
+		return false
+	}
+}
+
+// StepRecord describes the outcome of a single step (e.g. collecting or exporting data) for a DataProducer.
+type StepRecord struct {
+	Succeeded bool      `json:"succeeded"`
+	TimedOut  bool      `json:"timedOut,omitempty"`
+	Cancelled bool      `json:"cancelled,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Start     time.Time `json:"start"`
+	Duration  string    `json:"duration"`
+}
+
+// GetDuration gets the duration of the step, or zero if it can't be parsed.
+func (r *StepRecord) GetDuration() time.Duration {
+	duration, _ := time.ParseDuration(r.Duration)
+	return duration
+}
+
+// ArtifactRecord describes a single data value within the archive.
+type ArtifactRecord struct {
+	Key    string `json:"key"`
+	Length int64  `json:"length"`
+	Sha256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UploadRecord describes the outcome of uploading a single data value to remote storage. A value which was unchanged
+// since a previous run is not uploaded, and instead records the run in which its content was uploaded.
+type UploadRecord struct {
+	Key            string `json:"key"`
+	Succeeded      bool   `json:"succeeded"`
+	Attempts       int    `json:"attempts"`
+	Error          string `json:"error,omitempty"`
+	Duration       string `json:"duration"`
+	ReferenceRunId string `json:"referenceRunId,omitempty"`
+}
+
+// TruncationRecord describes a data value which exceeded its size budget, and had the start of its data dropped.
+type TruncationRecord struct {
+	Key          string `json:"key"`
+	Length       int64  `json:"length"`
+	Limit        int64  `json:"limit"`
+	DroppedBytes int64  `json:"droppedBytes"`
+}
+
+func NewManifest(runId, hostNodeName string) *Manifest {
+	return &Manifest{
+		RunId:           runId,
+		HostNodeName:    hostNodeName,
+		Producers:       []*ProducerRecord{},
+		RedactionRules:  []string{},
+		Redactions:      map[string]int{},
+		producerRecords: map[interfaces.DataProducer]*ProducerRecord{},
+	}
+}
+
+// AddProducer adds a record for the specified DataProducer to the manifest. The returned record is owned by the
+// caller, which is responsible for populating its steps.
+func (m *Manifest) AddProducer(producerType ProducerType, producer interfaces.DataProducer) *ProducerRecord {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	record := &ProducerRecord{
+		Name:      producer.GetName(),
+		Type:      producerType,
+		Artifacts: []*ArtifactRecord{},
+	}
+
+	m.Producers = append(m.Producers, record)
+	m.producerRecords[producer] = record
+	return record
+}
+
+// AddSkippedProducer adds a record for a producer that was not created, for example because a producer it depends on
+// did not succeed. The reason is recorded as a failed CheckSupported step.
+func (m *Manifest) AddSkippedProducer(producerType ProducerType, name string, reason error) *ProducerRecord {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	record := &ProducerRecord{
+		Name: name,
+		Type: producerType,
+		CheckSupported: &StepRecord{
+			Succeeded: false,
+			Error:     reason.Error(),
+			Start:     time.Now().UTC(),
+			Duration:  time.Duration(0).String(),
+		},
+		Artifacts: []*ArtifactRecord{},
+	}
+
+	m.Producers = append(m.Producers, record)
+	return record
+}
+
+// AddArtifact records an artifact for a DataProducer which has previously been added to the manifest.
+// A producer wrapping another (e.g. to redact or truncate its data) has its artifacts recorded against the wrapped
+// producer.
+func (m *Manifest) AddArtifact(producer interfaces.DataProducer, artifact *ArtifactRecord) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	record := m.getRecord(producer)
+	record.Artifacts = append(record.Artifacts, artifact)
+}
+
+// AddTruncation records that a value of a DataProducer which has previously been added to the manifest was truncated.
+func (m *Manifest) AddTruncation(producer interfaces.DataProducer, truncation *TruncationRecord) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	record := m.getRecord(producer)
+	record.Truncations = append(record.Truncations, truncation)
+}
+
+// getRecord gets the record for the producer, or for the producer it wraps (possibly through several wrappers).
+func (m *Manifest) getRecord(producer interfaces.DataProducer) *ProducerRecord {
+	for {
+		wrapper, ok := producer.(interface {
+			Unwrap() interfaces.DataProducer
+		})
+		if !ok {
+			break
+		}
+		producer = wrapper.Unwrap()
+	}
+
+	record, ok := m.producerRecords[producer]
+	if !ok {
+		// Not expected, but we still want the data to be listed.
+		record = &ProducerRecord{Name: producer.GetName(), Artifacts: []*ArtifactRecord{}}
+		m.Producers = append(m.Producers, record)
+		m.producerRecords[producer] = record
+	}
+
+	return record
+}
+
+// SetRedactionRules records the names of the redaction rules applied to the data.
+func (m *Manifest) SetRedactionRules(names []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.RedactionRules = names
+}
+
+// SetError records that the run failed before running any producers.
+func (m *Manifest) SetError(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.Error = err.Error()
+}
+
+// SetPartial records that the run was cancelled before all producers completed.
+func (m *Manifest) SetPartial() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.Partial = true
+}
+
+// AddRedactions records that the named redaction rule redacted the specified number of values.
+func (m *Manifest) AddRedactions(rule string, count int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.Redactions[rule] += count
+}
+
+// GetProducerNames gets the sorted names of the producers whose records match the filter. Since records are populated
+// by their callers, this should only be used once the producers have completed.
+func (m *Manifest) GetProducerNames(filter func(*ProducerRecord) bool) []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := []string{}
+	for _, record := range m.Producers {
+		if filter(record) {
+			names = append(names, record.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Marshal serializes the manifest as formatted JSON, with artifacts in a consistent order.
+func (m *Manifest) Marshal() ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, record := range m.Producers {
+		sort.Slice(record.Artifacts, func(i, j int) bool {
+			return record.Artifacts[i].Key < record.Artifacts[j].Key
+		})
+		sort.Slice(record.Uploads, func(i, j int) bool {
+			return record.Uploads[i].Key < record.Uploads[j].Key
+		})
+		sort.Slice(record.Truncations, func(i, j int) bool {
+			return record.Truncations[i].Key < record.Truncations[j].Key
+		})
+	}
+
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// RecordStep runs the specified step, returning a record of its outcome along with any error.
+func RecordStep(step func() error) (*StepRecord, error) {
+	start := time.Now()
+	err := step()
+
+	record := &StepRecord{
+		Succeeded: err == nil,
+		Start:     start.UTC(),
+		Duration:  time.Since(start).String(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+		record.TimedOut = errors.Is(err, context.DeadlineExceeded)
+		record.Cancelled = errors.Is(err, context.Canceled)
+	}
+
+	return record, err
+}
@@ -0,0 +1,299 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+// s3DefaultRegion is used for signing requests when no region is configured. S3-compatible stores such as MinIO
+// accept this by default.
+const s3DefaultRegion = "us-east-1"
+
+// s3PartSize is the size of each part of a multipart upload. Data smaller than this is uploaded in a single request.
+// S3 requires all parts except the last to be at least 5MiB.
+const s3PartSize = 8 * 1024 * 1024
+
+// S3Exporter defines an exporter that uploads data to an S3-compatible object store (such as MinIO), using the same
+// <runId>/<node>/<key> layout as the AzureBlobExporter. Objects are addressed using path-style URLs.
+type S3Exporter struct {
+	runtimeInfo *utils.RuntimeInfo
+	runId       string
+	client      *http.Client
+	partSize    int
+}
+
+func NewS3Exporter(runtimeInfo *utils.RuntimeInfo, runId string) *S3Exporter {
+	return &S3Exporter{
+		runtimeInfo: runtimeInfo,
+		runId:       runId,
+		client:      &http.Client{Timeout: runtimeInfo.GetUploadOptions().TryTimeout},
+		partSize:    s3PartSize,
+	}
+}
+
+type s3ErrorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// s3Error is returned for requests that the store responded to with an error status.
+type s3Error struct {
+	StatusCode int
+	Code       string
+	message    string
+}
+
+func (err *s3Error) Error() string {
+	return err.message
+}
+
+type s3InitiateMultipartUploadResult struct {
+	UploadId string `xml:"UploadId"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+// Export implements the interface method
+func (exporter *S3Exporter) Export(ctx context.Context, producer interfaces.DataProducer) error {
+	_, err := exporter.ExportWithRecords(ctx, producer)
+	return err
+}
+
+// ExportWithRecords uploads each key of the producer's data, retrying keys that fail with a transient error, and
+// returns a record of the outcome of each upload.
+func (exporter *S3Exporter) ExportWithRecords(ctx context.Context, producer interfaces.DataProducer) ([]*UploadRecord, error) {
+	if err := exporter.checkConfigured(); err != nil {
+		return nil, err
+	}
+
+	logger := slog.With(logging.ComponentKey, "s3")
+	return uploadAll(ctx, logger, producer, exporter.runtimeInfo.GetUploadOptions(), isTransientS3Error, func(ctx context.Context, key string, value interfaces.DataValue) error {
+		logger.Info("Uploading object", "key", key, "size", value.GetLength())
+
+		valueReadCloser, err := value.GetReader()
+		if err != nil {
+			return err
+		}
+
+		defer valueReadCloser.Close()
+
+		return exporter.upload(ctx, exporter.do, key, valueReadCloser)
+	})
+}
+
+// ExportReader uploads the content of the reader to <runId>/<node>/<name>. The reader can't be re-read, so the upload
+// isn't retried as a whole, but each request (and so each part of a multipart upload) is retried.
+func (exporter *S3Exporter) ExportReader(ctx context.Context, name string, reader io.Reader) error {
+	if err := exporter.checkConfigured(); err != nil {
+		return err
+	}
+
+	slog.Info("Uploading object", logging.ComponentKey, "s3", "key", name)
+	return exporter.upload(ctx, exporter.doWithRetry, name, reader)
+}
+
+func (exporter *S3Exporter) checkConfigured() error {
+	info := exporter.runtimeInfo
+	if info.S3Endpoint == "" || info.S3Bucket == "" || info.S3AccessKeyId == "" || info.S3SecretAccessKey == "" {
+		slog.Warn("S3 storage information was not provided, export to S3 will be skipped", logging.ComponentKey, "s3")
+		return errors.New("S3 storage not configured.")
+	}
+	return nil
+}
+
+// s3SendFunc sends a signed request for the specified object, returning the response if it succeeded.
+type s3SendFunc func(ctx context.Context, method, objectKey string, query url.Values, body []byte) (*s3Response, error)
+
+// upload writes the data to the object with the specified key, sending each request with send. The data is read in
+// parts so that its length doesn't need to be known in advance: if it fits in a single part it is uploaded with one
+// request, otherwise it is uploaded as a multipart upload.
+func (exporter *S3Exporter) upload(ctx context.Context, send s3SendFunc, key string, reader io.Reader) error {
+	objectKey := fmt.Sprintf("%s/%s/%s", exporter.runId, exporter.runtimeInfo.HostNodeName, key)
+
+	buffer := make([]byte, exporter.partSize)
+	n, err := io.ReadFull(reader, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = send(ctx, http.MethodPut, objectKey, nil, buffer[:n])
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("error reading data: %w", err)
+	}
+
+	response, err := send(ctx, http.MethodPost, objectKey, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return fmt.Errorf("error creating multipart upload: %w", err)
+	}
+
+	initiateResult := &s3InitiateMultipartUploadResult{}
+	if err := xml.Unmarshal(response.body, initiateResult); err != nil || initiateResult.UploadId == "" {
+		return fmt.Errorf("unexpected response creating multipart upload: %s", string(response.body))
+	}
+
+	uploadId := initiateResult.UploadId
+	err = exporter.uploadParts(ctx, send, objectKey, uploadId, buffer, n, reader)
+	if err != nil {
+		// Don't leave the uploaded parts taking up space in the bucket.
+		if _, abortErr := send(ctx, http.MethodDelete, objectKey, url.Values{"uploadId": {uploadId}}, nil); abortErr != nil {
+			slog.Warn("Could not abort multipart upload", logging.ComponentKey, "s3", "object", objectKey, logging.Error(abortErr))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (exporter *S3Exporter) uploadParts(ctx context.Context, send s3SendFunc, objectKey, uploadId string, buffer []byte, n int, reader io.Reader) error {
+	complete := &s3CompleteMultipartUpload{}
+	for partNumber := 1; n > 0; partNumber++ {
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadId},
+		}
+		response, err := send(ctx, http.MethodPut, objectKey, query, buffer[:n])
+		if err != nil {
+			return fmt.Errorf("error uploading part %d: %w", partNumber, err)
+		}
+
+		complete.Parts = append(complete.Parts, s3CompletedPart{PartNumber: partNumber, ETag: response.header.Get("ETag")})
+
+		n, err = io.ReadFull(reader, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("error reading data: %w", err)
+		}
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return fmt.Errorf("error serializing parts: %w", err)
+	}
+
+	response, err := send(ctx, http.MethodPost, objectKey, url.Values{"uploadId": {uploadId}}, body)
+	if err != nil {
+		return fmt.Errorf("error completing multipart upload: %w", err)
+	}
+
+	// Completion can fail after a 200 response has been sent, in which case the body contains an error.
+	errorResponse := &s3ErrorResponse{}
+	if xml.Unmarshal(response.body, errorResponse) == nil && errorResponse.Code != "" {
+		return fmt.Errorf("error completing multipart upload: %s: %s", errorResponse.Code, errorResponse.Message)
+	}
+
+	return nil
+}
+
+type s3Response struct {
+	header http.Header
+	body   []byte
+}
+
+// doWithRetry sends the request with do, retrying it while it fails with a transient error.
+func (exporter *S3Exporter) doWithRetry(ctx context.Context, method, objectKey string, query url.Values, body []byte) (*s3Response, error) {
+	var response *s3Response
+	logger := slog.With(logging.ComponentKey, "s3", "object", objectKey, "method", method)
+	_, err := uploadWithRetry(ctx, logger, exporter.runtimeInfo.GetUploadOptions(), isTransientS3Error, func(ctx context.Context) error {
+		var err error
+		response, err = exporter.do(ctx, method, objectKey, query, body)
+		return err
+	})
+	return response, err
+}
+
+// do sends a signed request for the specified object, returning the response if it succeeded.
+func (exporter *S3Exporter) do(ctx context.Context, method, objectKey string, query url.Values, body []byte) (*s3Response, error) {
+	info := exporter.runtimeInfo
+	endpoint, err := url.Parse(strings.TrimSuffix(info.S3Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %s: %w", info.S3Endpoint, err)
+	}
+
+	objectPath := "/" + info.S3Bucket + "/" + objectKey
+	requestURL := &url.URL{
+		Scheme:   endpoint.Scheme,
+		Host:     endpoint.Host,
+		Path:     endpoint.Path + objectPath,
+		RawPath:  endpoint.EscapedPath() + s3Escape(objectPath, false),
+		RawQuery: strings.ReplaceAll(query.Encode(), "+", "%20"),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	region := info.S3Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+
+	credentials := &s3Credentials{
+		accessKeyId:     info.S3AccessKeyId,
+		secretAccessKey: info.S3SecretAccessKey,
+		region:          region,
+	}
+	signS3Request(req, credentials, sha256Hex(body), time.Now())
+
+	resp, err := exporter.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errorResponse := &s3ErrorResponse{}
+		if xml.Unmarshal(responseBody, errorResponse) == nil && errorResponse.Code != "" {
+			return nil, &s3Error{
+				StatusCode: resp.StatusCode,
+				Code:       errorResponse.Code,
+				message:    fmt.Sprintf("%s %s: %s: %s", method, objectPath, errorResponse.Code, errorResponse.Message),
+			}
+		}
+		return nil, &s3Error{
+			StatusCode: resp.StatusCode,
+			message:    fmt.Sprintf("%s %s: unexpected status %s", method, objectPath, resp.Status),
+		}
+	}
+
+	return &s3Response{header: resp.Header, body: responseBody}, nil
+}
+
+// isTransientS3Error returns true if the error may not recur if the request is retried, such as a throttling
+// (429 or SlowDown) or server error response, or a network error.
+func isTransientS3Error(err error) bool {
+	var s3Err *s3Error
+	if errors.As(err, &s3Err) {
+		status := s3Err.StatusCode
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError || s3Err.Code == "SlowDown"
+	}
+
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
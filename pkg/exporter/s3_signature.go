@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4, as described in:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+// This is implemented directly rather than through an SDK because only a handful of S3 operations are needed.
+const (
+	s3SigningAlgorithm  = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat     = "20060102T150405Z"
+	s3ShortDateFormat   = "20060102"
+	s3ContentSha256Name = "X-Amz-Content-Sha256"
+	s3DateName          = "X-Amz-Date"
+)
+
+type s3Credentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	region          string
+}
+
+// signS3Request adds the headers needed to authenticate the request, including an Authorization header containing a
+// signature of the request method, path, query, headers and payload hash.
+func signS3Request(req *http.Request, credentials *s3Credentials, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(s3AmzDateFormat)
+	req.Header.Set(s3DateName, amzDate)
+	req.Header.Set(s3ContentSha256Name, payloadHash)
+
+	signedHeaders, canonicalHeaders := getCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		getCanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.UTC().Format(s3ShortDateFormat), credentials.region)
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+credentials.secretAccessKey), now.UTC().Format(s3ShortDateFormat))
+	signingKey = hmacSha256(signingKey, credentials.region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, credentials.accessKeyId, scope, signedHeaders, signature))
+}
+
+// getCanonicalHeaders returns the names of the signed headers, and the canonical form of those headers.
+// The host is always signed, along with the x-amz-* headers and any standard headers that affect the content.
+func getCanonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(name, "x-amz-"), name == "content-type", name == "content-md5", name == "range":
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+
+	return strings.Join(names, ";"), canonicalHeaders.String()
+}
+
+func getCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// s3Escape URI-encodes every byte except the unreserved characters, and optionally the path separator.
+func s3Escape(value string, encodeSlash bool) string {
+	result := new(strings.Builder)
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			result.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			result.WriteByte(b)
+		default:
+			fmt.Fprintf(result, "%%%02X", b)
+		}
+	}
+	return result.String()
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
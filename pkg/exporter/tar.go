@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// maxSpoolMemory is the most data held in memory for a single tar entry. Larger entries are spooled to a temporary
+// file, since a tar header includes the entry size, which isn't known until the data has been read.
+const maxSpoolMemory = 1024 * 1024
+
+// tarArchiveWriter writes a tar archive through a compressor, storing entries which are already compressed without
+// compressing them again.
+type tarArchiveWriter struct {
+	writer     *tar.Writer
+	compressor *switchingCompressor
+	modTime    time.Time
+}
+
+func newTarArchiveWriter(compressor *switchingCompressor) *tarArchiveWriter {
+	return &tarArchiveWriter{
+		writer:     tar.NewWriter(compressor),
+		compressor: compressor,
+		modTime:    time.Now(),
+	}
+}
+
+func (a *tarArchiveWriter) WriteEntry(name string, reader io.Reader) (int64, error) {
+	reader, compressed := detectCompressed(name, reader)
+
+	data, readErr := spool(reader)
+	if data == nil {
+		return 0, readErr
+	}
+	defer data.close()
+
+	// Finish the previous entry before switching compression, so that each entry is compressed as a whole.
+	if err := a.writer.Flush(); err != nil {
+		return 0, err
+	}
+	if err := a.compressor.SetCompression(!compressed); err != nil {
+		return 0, err
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     data.size,
+		Mode:     0644,
+		ModTime:  a.modTime,
+	}
+	if err := a.writer.WriteHeader(header); err != nil {
+		return 0, err
+	}
+
+	dataReader, err := data.reader()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(a.writer, dataReader); err != nil {
+		return 0, err
+	}
+
+	return data.size, readErr
+}
+
+func (a *tarArchiveWriter) Close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+	return a.compressor.Close()
+}
+
+// spooledData holds data in memory up to a limit, and in a temporary file beyond that.
+type spooledData struct {
+	buffer *bytes.Buffer
+	file   *os.File
+	size   int64
+}
+
+// spool reads all the data from the reader. If reading fails, the data read so far is returned along with the error.
+// The returned data is nil if it couldn't be stored.
+func spool(reader io.Reader) (*spooledData, error) {
+	data := &spooledData{buffer: new(bytes.Buffer)}
+
+	var err error
+	data.size, err = io.CopyN(data.buffer, reader, maxSpoolMemory)
+	if err == io.EOF {
+		return data, nil
+	}
+	if err != nil {
+		return data, err
+	}
+
+	data.file, err = os.CreateTemp("", "periscope-archive-")
+	if err != nil {
+		return nil, err
+	}
+
+	fileWriter := &spoolFileWriter{file: data.file}
+	fileSize, err := io.Copy(fileWriter, reader)
+	data.size += fileSize
+	if fileWriter.err != nil {
+		// The temporary file couldn't be written, so the data is incomplete.
+		data.close()
+		return nil, fileWriter.err
+	}
+
+	return data, err
+}
+
+// spoolFileWriter records write errors, to distinguish them from errors reading the data being spooled.
+type spoolFileWriter struct {
+	file *os.File
+	err  error
+}
+
+func (w *spoolFileWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (s *spooledData) reader() (io.Reader, error) {
+	if s.file == nil {
+		return s.buffer, nil
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.MultiReader(s.buffer, s.file), nil
+}
+
+func (s *spooledData) close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/logging"
+	"github.com/Azure/aks-periscope/pkg/utils"
+	"github.com/hashicorp/go-multierror"
+)
+
+// UploadRecordingExporter is implemented by exporters that record the outcome of uploading each key of a producer's
+// data, so that the records can be included in the manifest.
+type UploadRecordingExporter interface {
+	interfaces.Exporter
+	ExportWithRecords(ctx context.Context, producer interfaces.DataProducer) ([]*UploadRecord, error)
+}
+
+// uploadFunc uploads a single data value to the specified key.
+type uploadFunc func(ctx context.Context, key string, value interfaces.DataValue) error
+
+// uploadAll uploads every data value of the producer, retrying each key that fails with a transient error.
+// A key that can't be uploaded doesn't prevent the remaining keys from being uploaded, unless the context is done.
+func uploadAll(ctx context.Context, logger *slog.Logger, producer interfaces.DataProducer, options *utils.UploadOptions, isTransient func(error) bool, upload uploadFunc) ([]*UploadRecord, error) {
+	var errs error
+	records := []*UploadRecord{}
+	for key, value := range producer.GetData() {
+		if err := ctx.Err(); err != nil {
+			records = append(records, &UploadRecord{Key: key, Error: err.Error(), Duration: time.Duration(0).String()})
+			errs = multierror.Append(errs, fmt.Errorf("upload %s: %w", key, err))
+			continue
+		}
+
+		start := time.Now()
+		attempts, err := uploadWithRetry(ctx, logger.With("key", key), options, isTransient, func(ctx context.Context) error {
+			return upload(ctx, key, value)
+		})
+
+		record := &UploadRecord{
+			Key:       key,
+			Succeeded: err == nil,
+			Attempts:  attempts,
+			Duration:  time.Since(start).String(),
+		}
+		if err != nil {
+			record.Error = err.Error()
+			errs = multierror.Append(errs, fmt.Errorf("upload %s: %w", key, err))
+		}
+		records = append(records, record)
+	}
+
+	return records, errs
+}
+
+// uploadWithRetry runs the upload, retrying with exponential backoff while it fails with a transient error, up to the
+// maximum number of tries. The number of attempts is returned along with the error from the last attempt.
+func uploadWithRetry(ctx context.Context, logger *slog.Logger, options *utils.UploadOptions, isTransient func(error) bool, upload func(context.Context) error) (int, error) {
+	attempt := 1
+	for {
+		err := upload(ctx)
+		if err == nil || attempt >= options.MaxTries || !isTransient(err) || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		// Add jitter so that nodes which failed at the same time (e.g. due to throttling) don't retry in lockstep.
+		delay := options.GetRetryDelay(attempt)
+		delay = time.Duration(float64(delay) * (0.8 + 0.4*rand.Float64()))
+		logger.Warn("Upload attempt failed with transient error, retrying", "attempt", attempt, "retryDelay", delay.Round(time.Millisecond).Seconds(), logging.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+		attempt++
+	}
+}
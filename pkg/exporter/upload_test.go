@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+var errTransient = errors.New("transient")
+var errPermanent = errors.New("permanent")
+
+func TestUploadAll(t *testing.T) {
+	options := &utils.UploadOptions{MaxTries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: 4 * time.Millisecond}
+	isTransient := func(err error) bool { return errors.Is(err, errTransient) }
+
+	// The errors returned by each successive attempt to upload each key, with nil indicating success.
+	attemptResults := map[string][]error{
+		"immediate": {nil},
+		"flaky":     {errTransient, errTransient, nil},
+		"exhausted": {errTransient, errTransient, errTransient, nil},
+		"permanent": {errTransient, errPermanent, nil},
+	}
+
+	expected := map[string]*UploadRecord{
+		"immediate": {Key: "immediate", Succeeded: true, Attempts: 1},
+		"flaky":     {Key: "flaky", Succeeded: true, Attempts: 3},
+		"exhausted": {Key: "exhausted", Succeeded: false, Attempts: 3, Error: errTransient.Error()},
+		"permanent": {Key: "permanent", Succeeded: false, Attempts: 2, Error: errPermanent.Error()},
+	}
+
+	producer := &testDataProducer{name: "test", data: map[string]string{}}
+	for key := range attemptResults {
+		producer.data[key] = "content"
+	}
+
+	attempts := map[string]int{}
+	records, err := uploadAll(context.TODO(), slog.Default(), producer, options, isTransient, func(ctx context.Context, key string, value interfaces.DataValue) error {
+		attempts[key]++
+		return attemptResults[key][attempts[key]-1]
+	})
+
+	if err == nil {
+		t.Errorf("expected error for failed uploads")
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, found %d", len(expected), len(records))
+	}
+
+	for _, record := range records {
+		want := expected[record.Key]
+		if record.Succeeded != want.Succeeded || record.Attempts != want.Attempts || record.Error != want.Error {
+			t.Errorf("unexpected record for %s: expected %+v, found %+v", record.Key, want, record)
+		}
+	}
+}
+
+func TestUploadWithRetryCancelled(t *testing.T) {
+	options := &utils.UploadOptions{MaxTries: 5, RetryDelay: time.Hour, MaxRetryDelay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	attempts, err := uploadWithRetry(ctx, slog.Default(), options, func(error) bool { return true }, func(context.Context) error { return errTransient })
+	if !errors.Is(err, errTransient) {
+		t.Errorf("expected transient error, found %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected a single attempt before cancellation, found %d", attempts)
+	}
+}
+
+func TestUploadAllCancelled(t *testing.T) {
+	options := &utils.UploadOptions{MaxTries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond}
+	producer := &testDataProducer{name: "test", data: map[string]string{"key1": "content", "key2": "content"}}
+
+	// Once the context is done (e.g. at the shutdown deadline), the remaining keys are recorded as not uploaded.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	records, err := uploadAll(ctx, slog.Default(), producer, options, func(error) bool { return true }, func(context.Context, string, interfaces.DataValue) error {
+		t.Errorf("unexpected upload after cancellation")
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, found %v", err)
+	}
+	for _, record := range records {
+		if record.Succeeded || record.Attempts != 0 || record.Error == "" {
+			t.Errorf("unexpected record for %s: %+v", record.Key, record)
+		}
+	}
+	if len(records) != 2 {
+		t.Errorf("expected 2 records, found %d", len(records))
+	}
+}
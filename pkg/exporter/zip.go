@@ -2,51 +2,44 @@ package exporter
 
 import (
 	"archive/zip"
-	"bytes"
 	"io"
-	"log"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 )
 
-func Zip(data []interfaces.DataProducer) (*bytes.Buffer, error) {
-	buffer := new(bytes.Buffer)
-	z := zip.NewWriter(buffer)
-	defer z.Close()
-
-	for _, prd := range data {
-		for name, value := range prd.GetData() {
-			key := prd.GetName() + "/" + name
-			dataf, err := z.Create(key)
-			if err != nil {
-				// If there's an error creating one value, log the error and continue.
-				// We don't this to prevent all the other logs from being exported.
-				log.Printf("Error creating zip entry %q: %v", key, err)
-				continue
-			}
-
-			err = func() error {
-				valueReader, err := value.GetReader()
-				if err != nil {
-					return err
-				}
-
-				defer valueReader.Close()
-
-				_, err = io.Copy(dataf, valueReader)
-				return err
-			}()
-
-			if err != nil {
-				// If there's an error writing one value, log the error and continue.
-				// This will leave the entry in the zip empty but allow export of other entries.
-				log.Printf("Error writing zip entry %q: %v", key, err)
-				continue
-			}
-		}
+// Zip writes the data from all the specified producers to the writer as a zip archive. Each value is streamed
+// directly from its reader into the archive, so memory use does not depend on the size of the data.
+// Every value is recorded as an artifact in the manifest, which is written as the final entry in the archive.
+func Zip(w io.Writer, data []interfaces.DataProducer, manifest *Manifest) error {
+	return WriteArchive(w, ZipArchiveFormat, data, manifest)
+}
+
+// zipArchiveWriter writes each entry with Deflate compression, unless it is already compressed, in which case it
+// is stored as it is.
+type zipArchiveWriter struct {
+	writer *zip.Writer
+}
+
+func newZipArchiveWriter(w io.Writer) *zipArchiveWriter {
+	return &zipArchiveWriter{writer: zip.NewWriter(w)}
+}
+
+func (a *zipArchiveWriter) WriteEntry(name string, reader io.Reader) (int64, error) {
+	reader, compressed := detectCompressed(name, reader)
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if compressed {
+		header.Method = zip.Store
 	}
 
-	z.Flush()
+	entry, err := a.writer.CreateHeader(header)
+	if err != nil {
+		return 0, err
+	}
+
+	return io.Copy(entry, reader)
+}
 
-	return buffer, nil
+func (a *zipArchiveWriter) Close() error {
+	return a.writer.Close()
 }
@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+func TestZip(t *testing.T) {
+	producers := []interfaces.DataProducer{
+		&testDataProducer{
+			name: "dns",
+			data: map[string]string{
+				"virtualmachine": "vm content",
+				"kubernetes":     "k8s content",
+			},
+		},
+		&testDataProducer{
+			name: "osm",
+			data: map[string]string{
+				"mesh/envoy/podconfig_dump": "envoy content",
+			},
+		},
+	}
+
+	manifest := NewManifest("run1", "node1")
+	for _, producer := range producers {
+		manifest.AddProducer(CollectorProducer, producer)
+	}
+
+	wantEntries := map[string]string{
+		"dns/virtualmachine":            "vm content",
+		"dns/kubernetes":                "k8s content",
+		"osm/mesh/envoy/podconfig_dump": "envoy content",
+	}
+
+	// Write through a pipe, as the archive is written when exporting.
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(Zip(pipeWriter, producers, manifest))
+	}()
+
+	content, err := io.ReadAll(pipeReader)
+	if err != nil {
+		t.Fatalf("error reading zip output: %v", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("error opening zip output: %v", err)
+	}
+
+	// All entries plus the manifest
+	if len(zipReader.File) != len(wantEntries)+1 {
+		t.Errorf("unexpected number of entries: expected %d, found %d", len(wantEntries)+1, len(zipReader.File))
+	}
+
+	for _, file := range zipReader.File {
+		if file.Name == ManifestFileName {
+			continue
+		}
+
+		expectedContent, ok := wantEntries[file.Name]
+		if !ok {
+			t.Errorf("unexpected entry %s", file.Name)
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			t.Errorf("error opening entry %s: %v", file.Name, err)
+			continue
+		}
+		actualContent, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Errorf("error reading entry %s: %v", file.Name, err)
+			continue
+		}
+
+		if string(actualContent) != expectedContent {
+			t.Errorf("unexpected content for %s.\nExpected '%s'\nFound '%s'", file.Name, expectedContent, string(actualContent))
+		}
+	}
+}
+
+func TestZipManifest(t *testing.T) {
+	const content = "k8s content"
+
+	producer := &testDataProducer{name: "dns", data: map[string]string{"kubernetes": content}}
+	skippedProducer := &testDataProducer{name: "iptables", data: map[string]string{}}
+
+	manifest := NewManifest("run1", "node1")
+	record := manifest.AddProducer(CollectorProducer, producer)
+	record.CheckSupported, _ = RecordStep(func() error { return nil })
+	skippedRecord := manifest.AddProducer(CollectorProducer, skippedProducer)
+	skippedRecord.CheckSupported, _ = RecordStep(func() error { return errors.New("unsupported OS: windows") })
+
+	buffer := new(bytes.Buffer)
+	if err := Zip(buffer, []interfaces.DataProducer{producer}, manifest); err != nil {
+		t.Fatalf("Zip() error = %v", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("error opening zip output: %v", err)
+	}
+
+	manifestFile, err := zipReader.Open(ManifestFileName)
+	if err != nil {
+		t.Fatalf("error opening manifest: %v", err)
+	}
+	defer manifestFile.Close()
+
+	actual := &Manifest{}
+	if err := json.NewDecoder(manifestFile).Decode(actual); err != nil {
+		t.Fatalf("error decoding manifest: %v", err)
+	}
+
+	if len(actual.Producers) != 2 {
+		t.Fatalf("unexpected number of producers: expected 2, found %d", len(actual.Producers))
+	}
+
+	dnsRecord := actual.Producers[0]
+	if !dnsRecord.CheckSupported.Succeeded {
+		t.Errorf("expected CheckSupported to succeed for %s", dnsRecord.Name)
+	}
+	if len(dnsRecord.Artifacts) != 1 {
+		t.Fatalf("unexpected number of artifacts: expected 1, found %d", len(dnsRecord.Artifacts))
+	}
+
+	hash := sha256.Sum256([]byte(content))
+	artifact := dnsRecord.Artifacts[0]
+	if artifact.Key != "kubernetes" || artifact.Length != int64(len(content)) || artifact.Sha256 != hex.EncodeToString(hash[:]) {
+		t.Errorf("unexpected artifact: %+v", artifact)
+	}
+
+	iptablesRecord := actual.Producers[1]
+	if iptablesRecord.CheckSupported.Succeeded || iptablesRecord.CheckSupported.Error == "" {
+		t.Errorf("expected CheckSupported failure to be recorded for %s", iptablesRecord.Name)
+	}
+	if iptablesRecord.Collect != nil {
+		t.Errorf("expected no Collect step to be recorded for %s", iptablesRecord.Name)
+	}
+}
+
+func TestRecordStep(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantTimedOut  bool
+		wantCancelled bool
+	}{
+		{name: "succeeded"},
+		{name: "failed", err: errors.New("failed")},
+		{name: "timed out", err: fmt.Errorf("timed out after 1s: %w", context.DeadlineExceeded), wantTimedOut: true},
+		{name: "cancelled", err: fmt.Errorf("%w: stopped", context.Canceled), wantCancelled: true},
+	}
+
+	for _, tt := range tests {
+		record, err := RecordStep(func() error { return tt.err })
+		if err != tt.err {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if record.Succeeded != (tt.err == nil) || record.TimedOut != tt.wantTimedOut || record.Cancelled != tt.wantCancelled {
+			t.Errorf("%s: unexpected record %+v", tt.name, record)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package interfaces
 
+import "context"
+
 // Collector defines interface for a collector
 type Collector interface {
 	GetName() string
 
 	CheckSupported() error
 
-	Collect() error
+	Collect(ctx context.Context) error
 
 	GetData() map[string]DataValue
 }
@@ -0,0 +1,14 @@
+package interfaces
+
+// DNSConfigOutput is implemented by collectors that read the DNS configuration of the node and the container,
+// so that diagnosers can use it without depending on a specific collector type.
+type DNSConfigOutput interface {
+	GetHostConf() string
+
+	GetContainerConf() string
+}
+
+// KubeletCommandOutput is implemented by collectors that read the command line of the kubelet process.
+type KubeletCommandOutput interface {
+	GetKubeletCommand() string
+}
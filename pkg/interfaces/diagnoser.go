@@ -1,10 +1,12 @@
 package interfaces
 
+import "context"
+
 // Diagnoser defines interface for a diagnoser
 type Diagnoser interface {
 	GetName() string
 
-	Diagnose() error
+	Diagnose(ctx context.Context) error
 
 	GetData() map[string]DataValue
 }
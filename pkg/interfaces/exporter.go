@@ -1,6 +1,13 @@
 package interfaces
 
+import (
+	"context"
+	"io"
+)
+
 // Exporter defines interface for an exporter
 type Exporter interface {
-	Export(DataProducer) error
+	Export(ctx context.Context, producer DataProducer) error
+
+	ExportReader(ctx context.Context, name string, reader io.Reader) error
 }
@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// Format is the format log lines are written in.
+type Format string
+
+const (
+	LogfmtFormat Format = "logfmt"
+	JSONFormat   Format = "json"
+)
+
+// DefaultFormat is used when no log format is configured.
+const DefaultFormat = LogfmtFormat
+
+// Keys of the structured fields logged by Periscope.
+const (
+	RunIdKey     = "runId"
+	NodeKey      = "node"
+	ComponentKey = "component"
+	DurationKey  = "duration"
+	ErrorKey     = "error"
+)
+
+// RunLogName is the name of the producer of the run log, and RunLogKey the key of its data, so that it is written to
+// the archive as periscope/run.log.
+const (
+	RunLogName = "periscope"
+	RunLogKey  = "run.log"
+)
+
+// maxRunLogSize bounds the memory used to capture the log of a run. Once it is reached, further lines are dropped
+// (and counted).
+const maxRunLogSize = 10 * 1024 * 1024
+
+var (
+	configuredFormat = DefaultFormat
+	currentRunLog    atomic.Pointer[RunLog]
+)
+
+// ParseFormat gets the log format with the specified name, or the default format if the name is empty.
+func ParseFormat(name string) (Format, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DefaultFormat, nil
+	}
+
+	for _, format := range []Format{LogfmtFormat, JSONFormat} {
+		if name == string(format) {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown log format %q (expected %s or %s)", name, LogfmtFormat, JSONFormat)
+}
+
+// Configure makes the default slog logger write structured log lines in the specified format to the writer. Lines
+// logged through the log package are written the same way (as messages without any other fields). While a run log
+// is started, all lines are also captured in it.
+func Configure(w io.Writer, format Format) {
+	configuredFormat = format
+	slog.SetDefault(slog.New(&handler{inner: newFormatHandler(w, format)}))
+}
+
+// Duration gets the field for a duration, in seconds.
+func Duration(d time.Duration) slog.Attr {
+	return slog.Float64(DurationKey, d.Round(time.Millisecond).Seconds())
+}
+
+// Error gets the field for an error.
+func Error(err error) slog.Attr {
+	return slog.Any(ErrorKey, err)
+}
+
+func newFormatHandler(w io.Writer, format Format) slog.Handler {
+	if format == JSONFormat {
+		return slog.NewJSONHandler(w, nil)
+	}
+	return slog.NewTextHandler(w, nil)
+}
+
+// handler writes log lines to the inner handler, and to the current run log (if any). Lines logged without a run ID
+// (e.g. through the log package) are given the run ID and node of the current run log.
+type handler struct {
+	inner slog.Handler
+	// ops are the WithAttrs and WithGroup calls made to derive this handler, which are applied in the same way to the
+	// run log's handler.
+	ops         []func(slog.Handler) slog.Handler
+	hasRunAttrs bool
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	if runLog := currentRunLog.Load(); runLog != nil {
+		if !h.hasRunAttrs {
+			record = record.Clone()
+			record.AddAttrs(runLog.attrs...)
+		}
+		runLog.handle(ctx, record, h.ops)
+	}
+
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hasRunAttrs := h.hasRunAttrs
+	for _, attr := range attrs {
+		hasRunAttrs = hasRunAttrs || attr.Key == RunIdKey
+	}
+
+	return &handler{
+		inner:       h.inner.WithAttrs(attrs),
+		ops:         append(h.ops[:len(h.ops):len(h.ops)], func(h slog.Handler) slog.Handler { return h.WithAttrs(attrs) }),
+		hasRunAttrs: hasRunAttrs,
+	}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &handler{
+		inner:       h.inner.WithGroup(name),
+		ops:         append(h.ops[:len(h.ops):len(h.ops)], func(h slog.Handler) slog.Handler { return h.WithGroup(name) }),
+		hasRunAttrs: h.hasRunAttrs,
+	}
+}
+
+// RunLog captures the log lines written during a run in memory, in the configured format, so that they can be
+// included in the archive. It is a DataProducer, whose data is the lines captured so far.
+type RunLog struct {
+	attrs   []slog.Attr
+	lock    sync.Mutex
+	buffer  bytes.Buffer
+	handler slog.Handler
+	dropped int
+}
+
+// StartRunLog starts capturing log lines for the specified run, until the returned RunLog is stopped. Only one run is
+// captured at a time, so this replaces any run log that was already started.
+func StartRunLog(runId string, node string) *RunLog {
+	runLog := &RunLog{attrs: []slog.Attr{slog.String(RunIdKey, runId), slog.String(NodeKey, node)}}
+	runLog.handler = newFormatHandler(&runLog.buffer, configuredFormat)
+	currentRunLog.Store(runLog)
+	return runLog
+}
+
+// Stop stops capturing log lines. The lines captured so far are still available.
+func (l *RunLog) Stop() {
+	currentRunLog.CompareAndSwap(l, nil)
+}
+
+func (l *RunLog) handle(ctx context.Context, record slog.Record, ops []func(slog.Handler) slog.Handler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.buffer.Len() >= maxRunLogSize {
+		l.dropped++
+		return
+	}
+
+	h := l.handler
+	for _, op := range ops {
+		h = op(h)
+	}
+	_ = h.Handle(ctx, record)
+}
+
+func (l *RunLog) GetName() string {
+	return RunLogName
+}
+
+func (l *RunLog) GetData() map[string]interfaces.DataValue {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	content := l.buffer.String()
+	if l.dropped > 0 {
+		content += fmt.Sprintf("%d further lines were dropped after reaching %d bytes\n", l.dropped, maxRunLogSize)
+	}
+
+	return map[string]interfaces.DataValue{
+		RunLogKey: runLogValue(content),
+	}
+}
+
+// runLogValue is the content of the run log at the time its data was read. It is defined here rather than using
+// utils.StringDataValue so that the utils package can log through this package.
+type runLogValue string
+
+func (v runLogValue) GetLength() int64 {
+	return int64(len(v))
+}
+
+func (v runLogValue) GetReader() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(v))), nil
+}
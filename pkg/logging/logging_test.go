@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"", LogfmtFormat, false},
+		{"logfmt", LogfmtFormat, false},
+		{" json ", JSONFormat, false},
+		{"text", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormat(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunLog(t *testing.T) {
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+
+	output := &bytes.Buffer{}
+	Configure(output, JSONFormat)
+
+	log.Printf("before the run")
+
+	runLog := StartRunLog("run1", "node1")
+	log.Printf("from the log package")
+	slog.With(RunIdKey, "run1", NodeKey, "cluster").With(ComponentKey, "dns").Error("from a logger", Duration(1500*time.Millisecond), Error(errors.New("failed")))
+	runLog.Stop()
+
+	log.Printf("after the run")
+
+	lines := parseLines(t, output.String())
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines written, found %d: %s", len(lines), output.String())
+	}
+
+	// Lines logged outside the run have no run fields, and lines logged without a run ID get the run's fields.
+	expected := []map[string]interface{}{
+		{"msg": "before the run"},
+		{"msg": "from the log package", "level": "INFO", RunIdKey: "run1", NodeKey: "node1"},
+		{"msg": "from a logger", "level": "ERROR", RunIdKey: "run1", NodeKey: "cluster", ComponentKey: "dns", DurationKey: 1.5, ErrorKey: "failed"},
+		{"msg": "after the run"},
+	}
+	for i, fields := range expected {
+		for key, value := range fields {
+			if lines[i][key] != value {
+				t.Errorf("line %d: expected %s=%v, found %v", i, key, value, lines[i][key])
+			}
+		}
+	}
+	if _, ok := lines[0][RunIdKey]; ok {
+		t.Errorf("expected no run ID before the run, found %v", lines[0])
+	}
+
+	// The run log contains just the lines logged during the run, in the same format.
+	data := runLog.GetData()
+	if runLog.GetName() != RunLogName || len(data) != 1 {
+		t.Fatalf("unexpected run log producer %s with data %v", runLog.GetName(), data)
+	}
+	reader, err := data[RunLogKey].GetReader()
+	if err != nil {
+		t.Fatalf("error reading run log: %v", err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error reading run log: %v", err)
+	}
+
+	runLines := parseLines(t, string(content))
+	if len(runLines) != 2 || runLines[0]["msg"] != "from the log package" || runLines[1]["msg"] != "from a logger" {
+		t.Errorf("unexpected run log: %s", content)
+	}
+	if runLines[1][ComponentKey] != "dns" || runLines[1][NodeKey] != "cluster" {
+		t.Errorf("expected run log to keep logger fields, found %v", runLines[1])
+	}
+}
+
+func TestRunLogLogfmt(t *testing.T) {
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+
+	output := &bytes.Buffer{}
+	Configure(output, LogfmtFormat)
+
+	runLog := StartRunLog("run1", "node1")
+	defer runLog.Stop()
+	slog.Info("collected", ComponentKey, "dns")
+
+	if line := output.String(); !strings.Contains(line, `msg=collected component=dns runId=run1 node=node1`) {
+		t.Errorf("unexpected logfmt line: %s", line)
+	}
+}
+
+func parseLines(t *testing.T, content string) []map[string]interface{} {
+	lines := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("error parsing line %q: %v", line, err)
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
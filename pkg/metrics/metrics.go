@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsPath is the path metrics are served on.
+const MetricsPath = "/metrics"
+
+// RunResult is the outcome of a run.
+type RunResult string
+
+const (
+	// RunSucceeded means every producer that was run succeeded, and all data was exported.
+	RunSucceeded RunResult = "succeeded"
+	// RunFailed means at least one producer failed, or some data could not be exported.
+	RunFailed RunResult = "failed"
+	// RunCancelled means the run was cancelled before it completed (e.g. because the pod was terminated).
+	RunCancelled RunResult = "cancelled"
+)
+
+var runResults = []RunResult{RunSucceeded, RunFailed, RunCancelled}
+
+// ArchiveProducer is the producer type used for recording the export of the archive, named by its format.
+const ArchiveProducer exporter.ProducerType = "archive"
+
+// Metrics records Periscope's own behaviour, in a registry that can be served to Prometheus.
+type Metrics struct {
+	registry         *prometheus.Registry
+	runs             *prometheus.CounterVec
+	lastRunTimestamp prometheus.Gauge
+	lastRunDuration  prometheus.Gauge
+	lastRunResult    *prometheus.GaugeVec
+	stepDuration     *prometheus.HistogramVec
+	stepErrors       *prometheus.CounterVec
+	producedBytes    *prometheus.CounterVec
+}
+
+// NewMetrics creates the metrics for Periscope runs, along with the standard Go runtime and process metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "periscope_runs_total",
+			Help: "Number of completed runs, by result.",
+		}, []string{"result"}),
+		lastRunTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "periscope_last_run_timestamp_seconds",
+			Help: "Time the last run completed, in seconds since the Unix epoch.",
+		}),
+		lastRunDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "periscope_last_run_duration_seconds",
+			Help: "Duration of the last run.",
+		}),
+		lastRunResult: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "periscope_last_run_result",
+			Help: "Result of the last run: 1 for the result of the last run, 0 for the others.",
+		}, []string{"result"}),
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "periscope_step_duration_seconds",
+			Help:    "Duration of each step (collect, diagnose or export) of each collector and diagnoser, and of exporting the archive.",
+			Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600},
+		}, []string{"type", "name", "step"}),
+		stepErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "periscope_step_errors_total",
+			Help: "Number of failed steps (collect, diagnose or export) of each collector and diagnoser, and of exporting the archive.",
+		}, []string{"type", "name", "step"}),
+		producedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "periscope_produced_bytes_total",
+			Help: "Number of bytes of data produced by each collector and diagnoser, after truncation and redaction.",
+		}, []string{"type", "name"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.runs,
+		m.lastRunTimestamp,
+		m.lastRunDuration,
+		m.lastRunResult,
+		m.stepDuration,
+		m.stepErrors,
+		m.producedBytes,
+	)
+
+	// Initialize the run results so that they are reported before the first run completes.
+	for _, result := range runResults {
+		m.runs.WithLabelValues(string(result))
+		m.lastRunResult.WithLabelValues(string(result))
+	}
+
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRun records the result of a run which started at the specified time.
+func (m *Metrics) ObserveRun(result RunResult, start time.Time) {
+	now := time.Now()
+	m.runs.WithLabelValues(string(result)).Inc()
+	m.lastRunTimestamp.Set(float64(now.Unix()))
+	m.lastRunDuration.Set(now.Sub(start).Seconds())
+	for _, r := range runResults {
+		value := 0.0
+		if r == result {
+			value = 1
+		}
+		m.lastRunResult.WithLabelValues(string(r)).Set(value)
+	}
+}
+
+// ObserveStep records the duration and outcome of a step run for the named producer. Steps that were not run (i.e.
+// a nil record) are ignored.
+func (m *Metrics) ObserveStep(producerType exporter.ProducerType, name string, step string, record *exporter.StepRecord) {
+	if record == nil {
+		return
+	}
+
+	m.stepDuration.WithLabelValues(string(producerType), name, step).Observe(record.GetDuration().Seconds())
+	if !record.Succeeded {
+		m.stepErrors.WithLabelValues(string(producerType), name, step).Inc()
+	}
+}
+
+// ObserveProducer records the steps run for a producer, along with the size of its data in the archive.
+func (m *Metrics) ObserveProducer(record *exporter.ProducerRecord) {
+	m.ObserveStep(record.Type, record.Name, "collect", record.Collect)
+	m.ObserveStep(record.Type, record.Name, "diagnose", record.Diagnose)
+	m.ObserveStep(record.Type, record.Name, "export", record.Export)
+
+	var length int64
+	for _, artifact := range record.Artifacts {
+		length += artifact.Length
+	}
+	if length > 0 {
+		m.producedBytes.WithLabelValues(string(record.Type), record.Name).Add(float64(length))
+	}
+}
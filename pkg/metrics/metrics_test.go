@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRun(t *testing.T) {
+	m := NewMetrics()
+	start := time.Now()
+
+	m.ObserveRun(RunSucceeded, start)
+	m.ObserveRun(RunFailed, start)
+	m.ObserveRun(RunFailed, start)
+
+	tests := []struct {
+		result   RunResult
+		runs     float64
+		lastRuns float64
+	}{
+		{RunSucceeded, 1, 0},
+		{RunFailed, 2, 1},
+		{RunCancelled, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.result), func(t *testing.T) {
+			if runs := testutil.ToFloat64(m.runs.WithLabelValues(string(tt.result))); runs != tt.runs {
+				t.Errorf("expected %v runs, found %v", tt.runs, runs)
+			}
+			if lastRuns := testutil.ToFloat64(m.lastRunResult.WithLabelValues(string(tt.result))); lastRuns != tt.lastRuns {
+				t.Errorf("expected last run result %v, found %v", tt.lastRuns, lastRuns)
+			}
+		})
+	}
+
+	if timestamp := testutil.ToFloat64(m.lastRunTimestamp); timestamp < float64(start.Unix()) {
+		t.Errorf("expected last run timestamp after %d, found %v", start.Unix(), timestamp)
+	}
+}
+
+func TestObserveProducer(t *testing.T) {
+	m := NewMetrics()
+
+	m.ObserveProducer(&exporter.ProducerRecord{
+		Type:      exporter.CollectorProducer,
+		Name:      "dns",
+		Collect:   &exporter.StepRecord{Succeeded: true, Duration: "1.5s"},
+		Export:    &exporter.StepRecord{Succeeded: false, Duration: "200ms"},
+		Artifacts: []*exporter.ArtifactRecord{{Key: "virtualmachine", Length: 100}, {Key: "kubernetes", Length: 50}},
+	})
+	m.ObserveProducer(&exporter.ProducerRecord{
+		Type:     exporter.DiagnoserProducer,
+		Name:     "networkconfig",
+		Diagnose: &exporter.StepRecord{Succeeded: false, Duration: "10ms"},
+	})
+	m.ObserveStep(ArchiveProducer, "zip", "export", &exporter.StepRecord{Succeeded: true, Duration: "3s"})
+
+	expected := `
+# HELP periscope_step_errors_total Number of failed steps (collect, diagnose or export) of each collector and diagnoser, and of exporting the archive.
+# TYPE periscope_step_errors_total counter
+periscope_step_errors_total{name="dns",step="export",type="collector"} 1
+periscope_step_errors_total{name="networkconfig",step="diagnose",type="diagnoser"} 1
+# HELP periscope_produced_bytes_total Number of bytes of data produced by each collector and diagnoser, after truncation and redaction.
+# TYPE periscope_produced_bytes_total counter
+periscope_produced_bytes_total{name="dns",type="collector"} 150
+`
+	if err := testutil.CollectAndCompare(m.registry, strings.NewReader(expected), "periscope_step_errors_total", "periscope_produced_bytes_total"); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+
+	// Every step that was run has its duration recorded: collect and export for dns, diagnose for networkconfig,
+	// and the archive export.
+	if count := testutil.CollectAndCount(m.stepDuration); count != 4 {
+		t.Errorf("expected 4 step duration series, found %d", count)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveRun(RunCancelled, time.Now())
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + MetricsPath)
+	if err != nil {
+		t.Fatalf("error getting metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("error reading metrics: %v", err)
+	}
+
+	for _, line := range []string{
+		`periscope_runs_total{result="cancelled"} 1`,
+		`periscope_runs_total{result="succeeded"} 0`,
+		`periscope_last_run_result{result="cancelled"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("expected metrics to contain %s", line)
+		}
+	}
+}
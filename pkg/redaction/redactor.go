@@ -0,0 +1,128 @@
+package redaction
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// maxLineLength is the longest line that is redacted as a whole. Longer lines are redacted in chunks of this size,
+// so a value spanning the boundary between two chunks will not be matched.
+const maxLineLength = 256 * 1024
+
+// Redactor applies redaction rules to data as it is read, line by line, so that the data never needs to be held
+// in memory in full.
+type Redactor struct {
+	rules  []*Rule
+	record func(rule string, count int)
+}
+
+// NewRedactor creates a Redactor applying the rules in order. The record function (if any) is called with the number
+// of values redacted by each rule, and must be safe to call concurrently.
+func NewRedactor(rules []*Rule, record func(rule string, count int)) *Redactor {
+	return &Redactor{
+		rules:  rules,
+		record: record,
+	}
+}
+
+// RedactProducer wraps the producer so that all of its data is redacted when read.
+func (r *Redactor) RedactProducer(producer interfaces.DataProducer) interfaces.DataProducer {
+	return &redactedProducer{producer: producer, redactor: r}
+}
+
+// RedactValue wraps the value so that it is redacted when read.
+func (r *Redactor) RedactValue(value interfaces.DataValue) interfaces.DataValue {
+	return &redactedValue{value: value, redactor: r}
+}
+
+func (r *Redactor) redact(data []byte) []byte {
+	for _, rule := range r.rules {
+		var count int
+		data, count = rule.apply(data)
+		if count > 0 && r.record != nil {
+			r.record(rule.Name, count)
+		}
+	}
+	return data
+}
+
+type redactedProducer struct {
+	producer interfaces.DataProducer
+	redactor *Redactor
+}
+
+func (p *redactedProducer) GetName() string {
+	return p.producer.GetName()
+}
+
+func (p *redactedProducer) GetData() map[string]interfaces.DataValue {
+	data := p.producer.GetData()
+	result := make(map[string]interfaces.DataValue, len(data))
+	for key, value := range data {
+		result[key] = p.redactor.RedactValue(value)
+	}
+	return result
+}
+
+// Unwrap returns the producer whose data is redacted, so that its data can be attributed to it.
+func (p *redactedProducer) Unwrap() interfaces.DataProducer {
+	return p.producer
+}
+
+type redactedValue struct {
+	value    interfaces.DataValue
+	redactor *Redactor
+}
+
+// GetLength returns the length of the unredacted value, since the redacted length isn't known until it is read.
+func (v *redactedValue) GetLength() int64 {
+	return v.value.GetLength()
+}
+
+func (v *redactedValue) GetReader() (io.ReadCloser, error) {
+	reader, err := v.value.GetReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &redactingReader{
+		source:   reader,
+		reader:   bufio.NewReaderSize(reader, maxLineLength),
+		redactor: v.redactor,
+	}, nil
+}
+
+type redactingReader struct {
+	source   io.ReadCloser
+	reader   *bufio.Reader
+	redactor *Redactor
+	pending  []byte
+	err      error
+}
+
+func (r *redactingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			err = nil
+		}
+
+		// The slice is only valid until the next read, and is not modified by redaction if nothing matches.
+		r.pending = r.redactor.redact(append([]byte(nil), line...))
+		r.err = err
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *redactingReader) Close() error {
+	return r.source.Close()
+}
@@ -0,0 +1,101 @@
+package redaction
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	"github.com/Azure/aks-periscope/pkg/utils"
+)
+
+type testDataProducer struct {
+	data map[string]string
+}
+
+func (p *testDataProducer) GetName() string {
+	return "test"
+}
+
+func (p *testDataProducer) GetData() map[string]interfaces.DataValue {
+	return utils.ToDataValueMap(p.data)
+}
+
+func TestRedactProducer(t *testing.T) {
+	producer := &testDataProducer{
+		data: map[string]string{
+			"journal": "line 1\nAuthorization: Bearer abc123\nline 3 password=hunter2\nBearer def456",
+			"empty":   "",
+			"clean":   "nothing to see here\n",
+		},
+	}
+
+	expected := map[string]string{
+		"journal": "line 1\nAuthorization: Bearer [REDACTED]\nline 3 password=[REDACTED]\nBearer [REDACTED]",
+		"empty":   "",
+		"clean":   "nothing to see here\n",
+	}
+
+	lock := sync.Mutex{}
+	counts := map[string]int{}
+	redactor := NewRedactor(GetBuiltInRules(), func(rule string, count int) {
+		lock.Lock()
+		defer lock.Unlock()
+		counts[rule] += count
+	})
+
+	redacted := redactor.RedactProducer(producer)
+	if redacted.GetName() != producer.GetName() {
+		t.Errorf("unexpected name %s", redacted.GetName())
+	}
+	if unwrapped := redacted.(*redactedProducer).Unwrap(); unwrapped != producer {
+		t.Errorf("expected Unwrap to return the original producer")
+	}
+
+	for key, value := range redacted.GetData() {
+		reader, err := value.GetReader()
+		if err != nil {
+			t.Fatalf("error getting reader for %s: %v", key, err)
+		}
+
+		// Read in small chunks, to ensure the redacted output is split across reads correctly.
+		actual, err := io.ReadAll(iotest.OneByteReader(reader))
+		reader.Close()
+		if err != nil {
+			t.Errorf("error reading %s: %v", key, err)
+			continue
+		}
+
+		if string(actual) != expected[key] {
+			t.Errorf("unexpected content for %s.\nExpected '%s'\nFound '%s'", key, expected[key], string(actual))
+		}
+	}
+
+	if counts["bearer-token"] != 2 || counts["client-secret"] != 1 || len(counts) != 2 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+func TestRedactLongLine(t *testing.T) {
+	// Lines longer than the buffer are redacted in chunks, so secrets within each chunk are still redacted.
+	line := strings.Repeat("x", maxLineLength) + " Bearer abc123 " + strings.Repeat("y", maxLineLength)
+	value := utils.NewStringDataValue(line + "\n")
+
+	reader, err := NewRedactor(GetBuiltInRules(), nil).RedactValue(value).GetReader()
+	if err != nil {
+		t.Fatalf("error getting reader: %v", err)
+	}
+	defer reader.Close()
+
+	actual, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+
+	expected := strings.Replace(line, "abc123", RedactedText, 1) + "\n"
+	if string(actual) != expected {
+		t.Errorf("unexpected content of length %d (expected length %d)", len(actual), len(expected))
+	}
+}
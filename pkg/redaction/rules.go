@@ -0,0 +1,130 @@
+package redaction
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactedText replaces each redacted value.
+const RedactedText = "[REDACTED]"
+
+// secretGroupName is the name of the capture group identifying the part of a match to redact. If a rule's pattern has
+// no such group, the whole match is redacted.
+const secretGroupName = "secret"
+
+// Rule is a named pattern identifying sensitive data.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+func newRule(name, pattern string) (*Rule, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for redaction rule %s: %w", name, err)
+	}
+	return &Rule{Name: name, Pattern: compiled}, nil
+}
+
+// GetBuiltInRules returns the rules that are applied unless explicitly disabled.
+func GetBuiltInRules() []*Rule {
+	return []*Rule{
+		// Authorization headers, and tokens passed on command lines (e.g. curl -H "Authorization: Bearer ...").
+		{Name: "bearer-token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`)},
+		// Signed JSON Web Tokens, such as service account tokens.
+		{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`)},
+		// The signature of a SAS URL or token, without which the rest of the URL is harmless.
+		{Name: "sas-signature", Pattern: regexp.MustCompile(`(?i)[?&]sig=(?P<secret>[^&\s"'<>]+)`)},
+		// Storage account and Service Bus/Event Hubs keys in connection strings.
+		{Name: "connection-string-key", Pattern: regexp.MustCompile(`(?i)\b(?:AccountKey|SharedAccessKey)=(?P<secret>[^;\s"'<>]+)`)},
+		// Client secrets and passwords in command-line arguments, config files, JSON and connection strings.
+		{Name: "client-secret", Pattern: regexp.MustCompile(`(?i)\b(?:client[_-]?secret|password|pwd)["']?\s*[:=]\s*["']?(?P<secret>[^\s"',;&<>]+)`)},
+	}
+}
+
+// GetRules returns the built-in rules, modified by the configured entries. Each entry is one of:
+//   - name=pattern: adds a rule with the specified regular expression, redacting the named 'secret' capture group if
+//     there is one, or otherwise the whole match.
+//   - -name: disables the built-in rule with that name.
+//
+// Empty entries, and entries starting with '#', are ignored.
+func GetRules(entries []string) ([]*Rule, error) {
+	disabled := map[string]bool{}
+	userRules := []*Rule{}
+	names := map[string]bool{}
+	for _, rule := range GetBuiltInRules() {
+		names[rule.Name] = true
+	}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if name, isDisabled := strings.CutPrefix(entry, "-"); isDisabled {
+			if !names[name] {
+				return nil, fmt.Errorf("unknown built-in redaction rule: %s", name)
+			}
+			disabled[name] = true
+			continue
+		}
+
+		name, pattern, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || pattern == "" {
+			return nil, fmt.Errorf("expected name=pattern for redaction rule: %s", entry)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate redaction rule: %s", name)
+		}
+		names[name] = true
+
+		rule, err := newRule(name, pattern)
+		if err != nil {
+			return nil, err
+		}
+		userRules = append(userRules, rule)
+	}
+
+	rules := []*Rule{}
+	for _, rule := range GetBuiltInRules() {
+		if !disabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+
+	return append(rules, userRules...), nil
+}
+
+// apply redacts every match of the rule in the data, returning the result and the number of values redacted.
+func (rule *Rule) apply(data []byte) ([]byte, int) {
+	matches := rule.Pattern.FindAllSubmatchIndex(data, -1)
+	if len(matches) == 0 {
+		return data, 0
+	}
+
+	group := rule.Pattern.SubexpIndex(secretGroupName)
+	result := make([]byte, 0, len(data))
+	count := 0
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if group > 0 {
+			start, end = match[2*group], match[2*group+1]
+		}
+
+		// Skip values already redacted by an earlier rule, and optional groups that didn't match.
+		if start < 0 || string(data[start:end]) == RedactedText {
+			continue
+		}
+
+		result = append(result, data[last:start]...)
+		result = append(result, RedactedText...)
+		last = end
+		count++
+	}
+
+	return append(result, data[last:]...), count
+}
@@ -0,0 +1,262 @@
+package status
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+)
+
+// Paths the status handlers are served on.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+	StatusPath    = "/status"
+)
+
+// State is the stage a run has reached in a pipeline (for a node, or for the cluster-level data).
+type State string
+
+const (
+	Idle       State = "idle"
+	Collecting State = "collecting"
+	Diagnosing State = "diagnosing"
+	Exporting  State = "exporting"
+)
+
+// stateOrder orders the states of a run, so that the overall state is the least advanced of its pipelines.
+var stateOrder = map[State]int{Collecting: 0, Diagnosing: 1, Exporting: 2, Idle: 3}
+
+// Outcome is the outcome so far of a single collector or diagnoser.
+type Outcome string
+
+const (
+	Running   Outcome = "running"
+	Succeeded Outcome = "succeeded"
+	Failed    Outcome = "failed"
+	Skipped   Outcome = "skipped"
+	Partial   Outcome = "partial"
+)
+
+// ProducerStatus is the outcome of a collector or diagnoser in the current (or last) run.
+type ProducerStatus struct {
+	Node     string     `json:"node"`
+	Type     string     `json:"type"`
+	Name     string     `json:"name"`
+	Outcome  Outcome    `json:"outcome"`
+	Error    string     `json:"error,omitempty"`
+	Start    *time.Time `json:"start,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+}
+
+// Status is the status of the daemon, reported by the status endpoint.
+type Status struct {
+	// RunId is the ID of the current run, or the last run if State is idle.
+	RunId     string           `json:"runId,omitempty"`
+	State     State            `json:"state"`
+	Pipelines map[string]State `json:"pipelines,omitempty"`
+	Started   *time.Time       `json:"started,omitempty"`
+	Completed *time.Time       `json:"completed,omitempty"`
+	Result    string           `json:"result,omitempty"`
+	// Error is set if the run failed before running any producers, e.g. because of an invalid config value.
+	Error     string            `json:"error,omitempty"`
+	Producers []*ProducerStatus `json:"producers"`
+	// RunIdLastRead is the time the run ID was last read from the config.
+	RunIdLastRead *time.Time `json:"runIdLastRead,omitempty"`
+}
+
+// Tracker keeps track of the status of the daemon as runs progress.
+type Tracker struct {
+	lock      sync.Mutex
+	status    Status
+	producers map[string]*ProducerStatus
+}
+
+// NewTracker creates a Tracker for a daemon that has not yet started a run.
+func NewTracker() *Tracker {
+	return &Tracker{
+		status:    Status{State: Idle, Producers: []*ProducerStatus{}},
+		producers: map[string]*ProducerStatus{},
+	}
+}
+
+// StartRun resets the status for a new run.
+func (t *Tracker) StartRun(runId string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now().UTC()
+	t.status = Status{
+		RunId:         runId,
+		State:         Collecting,
+		Pipelines:     map[string]State{},
+		Started:       &now,
+		Producers:     []*ProducerStatus{},
+		RunIdLastRead: t.status.RunIdLastRead,
+	}
+	t.producers = map[string]*ProducerStatus{}
+}
+
+// SetState sets the state of the run in the pipeline for the specified node.
+func (t *Tracker) SetState(node string, state State) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.status.Pipelines == nil {
+		t.status.Pipelines = map[string]State{}
+	}
+	t.status.Pipelines[node] = state
+
+	t.status.State = Idle
+	for _, pipelineState := range t.status.Pipelines {
+		if stateOrder[pipelineState] < stateOrder[t.status.State] {
+			t.status.State = pipelineState
+		}
+	}
+}
+
+// SetProducer records the outcome so far of a collector or diagnoser in the pipeline for the specified node. The
+// start time is recorded when it starts running, and the duration when it has any other outcome.
+func (t *Tracker) SetProducer(node string, producerType string, name string, outcome Outcome, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := node + "/" + producerType + "/" + name
+	producer, ok := t.producers[key]
+	if !ok {
+		producer = &ProducerStatus{Node: node, Type: producerType, Name: name}
+		t.producers[key] = producer
+		t.status.Producers = append(t.status.Producers, producer)
+	}
+
+	now := time.Now().UTC()
+	producer.Outcome = outcome
+	producer.Error = ""
+	if err != nil {
+		producer.Error = err.Error()
+	}
+	if outcome == Running {
+		producer.Start = &now
+	} else if producer.Start != nil {
+		producer.Duration = now.Sub(*producer.Start).String()
+	}
+}
+
+// CompleteRun records the result of the current run, along with the error that stopped it from running any producers
+// (if any).
+func (t *Tracker) CompleteRun(result string, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now().UTC()
+	t.status.State = Idle
+	t.status.Pipelines = nil
+	t.status.Completed = &now
+	t.status.Result = result
+	if err != nil {
+		t.status.Error = err.Error()
+	}
+}
+
+// RecordRunIdRead records that the run ID was read from the config.
+func (t *Tracker) RecordRunIdRead() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now().UTC()
+	t.status.RunIdLastRead = &now
+}
+
+// GetStatus gets a copy of the current status.
+func (t *Tracker) GetStatus() *Status {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	status := t.status
+	if t.status.Pipelines != nil {
+		status.Pipelines = map[string]State{}
+		for node, state := range t.status.Pipelines {
+			status.Pipelines[node] = state
+		}
+	}
+
+	status.Producers = make([]*ProducerStatus, len(t.status.Producers))
+	for i, producer := range t.status.Producers {
+		producerCopy := *producer
+		status.Producers[i] = &producerCopy
+	}
+	sort.SliceStable(status.Producers, func(i, j int) bool {
+		a, b := status.Producers[i], status.Producers[j]
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Name < b.Name
+	})
+
+	return &status
+}
+
+// IsReady returns true once the run ID has been read, i.e. the daemon is watching the config and able to start runs.
+func (t *Tracker) IsReady() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.status.RunIdLastRead != nil
+}
+
+// RegisterHandlers adds the liveness, readiness and status handlers to the mux. The liveness handler always succeeds
+// while the process is able to serve requests, the readiness handler succeeds once the daemon is ready to start runs,
+// and the status handler returns the status as JSON.
+func (t *Tracker) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "ok\n")
+	})
+
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, r *http.Request) {
+		if !t.IsReady() {
+			http.Error(w, "run ID not read yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "ok\n")
+	})
+
+	mux.HandleFunc(StatusPath, func(w http.ResponseWriter, r *http.Request) {
+		content, err := json.MarshalIndent(t.GetStatus(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(content)
+	})
+}
+
+// runIdReadRecorder is a FileSystemAccessor that records every read of the run ID file in the Tracker.
+type runIdReadRecorder struct {
+	interfaces.FileSystemAccessor
+	runIdPath string
+	tracker   *Tracker
+}
+
+// NewRunIdReadRecorder wraps the FileSystemAccessor so that reads of the run ID file at the specified path are
+// recorded in the Tracker.
+func NewRunIdReadRecorder(fileSystem interfaces.FileSystemAccessor, runIdPath string, tracker *Tracker) interfaces.FileSystemAccessor {
+	return &runIdReadRecorder{FileSystemAccessor: fileSystem, runIdPath: runIdPath, tracker: tracker}
+}
+
+func (r *runIdReadRecorder) GetFileReader(filePath string) (io.ReadCloser, error) {
+	reader, err := r.FileSystemAccessor.GetFileReader(filePath)
+	if err == nil && filePath == r.runIdPath {
+		r.tracker.RecordRunIdRead()
+	}
+	return reader, err
+}
@@ -0,0 +1,174 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/test"
+	"github.com/Azure/aks-periscope/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	if status := tracker.GetStatus(); status.State != Idle || status.RunId != "" {
+		t.Errorf("expected idle status before the first run, found %+v", status)
+	}
+
+	tracker.StartRun("run1")
+	tracker.SetState("node1", Collecting)
+	tracker.SetState("cluster", Collecting)
+	tracker.SetProducer("node1", "collector", "dns", Running, nil)
+	tracker.SetProducer("node1", "collector", "iptables", Skipped, errors.New("unsupported"))
+	tracker.SetProducer("cluster", "collector", "helm", Running, nil)
+	tracker.SetProducer("node1", "collector", "dns", Succeeded, nil)
+
+	// The run's state is the least advanced of its pipelines.
+	tracker.SetState("node1", Exporting)
+	if state := tracker.GetStatus().State; state != Collecting {
+		t.Errorf("expected collecting state while cluster pipeline is collecting, found %s", state)
+	}
+	tracker.SetState("cluster", Diagnosing)
+	if state := tracker.GetStatus().State; state != Diagnosing {
+		t.Errorf("expected diagnosing state, found %s", state)
+	}
+
+	status := tracker.GetStatus()
+	if status.RunId != "run1" || status.Started == nil || status.Completed != nil {
+		t.Errorf("unexpected run status %+v", status)
+	}
+
+	tests := []struct {
+		node        string
+		name        string
+		outcome     Outcome
+		err         string
+		hasDuration bool
+	}{
+		{"cluster", "helm", Running, "", false},
+		{"node1", "dns", Succeeded, "", true},
+		{"node1", "iptables", Skipped, "unsupported", false},
+	}
+
+	if len(status.Producers) != len(tests) {
+		t.Fatalf("expected %d producers, found %d", len(tests), len(status.Producers))
+	}
+	for i, tt := range tests {
+		producer := status.Producers[i]
+		if producer.Node != tt.node || producer.Name != tt.name || producer.Outcome != tt.outcome || producer.Error != tt.err || (producer.Duration != "") != tt.hasDuration {
+			t.Errorf("unexpected producer %d: %+v", i, producer)
+		}
+	}
+
+	tracker.CompleteRun("succeeded", nil)
+	status = tracker.GetStatus()
+	if status.State != Idle || status.RunId != "run1" || status.Result != "succeeded" || status.Error != "" || status.Completed == nil || len(status.Producers) != 3 {
+		t.Errorf("unexpected completed status %+v", status)
+	}
+
+	// A new run resets the producers.
+	tracker.StartRun("run2")
+	if status := tracker.GetStatus(); status.RunId != "run2" || status.State != Collecting || len(status.Producers) != 0 || status.Result != "" {
+		t.Errorf("unexpected status for new run %+v", status)
+	}
+
+	// A run that fails before running any producers records the error, which is reset by the next run.
+	tracker.CompleteRun("failed", errors.New("invalid config"))
+	if status := tracker.GetStatus(); status.State != Idle || status.Result != "failed" || status.Error != "invalid config" {
+		t.Errorf("unexpected failed status %+v", status)
+	}
+	tracker.StartRun("run3")
+	if status := tracker.GetStatus(); status.Error != "" {
+		t.Errorf("expected error to be reset for new run, found %+v", status)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	tracker := NewTracker()
+	mux := http.NewServeMux()
+	tracker.RegisterHandlers(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(path string) *http.Response {
+		resp, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("error getting %s: %v", path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := get(LivenessPath); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected liveness status %d, found %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp := get(ReadinessPath); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness status %d before reading the run ID, found %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	// Only reads of the run ID file are recorded.
+	fs := NewRunIdReadRecorder(test.NewFakeFileSystem(map[string]string{"/config/run_id": "run1", "/config/other": "value"}), "/config/run_id", tracker)
+	if _, err := fs.GetFileReader("/config/other"); err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	if tracker.IsReady() {
+		t.Errorf("expected not to be ready after reading another file")
+	}
+	if _, err := fs.GetFileReader("/config/run_id"); err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+
+	if resp := get(ReadinessPath); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected readiness status %d after reading the run ID, found %d", http.StatusOK, resp.StatusCode)
+	}
+
+	tracker.StartRun("run1")
+	tracker.SetProducer("node1", "collector", "dns", Running, nil)
+
+	resp := get(StatusPath)
+	status := &Status{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		t.Fatalf("error decoding status: %v", err)
+	}
+	if status.RunId != "run1" || status.State != Collecting || status.RunIdLastRead == nil || len(status.Producers) != 1 || status.Producers[0].Outcome != Running {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestRunIdReadRecorderWithResourceContentWatcher(t *testing.T) {
+	const namespace = "aks-periscope"
+	knownFilePaths := &utils.KnownFilePaths{Config: "/config", Secret: "/secret"}
+	runIdPath := knownFilePaths.GetConfigPath(utils.RunIdKey)
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: utils.ConfigMapName, Namespace: namespace},
+		Data:       map[string]string{string(utils.RunIdKey): "run1"},
+	})
+
+	// When the config is watched through the API, the watcher reads the run ID itself rather than polling the file.
+	tracker := NewTracker()
+	watcher := utils.NewResourceContentWatcher(client, namespace, knownFilePaths, test.NewFakeFileSystem(map[string]string{}))
+	watcher.SetReader(NewRunIdReadRecorder(watcher, runIdPath, tracker))
+
+	runIdChan := make(chan string)
+	watcher.AddHandler(runIdPath, runIdChan, make(chan error, 1))
+	watcher.Start()
+
+	select {
+	case runId := <-runIdChan:
+		if runId != "run1" {
+			t.Errorf("unexpected run ID %s", runId)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the run ID")
+	}
+
+	if !tracker.IsReady() {
+		t.Errorf("expected to be ready after the watcher read the run ID")
+	}
+}
@@ -311,8 +311,10 @@ func TestStart(t *testing.T) {
 				handler.expect(handlerSetup.preChangeNotifications)
 			}
 
-			// Run test
+			// Run test. The watcher polls continuously, so stop it afterwards rather than leaving it using the CPU
+			// while the remaining tests (some of which depend on timing) run.
 			watcher.Start()
+			defer watcher.ticker.Stop()
 
 			// Start listening for pre-change notifications for all handlers
 			for _, handler := range handlers {
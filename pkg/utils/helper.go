@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -85,33 +87,45 @@ func GetStorageEndpointSuffix(knownFilePaths *KnownFilePaths) string {
 	return PublicAzureStorageEndpointSuffix
 }
 
-// RunCommandOnHost runs a command on host system
-func RunCommandOnHost(command string, arg ...string) (string, error) {
+// RunCommandOnHost runs a command on host system. The command is killed if the context is done before it completes.
+func RunCommandOnHost(ctx context.Context, command string, arg ...string) (string, error) {
 	args := []string{"--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid"}
 	args = append(args, "--")
 	args = append(args, command)
 	args = append(args, arg...)
 
-	cmd := exec.Command("nsenter", args...)
+	cmd := exec.CommandContext(ctx, "nsenter", args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("fail to run command on host: %w", ctx.Err())
+		}
 		return "", fmt.Errorf("fail to run command on host: %+v", err)
 	}
 
 	return string(out), nil
 }
 
-// Tries to issue an HTTP GET request up to maxRetries times
-func GetUrlWithRetries(url string, maxRetries int) ([]byte, error) {
+// Tries to issue an HTTP GET request up to maxRetries times, giving up early if the context is done
+func GetUrlWithRetries(ctx context.Context, url string, maxRetries int) ([]byte, error) {
 	retry := 1
 	for {
-		resp, err := http.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request HTTP Get %s: %w", url, err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			if retry == maxRetries {
 				return nil, fmt.Errorf("max retries reached for request HTTP Get %s: %w", url, err)
 			}
 			retry++
-			time.Sleep(5 * time.Second)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("request HTTP Get %s cancelled: %w", url, ctx.Err())
+			case <-time.After(5 * time.Second):
+			}
 		} else {
 			defer resp.Body.Close()
 			return io.ReadAll(resp.Body)
@@ -119,6 +133,26 @@ func GetUrlWithRetries(url string, maxRetries int) ([]byte, error) {
 	}
 }
 
+// RunWithTimeout runs the function with a context that is cancelled after the specified timeout. If the function
+// has not returned by then (e.g. because it is blocked on a call that doesn't accept a context), this returns an
+// error wrapping context.DeadlineExceeded without waiting any longer for it.
+func RunWithTimeout(timeout time.Duration, f func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- f(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+}
+
 func Contains(flagsList []string, flag string) bool {
 	for _, f := range flagsList {
 		if strings.EqualFold(f, flag) {
@@ -143,3 +177,40 @@ func GetContent(readCloserProvider func() (io.ReadCloser, error)) (string, error
 
 	return string(content), nil
 }
+
+// RunWithCancellation runs the function with a context that is cancelled after the specified timeout, or when the
+// parent context is cancelled. A timeout is handled as in RunWithTimeout. When the parent is cancelled, the function
+// is given up to stopWait to return, so that whatever it has done so far can still be used. The result indicates
+// whether the function returned, and the error wraps context.Canceled if the parent was cancelled.
+func RunWithCancellation(parent context.Context, timeout time.Duration, stopWait time.Duration, f func(context.Context) error) (bool, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- f(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil && parent.Err() != nil && !errors.Is(err, context.Canceled) {
+			err = fmt.Errorf("%w: %w", context.Canceled, err)
+		}
+		return true, err
+	case <-ctx.Done():
+	}
+
+	if parent.Err() == nil {
+		return false, fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+
+	select {
+	case err := <-result:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			err = fmt.Errorf("%w: %w", context.Canceled, err)
+		}
+		return true, err
+	case <-time.After(stopWait):
+		return false, fmt.Errorf("did not stop within %s of being cancelled: %w", stopWait, parent.Err())
+	}
+}
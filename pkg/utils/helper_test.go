@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunWithTimeout(t *testing.T) {
+	expectedErr := errors.New("expected")
+
+	tests := []struct {
+		name         string
+		f            func(context.Context) error
+		wantErr      error
+		wantTimedOut bool
+	}{
+		{
+			name:         "completes",
+			f:            func(context.Context) error { return nil },
+			wantErr:      nil,
+			wantTimedOut: false,
+		},
+		{
+			name:         "fails",
+			f:            func(context.Context) error { return expectedErr },
+			wantErr:      expectedErr,
+			wantTimedOut: false,
+		},
+		{
+			name: "honours cancellation",
+			f: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantTimedOut: true,
+		},
+		{
+			name: "ignores cancellation",
+			f: func(context.Context) error {
+				// Block for far longer than the timeout.
+				time.Sleep(time.Minute)
+				return nil
+			},
+			wantTimedOut: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RunWithTimeout(10*time.Millisecond, tt.f)
+			timedOut := errors.Is(err, context.DeadlineExceeded)
+			if timedOut != tt.wantTimedOut {
+				t.Errorf("RunWithTimeout() error = %v, wantTimedOut %v", err, tt.wantTimedOut)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("RunWithTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !tt.wantTimedOut && err != nil {
+				t.Errorf("RunWithTimeout() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRunWithCancellation(t *testing.T) {
+	// Released at the end of the test, so that a function blocking on it never stops while it is being waited for.
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	tests := []struct {
+		name          string
+		f             func(context.Context) error
+		wantReturned  bool
+		wantCancelled bool
+		wantErr       bool
+	}{
+		{
+			name:         "completes",
+			f:            func(context.Context) error { return nil },
+			wantReturned: true,
+		},
+		{
+			name: "stops when cancelled",
+			f: func(ctx context.Context) error {
+				<-ctx.Done()
+				return errors.New("stopped")
+			},
+			wantReturned:  true,
+			wantCancelled: true,
+			wantErr:       true,
+		},
+		{
+			name: "does not stop when cancelled",
+			f: func(ctx context.Context) error {
+				<-blocked
+				return nil
+			},
+			wantReturned:  false,
+			wantCancelled: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(10*time.Millisecond, cancel)
+
+			// The wait is long enough for a function that stops to be scheduled and return, even when other tests'
+			// goroutines are using the CPU.
+			returned, err := RunWithCancellation(ctx, time.Minute, 3*time.Second, tt.f)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RunWithCancellation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if returned != tt.wantReturned {
+				t.Errorf("RunWithCancellation() returned = %v, wantReturned %v", returned, tt.wantReturned)
+			}
+			if cancelled := errors.Is(err, context.Canceled); cancelled != tt.wantCancelled {
+				t.Errorf("RunWithCancellation() error = %v, wantCancelled %v", err, tt.wantCancelled)
+			}
+		})
+	}
+
+	// A timeout is not a cancellation.
+	returned, err := RunWithCancellation(context.Background(), 10*time.Millisecond, time.Minute, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	if returned || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunWithCancellation() returned = %v, error = %v, expected timeout", returned, err)
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServeHTTP starts serving the handler on the specified address (e.g. ":9090"), for the lifetime of the process. The
+// listener is created before this returns, so that an invalid address is reported immediately. Any later error is
+// sent to the error channel.
+func ServeHTTP(address string, handler http.Handler, errChan chan<- error) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %w", address, err)
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	slog.Info("Serving HTTP", "address", listener.Addr().String())
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- fmt.Errorf("error serving HTTP on %s: %w", address, err)
+		}
+	}()
+
+	return nil
+}
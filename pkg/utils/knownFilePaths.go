@@ -16,6 +16,7 @@ type KnownFilePaths struct {
 	NodeLogsList            string
 	Config                  string
 	Secret                  string
+	ServiceAccountNamespace string
 }
 
 type ConfigKey string
@@ -28,6 +29,19 @@ const (
 	NodeLogsLinuxKey     ConfigKey = "DIAGNOSTIC_NODELOGS_LIST_LINUX"
 	NodeLogsWindowsKey   ConfigKey = "DIAGNOSTIC_NODELOGS_LIST_WINDOWS"
 	RunIdKey             ConfigKey = "DIAGNOSTIC_RUN_ID"
+	ExporterKey          ConfigKey = "DIAGNOSTIC_EXPORTER"
+	ExportDirectoryKey   ConfigKey = "DIAGNOSTIC_EXPORT_DIRECTORY"
+	CollectorTimeoutsKey ConfigKey = "DIAGNOSTIC_COLLECTOR_TIMEOUTS"
+	StorageAuthModeKey   ConfigKey = "DIAGNOSTIC_STORAGE_AUTH_MODE"
+	UploadOptionsKey     ConfigKey = "DIAGNOSTIC_UPLOAD_OPTIONS"
+	RedactionRulesKey    ConfigKey = "DIAGNOSTIC_REDACTION_RULES"
+	EncryptionKey        ConfigKey = "DIAGNOSTIC_ENCRYPTION_RECIPIENTS"
+	SizeBudgetsKey       ConfigKey = "DIAGNOSTIC_SIZE_BUDGETS"
+	RunSizeBudgetKey     ConfigKey = "DIAGNOSTIC_RUN_SIZE_BUDGET"
+	ArchiveFormatKey     ConfigKey = "DIAGNOSTIC_ARCHIVE_FORMAT"
+	DeduplicationKey     ConfigKey = "DIAGNOSTIC_DEDUPLICATION_MAX_AGE"
+	ScheduleKey          ConfigKey = "DIAGNOSTIC_SCHEDULE"
+	DryRunKey            ConfigKey = "DIAGNOSTIC_DRY_RUN"
 )
 
 const (
@@ -35,6 +49,13 @@ const (
 	SasTokenKey      SecretKey = "AZURE_BLOB_SAS_KEY"
 	ContainerNameKey SecretKey = "AZURE_BLOB_CONTAINER_NAME"
 	SasTokenTypeKey  SecretKey = "AZURE_STORAGE_SAS_KEY_TYPE"
+	S3EndpointKey    SecretKey = "S3_ENDPOINT"
+	S3BucketKey      SecretKey = "S3_BUCKET"
+	S3RegionKey      SecretKey = "S3_REGION"
+	S3AccessKeyIdKey SecretKey = "S3_ACCESS_KEY_ID"
+	S3SecretKeyKey   SecretKey = "S3_SECRET_ACCESS_KEY"
+	AuthModeKey      SecretKey = "AZURE_BLOB_AUTH_MODE"
+	ClientIdKey      SecretKey = "AZURE_BLOB_CLIENT_ID"
 )
 
 // GetKnownFilePaths get known file paths
@@ -42,12 +63,13 @@ func GetKnownFilePaths(osIdentifier OSIdentifier) (*KnownFilePaths, error) {
 	switch osIdentifier {
 	case Windows:
 		return &KnownFilePaths{
-			AzureJson:           "/k/azure.json",
-			AzureStackCloudJson: "/k/azurestackcloud.json",
-			WindowsLogsOutput:   "/k/periscope-diagnostic-output",
-			NodeLogsList:        "/config/" + string(NodeLogsWindowsKey),
-			Config:              "/config",
-			Secret:              "/secret",
+			AzureJson:               "/k/azure.json",
+			AzureStackCloudJson:     "/k/azurestackcloud.json",
+			WindowsLogsOutput:       "/k/periscope-diagnostic-output",
+			NodeLogsList:            "/config/" + string(NodeLogsWindowsKey),
+			Config:                  "/config",
+			Secret:                  "/secret",
+			ServiceAccountNamespace: "/var/run/secrets/kubernetes.io/serviceaccount/namespace",
 		}, nil
 	case Linux:
 		// Since Azure Stack Hub does not support multiple node pools, we assume we don't need to worry about this for Windows
@@ -62,6 +84,7 @@ func GetKnownFilePaths(osIdentifier OSIdentifier) (*KnownFilePaths, error) {
 			NodeLogsList:            "/config/" + string(NodeLogsLinuxKey),
 			Config:                  "/config",
 			Secret:                  "/secret",
+			ServiceAccountNamespace: "/var/run/secrets/kubernetes.io/serviceaccount/namespace",
 		}, nil
 	default:
 		return nil, fmt.Errorf("unexpected OS: %s", osIdentifier)
@@ -32,8 +32,8 @@ func NewKubeCommandRunner(config *rest.Config) *KubeCommandRunner {
 }
 
 // GetTableOutput replicates 'kubectl get [kind] -o [table|wide]'.
-func (runner *KubeCommandRunner) GetTableOutput(gvr *schema.GroupVersionResource, namespace string, listOptions *metav1.ListOptions, printOptions *printers.PrintOptions) (string, error) {
-	table, err := runner.GetUnstructuredTable(gvr, namespace, listOptions)
+func (runner *KubeCommandRunner) GetTableOutput(ctx context.Context, gvr *schema.GroupVersionResource, namespace string, listOptions *metav1.ListOptions, printOptions *printers.PrintOptions) (string, error) {
+	table, err := runner.GetUnstructuredTable(ctx, gvr, namespace, listOptions)
 	if err != nil {
 		return "", fmt.Errorf("error requesting table for %s in %s: %w", gvr.String(), namespace, err)
 	}
@@ -47,8 +47,8 @@ func (runner *KubeCommandRunner) GetTableOutput(gvr *schema.GroupVersionResource
 }
 
 // GetJsonListOutput replicates 'kubectl get [kind] -o json'.
-func (runner *KubeCommandRunner) GetJsonListOutput(gvr *schema.GroupVersionResource, namespace string, listOptions *metav1.ListOptions) (string, error) {
-	list, err := runner.GetUnstructuredList(gvr, namespace, listOptions)
+func (runner *KubeCommandRunner) GetJsonListOutput(ctx context.Context, gvr *schema.GroupVersionResource, namespace string, listOptions *metav1.ListOptions) (string, error) {
+	list, err := runner.GetUnstructuredList(ctx, gvr, namespace, listOptions)
 	if err != nil {
 		return "", fmt.Errorf("error requesting all %s in %s: %w", gvr.String(), namespace, err)
 	}
@@ -62,8 +62,8 @@ func (runner *KubeCommandRunner) GetJsonListOutput(gvr *schema.GroupVersionResou
 }
 
 // GetYamlListOutput replicates 'kubectl get [kind] -o yaml'.
-func (runner *KubeCommandRunner) GetYamlListOutput(gvr *schema.GroupVersionResource, namespace string, listOptions *metav1.ListOptions) (string, error) {
-	list, err := runner.GetUnstructuredList(gvr, namespace, listOptions)
+func (runner *KubeCommandRunner) GetYamlListOutput(ctx context.Context, gvr *schema.GroupVersionResource, namespace string, listOptions *metav1.ListOptions) (string, error) {
+	list, err := runner.GetUnstructuredList(ctx, gvr, namespace, listOptions)
 	if err != nil {
 		return "", fmt.Errorf("error requesting all %s in %s: %w", gvr.String(), namespace, err)
 	}
@@ -77,8 +77,8 @@ func (runner *KubeCommandRunner) GetYamlListOutput(gvr *schema.GroupVersionResou
 }
 
 // GetJsonObjectOutput replicates 'kubectl get [kind] [name] -o json'.
-func (runner *KubeCommandRunner) GetJsonObjectOutput(gvr *schema.GroupVersionResource, namespace, name string) (string, error) {
-	obj, err := runner.GetUnstructuredItem(gvr, namespace, name)
+func (runner *KubeCommandRunner) GetJsonObjectOutput(ctx context.Context, gvr *schema.GroupVersionResource, namespace, name string) (string, error) {
+	obj, err := runner.GetUnstructuredItem(ctx, gvr, namespace, name)
 	if err != nil {
 		return "", fmt.Errorf("error requesting %s %s in %s: %w", gvr.String(), name, namespace, err)
 	}
@@ -92,8 +92,8 @@ func (runner *KubeCommandRunner) GetJsonObjectOutput(gvr *schema.GroupVersionRes
 }
 
 // GetYamlObjectOutput replicates 'kubectl get [kind] [name] -o yaml'.
-func (runner *KubeCommandRunner) GetYamlObjectOutput(gvr *schema.GroupVersionResource, namespace, name string) (string, error) {
-	obj, err := runner.GetUnstructuredItem(gvr, namespace, name)
+func (runner *KubeCommandRunner) GetYamlObjectOutput(ctx context.Context, gvr *schema.GroupVersionResource, namespace, name string) (string, error) {
+	obj, err := runner.GetUnstructuredItem(ctx, gvr, namespace, name)
 	if err != nil {
 		return "", fmt.Errorf("error requesting %s %s in %s: %w", gvr.String(), name, namespace, err)
 	}
@@ -107,7 +107,7 @@ func (runner *KubeCommandRunner) GetYamlObjectOutput(gvr *schema.GroupVersionRes
 }
 
 // GetUnstructuredList gets the API response to a List request in Unstructured form.
-func (runner *KubeCommandRunner) GetUnstructuredList(gvr *schema.GroupVersionResource, namespace string, options *metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+func (runner *KubeCommandRunner) GetUnstructuredList(ctx context.Context, gvr *schema.GroupVersionResource, namespace string, options *metav1.ListOptions) (*unstructured.UnstructuredList, error) {
 	request, err := runner.getUnstructuredRequest(gvr, false)
 	if err != nil {
 		return nil, fmt.Errorf("error getting request for JSON: %w", err)
@@ -115,7 +115,7 @@ func (runner *KubeCommandRunner) GetUnstructuredList(gvr *schema.GroupVersionRes
 
 	request = request.NamespaceIfScoped(namespace, namespace != "").VersionedParams(options, metav1.ParameterCodec)
 
-	obj, err := request.Do(context.Background()).Get()
+	obj, err := request.Do(ctx).Get()
 	if err != nil {
 		return nil, fmt.Errorf("error executing request: %w", err)
 	}
@@ -124,7 +124,7 @@ func (runner *KubeCommandRunner) GetUnstructuredList(gvr *schema.GroupVersionRes
 }
 
 // GetUnstructuredTable gets the API response to a List request for a server-generated table, in Unstructured form.
-func (runner *KubeCommandRunner) GetUnstructuredTable(gvr *schema.GroupVersionResource, namespace string, options *metav1.ListOptions) (*unstructured.Unstructured, error) {
+func (runner *KubeCommandRunner) GetUnstructuredTable(ctx context.Context, gvr *schema.GroupVersionResource, namespace string, options *metav1.ListOptions) (*unstructured.Unstructured, error) {
 	request, err := runner.getUnstructuredRequest(gvr, true)
 	if err != nil {
 		return nil, fmt.Errorf("error getting request for table: %w", err)
@@ -132,7 +132,7 @@ func (runner *KubeCommandRunner) GetUnstructuredTable(gvr *schema.GroupVersionRe
 
 	request = request.NamespaceIfScoped(namespace, namespace != "").VersionedParams(options, metav1.ParameterCodec)
 
-	obj, err := request.Do(context.Background()).Get()
+	obj, err := request.Do(ctx).Get()
 	if err != nil {
 		return nil, fmt.Errorf("error executing request: %w", err)
 	}
@@ -141,7 +141,7 @@ func (runner *KubeCommandRunner) GetUnstructuredTable(gvr *schema.GroupVersionRe
 }
 
 // GetUnstructuredItem gets the API response to a Get request in Unstructured form.
-func (runner *KubeCommandRunner) GetUnstructuredItem(gvr *schema.GroupVersionResource, namespace string, name string) (*unstructured.Unstructured, error) {
+func (runner *KubeCommandRunner) GetUnstructuredItem(ctx context.Context, gvr *schema.GroupVersionResource, namespace string, name string) (*unstructured.Unstructured, error) {
 	request, err := runner.getUnstructuredRequest(gvr, false)
 	if err != nil {
 		return nil, fmt.Errorf("error getting request for JSON: %w", err)
@@ -149,7 +149,7 @@ func (runner *KubeCommandRunner) GetUnstructuredItem(gvr *schema.GroupVersionRes
 
 	request = request.NamespaceIfScoped(namespace, namespace != "").VersionedParams(&metav1.GetOptions{}, metav1.ParameterCodec).Name(name)
 
-	obj, err := request.Do(context.Background()).Get()
+	obj, err := request.Do(ctx).Get()
 	if err != nil {
 		return nil, fmt.Errorf("error executing request: %w", err)
 	}
@@ -243,9 +243,9 @@ var crdGvrs = []schema.GroupVersionResource{
 }
 
 // GetCRDUnstructuredList reads all the CRDs in the cluster and returns the result as an UnstructuredList.
-func (runner *KubeCommandRunner) GetCRDUnstructuredList() (*unstructured.UnstructuredList, error) {
+func (runner *KubeCommandRunner) GetCRDUnstructuredList(ctx context.Context) (*unstructured.UnstructuredList, error) {
 	for _, gvr := range crdGvrs {
-		crds, err := runner.GetUnstructuredList(&gvr, "", &metav1.ListOptions{})
+		crds, err := runner.GetUnstructuredList(ctx, &gvr, "", &metav1.ListOptions{})
 		if err != nil {
 			if k8sErrors.IsNotFound(err) {
 				continue
@@ -261,9 +261,9 @@ func (runner *KubeCommandRunner) GetCRDUnstructuredList() (*unstructured.Unstruc
 
 // GetGVRForCRD gets the GroupVersionResource for the specified CRD (where Version is the 'storage'
 // version for the resources).
-func (runner *KubeCommandRunner) GetGVRForCRD(crdName string) (*schema.GroupVersionResource, error) {
+func (runner *KubeCommandRunner) GetGVRForCRD(ctx context.Context, crdName string) (*schema.GroupVersionResource, error) {
 	for _, gvr := range crdGvrs {
-		crd, err := runner.GetUnstructuredItem(&gvr, "", crdName)
+		crd, err := runner.GetUnstructuredItem(ctx, &gvr, "", crdName)
 		if err != nil {
 			if k8sErrors.IsNotFound(err) {
 				continue
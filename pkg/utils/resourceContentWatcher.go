@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/aks-periscope/pkg/interfaces"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap mounted at the config path.
+	ConfigMapName = "diagnostic-config"
+	// SecretName is the name of the Secret mounted at the secret path.
+	SecretName = "azureblob-secret"
+)
+
+// ContentWatcher sends notifications via a channel when the content of a config or secret file changes, or when there
+// is an error reading it.
+type ContentWatcher interface {
+	AddHandler(filePath string, contentChan chan string, errChan chan error)
+	Start()
+}
+
+// ResourceContentWatcher watches the diagnostic ConfigMap and Secret through the Kubernetes API, and sends the same
+// notifications as a FileContentWatcher for the files they are mounted as. Unlike the mounted files, which are only
+// updated when the kubelet next syncs the volume, changes are seen as soon as they are made.
+//
+// It is also a FileSystemAccessor, serving the config and secret files from the watched resources (so that they are
+// consistent with the notifications), and all other files from the underlying file system.
+type ResourceContentWatcher struct {
+	client         kubernetes.Interface
+	namespace      string
+	knownFilePaths *KnownFilePaths
+	fileSystem     interfaces.FileSystemAccessor
+	reader         interfaces.FileSystemAccessor
+	dataLock       sync.RWMutex
+	configData     map[string]string
+	secretData     map[string]string
+	notifyLock     sync.Mutex
+	items          map[string]*fileContentItem
+	started        bool
+	synced         bool
+}
+
+// NewResourceContentWatcher constructs a ResourceContentWatcher for the diagnostic ConfigMap and Secret in the specified
+// namespace. This will initially contain no handlers, and will not start watching until the Start method is called.
+func NewResourceContentWatcher(client kubernetes.Interface, namespace string, knownFilePaths *KnownFilePaths, fileSystem interfaces.FileSystemAccessor) *ResourceContentWatcher {
+	return &ResourceContentWatcher{
+		client:         client,
+		namespace:      namespace,
+		knownFilePaths: knownFilePaths,
+		fileSystem:     fileSystem,
+		items:          map[string]*fileContentItem{},
+	}
+}
+
+// AddHandler supplies channels for receiving notifications when the specified config or secret file is read or changed,
+// or when there is an error reading it. No notifications will be sent until the Start method is called.
+func (w *ResourceContentWatcher) AddHandler(filePath string, contentChan chan string, errChan chan error) {
+	w.notifyLock.Lock()
+	defer w.notifyLock.Unlock()
+
+	filePath = filepath.Clean(filePath)
+	if item, ok := w.items[filePath]; ok {
+		item.contentHandlers = append(item.contentHandlers, contentChan)
+		item.errorHandlers = append(item.errorHandlers, errChan)
+	} else {
+		w.items[filePath] = &fileContentItem{
+			contentHandlers: []chan string{contentChan},
+			errorHandlers:   []chan error{errChan},
+		}
+	}
+}
+
+// SetReader sets the FileSystemAccessor the content of the watched files is read from when notifying handlers. By
+// default this is the ResourceContentWatcher itself, but it can be replaced with one that wraps it (e.g. to record the
+// reads). This must be called before the Start method.
+func (w *ResourceContentWatcher) SetReader(reader interfaces.FileSystemAccessor) {
+	w.notifyLock.Lock()
+	defer w.notifyLock.Unlock()
+
+	w.reader = reader
+}
+
+// Start tells the ResourceContentWatcher to watch the ConfigMap and Secret. Once their current state is known, handlers
+// are notified of the content of their files, and then again whenever the resources change.
+func (w *ResourceContentWatcher) Start() {
+	w.notifyLock.Lock()
+	if w.started {
+		w.notifyLock.Unlock()
+		return
+	}
+	w.started = true
+	w.notifyLock.Unlock()
+
+	configMapInformer, configMapSynced := w.newInformer(ConfigMapName, &corev1.ConfigMap{},
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return w.client.CoreV1().ConfigMaps(w.namespace).List(ctx, options)
+		},
+		func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			return w.client.CoreV1().ConfigMaps(w.namespace).Watch(ctx, options)
+		},
+		func(obj interface{}) {
+			w.setData(w.knownFilePaths.Config, &w.configData, configMapData(obj))
+		},
+	)
+
+	secretInformer, secretSynced := w.newInformer(SecretName, &corev1.Secret{},
+		func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return w.client.CoreV1().Secrets(w.namespace).List(ctx, options)
+		},
+		func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			return w.client.CoreV1().Secrets(w.namespace).Watch(ctx, options)
+		},
+		func(obj interface{}) {
+			w.setData(w.knownFilePaths.Secret, &w.secretData, secretData(obj))
+		},
+	)
+
+	// Like the FileContentWatcher, this runs for the lifetime of the process.
+	stopChan := make(chan struct{})
+	go configMapInformer.Run(stopChan)
+	go secretInformer.Run(stopChan)
+
+	go func() {
+		cache.WaitForCacheSync(stopChan, configMapSynced, secretSynced)
+
+		// Send the initial notifications, including errors for files that don't exist because the resources don't.
+		w.notifyLock.Lock()
+		defer w.notifyLock.Unlock()
+		w.synced = true
+		w.checkFilePaths("")
+	}()
+}
+
+func (w *ResourceContentWatcher) newInformer(
+	name string,
+	objType runtime.Object,
+	list func(context.Context, metav1.ListOptions) (runtime.Object, error),
+	watchFunc func(context.Context, metav1.ListOptions) (watch.Interface, error),
+	update func(obj interface{}),
+) (cache.SharedIndexInformer, cache.InformerSynced) {
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", name).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return list(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return watchFunc(context.Background(), options)
+		},
+	}
+
+	// Ignore objects with other names, in case the field selector is not applied.
+	isWatched := func(obj interface{}) bool {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, ok := obj.(metav1.Object)
+		return ok && object.GetName() == name
+	}
+
+	informer := cache.NewSharedIndexInformer(listWatch, objType, 0, cache.Indexers{})
+	// The handler has synced once it has been called for all the objects in the initial list.
+	registration, _ := informer.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: isWatched,
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    update,
+			UpdateFunc: func(_, newObj interface{}) { update(newObj) },
+			DeleteFunc: func(interface{}) { update(nil) },
+		},
+	})
+
+	return informer, registration.HasSynced
+}
+
+func configMapData(obj interface{}) map[string]string {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return nil
+	}
+
+	data := map[string]string{}
+	for key, value := range configMap.BinaryData {
+		data[key] = string(value)
+	}
+	for key, value := range configMap.Data {
+		data[key] = value
+	}
+	return data
+}
+
+func secretData(obj interface{}) map[string]string {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return nil
+	}
+
+	data := map[string]string{}
+	for key, value := range secret.Data {
+		data[key] = string(value)
+	}
+	return data
+}
+
+// setData replaces the data of the resource mounted in the specified directory (nil if it doesn't exist), and notifies
+// the handlers of its files. Nothing is sent until both resources have been synced, so that a run started by the first
+// notification sees the current content of both.
+func (w *ResourceContentWatcher) setData(directoryPath string, target *map[string]string, data map[string]string) {
+	w.notifyLock.Lock()
+	defer w.notifyLock.Unlock()
+
+	w.dataLock.Lock()
+	*target = data
+	w.dataLock.Unlock()
+
+	if w.synced {
+		w.checkFilePaths(directoryPath)
+	}
+}
+
+// checkFilePaths notifies handlers of changed files in the specified directory, or in all directories if it is empty.
+// As with the FileContentWatcher, errors are sent every time the files are checked, but only the files of a resource
+// that changed are checked, so changes to one resource don't repeat the errors for the other.
+func (w *ResourceContentWatcher) checkFilePaths(directoryPath string) {
+	for filePath, item := range w.items {
+		if directoryPath != "" && filepath.Dir(filePath) != filepath.Clean(directoryPath) {
+			continue
+		}
+
+		content, err := w.getContent(filePath)
+		if item.updateIfChanged(content, err) {
+			item.handleUpdated(filePath)
+		}
+	}
+}
+
+// getData gets the data of the resource mounted in the specified directory, if any.
+func (w *ResourceContentWatcher) getData(directoryPath string) (map[string]string, string, bool) {
+	directoryPath = filepath.Clean(directoryPath)
+	switch directoryPath {
+	case filepath.Clean(w.knownFilePaths.Config):
+		return w.configData, fmt.Sprintf("ConfigMap %s/%s", w.namespace, ConfigMapName), true
+	case filepath.Clean(w.knownFilePaths.Secret):
+		return w.secretData, fmt.Sprintf("Secret %s/%s", w.namespace, SecretName), true
+	default:
+		return nil, "", false
+	}
+}
+
+// lookup gets the content of a file in one of the watched resources. ok is false if the file is elsewhere.
+func (w *ResourceContentWatcher) lookup(filePath string) (content string, exists bool, description string, ok bool) {
+	w.dataLock.RLock()
+	defer w.dataLock.RUnlock()
+
+	filePath = filepath.Clean(filePath)
+	data, description, ok := w.getData(filepath.Dir(filePath))
+	if !ok {
+		return "", false, "", false
+	}
+
+	content, exists = data[filepath.Base(filePath)]
+	return content, exists, description, true
+}
+
+func (w *ResourceContentWatcher) getContent(filePath string) (string, error) {
+	var reader interfaces.FileSystemAccessor = w
+	if w.reader != nil {
+		reader = w.reader
+	}
+	return GetContent(func() (io.ReadCloser, error) { return reader.GetFileReader(filePath) })
+}
+
+func (w *ResourceContentWatcher) GetFileReader(filePath string) (io.ReadCloser, error) {
+	content, exists, description, ok := w.lookup(filePath)
+	if !ok {
+		return w.fileSystem.GetFileReader(filePath)
+	}
+	if !exists {
+		return nil, fmt.Errorf("key %s not found in %s: %w", filepath.Base(filePath), description, os.ErrNotExist)
+	}
+
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func (w *ResourceContentWatcher) FileExists(filePath string) (bool, error) {
+	_, exists, _, ok := w.lookup(filePath)
+	if !ok {
+		return w.fileSystem.FileExists(filePath)
+	}
+
+	return exists, nil
+}
+
+func (w *ResourceContentWatcher) GetFileSize(filePath string) (int64, error) {
+	content, exists, description, ok := w.lookup(filePath)
+	if !ok {
+		return w.fileSystem.GetFileSize(filePath)
+	}
+	if !exists {
+		return 0, fmt.Errorf("key %s not found in %s: %w", filepath.Base(filePath), description, os.ErrNotExist)
+	}
+
+	return int64(len(content)), nil
+}
+
+func (w *ResourceContentWatcher) ListFiles(directoryPath string) ([]string, error) {
+	w.dataLock.RLock()
+	defer w.dataLock.RUnlock()
+
+	data, _, ok := w.getData(directoryPath)
+	if !ok {
+		return w.fileSystem.ListFiles(directoryPath)
+	}
+
+	paths := []string{}
+	for key := range data {
+		// Always use forward-slash-separated paths for consistency
+		paths = append(paths, filepath.ToSlash(filepath.Join(directoryPath, key)))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
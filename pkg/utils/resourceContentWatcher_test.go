@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResourceContentWatcher(t *testing.T) {
+	const namespace = "aks-periscope"
+	knownFilePaths := &KnownFilePaths{Config: "/config", Secret: "/secret"}
+	fs := test.NewFakeFileSystem(map[string]string{"/etc/hostfile": "host content"})
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: namespace},
+		Data:       map[string]string{string(RunIdKey): "run1"},
+	}
+	client := fake.NewSimpleClientset(
+		configMap,
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-config", Namespace: namespace},
+			Data:       map[string]string{string(RunIdKey): "other"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: namespace},
+			Data:       map[string][]byte{string(AccountNameKey): []byte("account")},
+		},
+	)
+
+	watcher := NewResourceContentWatcher(client, namespace, knownFilePaths, fs)
+
+	runIdHandler := newFileEventHandler("runId")
+	watcher.AddHandler(knownFilePaths.GetConfigPath(RunIdKey), runIdHandler.contentHandler, runIdHandler.errorHandler)
+	accountHandler := newFileEventHandler("account")
+	watcher.AddHandler(knownFilePaths.GetSecretPath(AccountNameKey), accountHandler.contentHandler, accountHandler.errorHandler)
+	missingHandler := newFileEventHandler("missing")
+	watcher.AddHandler(knownFilePaths.GetConfigPath(ScheduleKey), missingHandler.contentHandler, missingHandler.errorHandler)
+
+	expectNotifications := func(stage string, notifications map[*fileEventHandler][]fileEventNotification) {
+		for handler, expected := range notifications {
+			handler.expect(expected)
+			handler.listen()
+		}
+		for handler := range notifications {
+			select {
+			case err := <-handler.finished:
+				if err != nil {
+					t.Errorf("%s: handler %s finished with error: %v", stage, handler.name, err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("%s: timed out waiting for notifications for handler %s", stage, handler.name)
+			}
+		}
+	}
+
+	watcher.Start()
+	expectNotifications("initial", map[*fileEventHandler][]fileEventNotification{
+		runIdHandler:   {{content: "run1"}},
+		accountHandler: {{content: "account"}},
+		missingHandler: {{isError: true}},
+	})
+
+	// The watched files are read from the resources, and all others from the underlying file system.
+	if content, err := GetContent(func() (io.ReadCloser, error) { return watcher.GetFileReader("/config/" + string(RunIdKey)) }); err != nil || content != "run1" {
+		t.Errorf("unexpected run ID content '%s', error %v", content, err)
+	}
+	if exists, err := watcher.FileExists(knownFilePaths.GetConfigPath(ScheduleKey)); err != nil || exists {
+		t.Errorf("expected missing key not to exist, found exists %v, error %v", exists, err)
+	}
+	if _, err := watcher.GetFileReader(knownFilePaths.GetConfigPath(ScheduleKey)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist error for missing key, found %v", err)
+	}
+	if size, err := watcher.GetFileSize(knownFilePaths.GetSecretPath(AccountNameKey)); err != nil || size != int64(len("account")) {
+		t.Errorf("unexpected size %d, error %v", size, err)
+	}
+	if content, err := GetContent(func() (io.ReadCloser, error) { return watcher.GetFileReader("/etc/hostfile") }); err != nil || content != "host content" {
+		t.Errorf("unexpected host file content '%s', error %v", content, err)
+	}
+	if paths, err := watcher.ListFiles("/config"); err != nil || !reflect.DeepEqual(paths, []string{"/config/" + string(RunIdKey)}) {
+		t.Errorf("unexpected config files %v, error %v", paths, err)
+	}
+
+	// A change to the ConfigMap notifies handlers of its files, but not of the Secret's.
+	configMap = configMap.DeepCopy()
+	configMap.Data[string(RunIdKey)] = "run2"
+	configMap.Data[string(ScheduleKey)] = "@daily"
+	if _, err := client.CoreV1().ConfigMaps(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating ConfigMap: %v", err)
+	}
+	expectNotifications("changed", map[*fileEventHandler][]fileEventNotification{
+		runIdHandler:   {{content: "run2"}},
+		missingHandler: {{content: "@daily"}},
+	})
+
+	// Deleting the ConfigMap results in errors for its files.
+	if err := client.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), ConfigMapName, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("error deleting ConfigMap: %v", err)
+	}
+	expectNotifications("deleted", map[*fileEventHandler][]fileEventNotification{
+		runIdHandler:   {{isError: true}},
+		missingHandler: {{isError: true}},
+	})
+
+	// The Secret handler had no further notifications.
+	select {
+	case content := <-accountHandler.contentHandler:
+		t.Errorf("unexpected Secret notification: %s", content)
+	case <-accountHandler.errorHandler:
+		t.Errorf("unexpected Secret error notification")
+	default:
+	}
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/logging"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// runLeaseLabel identifies the Leases used to elect the pod that runs the cluster-scoped collectors.
+	runLeaseLabel = "aks-periscope.azure.github.com/run-lease"
+	// runIdAnnotation records the run ID a Lease is for, since run IDs are not necessarily valid resource names.
+	runIdAnnotation = "aks-periscope.azure.github.com/run-id"
+	// completedAnnotation is set when the holder of a Lease has completed the run, so that it is never taken over.
+	completedAnnotation = "aks-periscope.azure.github.com/completed"
+
+	// RunLeaseDuration is how long a Lease is held without being renewed before another pod can take over the run.
+	RunLeaseDuration = time.Minute
+	// runLeaseRetention is how long Leases for previous runs are kept before being deleted.
+	runLeaseRetention = 24 * time.Hour
+
+	// runLeaseComponent is the component lines about run Leases are logged with.
+	runLeaseComponent = "election"
+)
+
+// RunLeaseElector elects a single pod to collect cluster-level data for each run, using a Lease per run ID. The first
+// pod to create the Lease holds it for the duration of the run, renewing it until the run is complete. If the holder
+// stops renewing it before completing the run (e.g. because the pod was deleted), a pod that starts the same run
+// later can take it over. The run ID must be the same on every pod for the same run, as both configured run IDs and
+// the IDs of scheduled runs (generated from the scheduled time) are.
+type RunLeaseElector struct {
+	client    kubernetes.Interface
+	namespace string
+	identity  string
+	duration  time.Duration
+}
+
+// NewRunLeaseElector creates a RunLeaseElector which creates Leases in the specified namespace, held by the specified
+// identity (which must be unique to the pod).
+func NewRunLeaseElector(client kubernetes.Interface, namespace string, identity string) *RunLeaseElector {
+	return &RunLeaseElector{
+		client:    client,
+		namespace: namespace,
+		identity:  identity,
+		duration:  RunLeaseDuration,
+	}
+}
+
+// Elect tries to acquire the Lease for the specified run, returning its holder. If this pod is the holder, the Lease
+// is renewed until the returned release function is called at the end of the run. Otherwise, release is nil.
+func (e *RunLeaseElector) Elect(ctx context.Context, runId string) (holder string, release func(), err error) {
+	leases := e.client.CoordinationV1().Leases(e.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	durationSeconds := int32(e.duration.Seconds())
+
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        getRunLeaseName(runId),
+			Namespace:   e.namespace,
+			Labels:      map[string]string{runLeaseLabel: "true"},
+			Annotations: map[string]string{runIdAnnotation: runId},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &e.identity,
+			LeaseDurationSeconds: &durationSeconds,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+
+	created, err := leases.Create(ctx, lease, metav1.CreateOptions{})
+	if err == nil {
+		e.deleteExpiredLeases(ctx)
+		return e.identity, e.hold(created), nil
+	}
+	if !k8sErrors.IsAlreadyExists(err) {
+		return "", nil, fmt.Errorf("error creating Lease %s: %w", lease.Name, err)
+	}
+
+	existing, err := leases.Get(ctx, lease.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting Lease %s: %w", lease.Name, err)
+	}
+
+	// The run is taken over if it was started by this pod (e.g. before the container restarted), or if its holder
+	// stopped renewing the Lease before completing it.
+	existingHolder := ""
+	if existing.Spec.HolderIdentity != nil {
+		existingHolder = *existing.Spec.HolderIdentity
+	}
+	if existingHolder != e.identity && !isAbandoned(existing, now.Time) {
+		return existingHolder, nil, nil
+	}
+
+	existing.Spec.HolderIdentity = &e.identity
+	existing.Spec.LeaseDurationSeconds = &durationSeconds
+	existing.Spec.AcquireTime = &now
+	existing.Spec.RenewTime = &now
+	delete(existing.Annotations, completedAnnotation)
+
+	updated, err := leases.Update(ctx, existing, metav1.UpdateOptions{})
+	if k8sErrors.IsConflict(err) {
+		// Another pod took it over first.
+		current, err := leases.Get(ctx, lease.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, fmt.Errorf("error getting Lease %s: %w", lease.Name, err)
+		}
+		if current.Spec.HolderIdentity == nil {
+			return "", nil, nil
+		}
+		return *current.Spec.HolderIdentity, nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error taking over Lease %s from %s: %w", lease.Name, existingHolder, err)
+	}
+
+	slog.Info("Took over run Lease", logging.ComponentKey, runLeaseComponent, "lease", lease.Name, logging.RunIdKey, runId, "previousHolder", existingHolder)
+	return e.identity, e.hold(updated), nil
+}
+
+// hold renews the Lease until the returned function is called, which then marks the run as completed.
+func (e *RunLeaseElector) hold(lease *coordinationv1.Lease) func() {
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+
+	go func() {
+		defer close(doneChan)
+
+		ticker := time.NewTicker(e.duration / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+				if err := e.renew(lease.Name, false); err != nil {
+					slog.Warn("Could not renew run Lease", logging.ComponentKey, runLeaseComponent, "lease", lease.Name, logging.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stopChan)
+		<-doneChan
+
+		if err := e.renew(lease.Name, true); err != nil {
+			slog.Warn("Could not mark run Lease as completed", logging.ComponentKey, runLeaseComponent, "lease", lease.Name, logging.Error(err))
+		}
+	}
+}
+
+func (e *RunLeaseElector) renew(name string, completed bool) error {
+	return RunWithTimeout(e.duration/3, func(ctx context.Context) error {
+		leases := e.client.CoordinationV1().Leases(e.namespace)
+		lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != e.identity {
+			return fmt.Errorf("Lease has been taken over")
+		}
+
+		now := metav1.NewMicroTime(time.Now())
+		lease.Spec.RenewTime = &now
+		if completed {
+			if lease.Annotations == nil {
+				lease.Annotations = map[string]string{}
+			}
+			lease.Annotations[completedAnnotation] = "true"
+		}
+
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// deleteExpiredLeases deletes the Leases of previous runs, so that they don't accumulate. Errors are only logged,
+// since they don't affect the current run.
+func (e *RunLeaseElector) deleteExpiredLeases(ctx context.Context) {
+	leases := e.client.CoordinationV1().Leases(e.namespace)
+	list, err := leases.List(ctx, metav1.ListOptions{LabelSelector: runLeaseLabel + "=true"})
+	if err != nil {
+		slog.Warn("Could not list Leases of previous runs", logging.ComponentKey, runLeaseComponent, logging.Error(err))
+		return
+	}
+
+	for _, lease := range list.Items {
+		if lease.Spec.RenewTime == nil || time.Since(lease.Spec.RenewTime.Time) < runLeaseRetention {
+			continue
+		}
+		if err := leases.Delete(ctx, lease.Name, metav1.DeleteOptions{}); err != nil && !k8sErrors.IsNotFound(err) {
+			slog.Warn("Could not delete Lease of previous run", logging.ComponentKey, runLeaseComponent, "lease", lease.Name, logging.Error(err))
+		}
+	}
+}
+
+// isAbandoned returns true if the Lease's holder stopped renewing it before completing the run.
+func isAbandoned(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Annotations[completedAnnotation] == "true" {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.After(expiry)
+}
+
+// getRunLeaseName gets a valid resource name for the Lease of a run.
+func getRunLeaseName(runId string) string {
+	hash := sha256.Sum256([]byte(runId))
+	return "aks-periscope-run-" + hex.EncodeToString(hash[:8])
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunLeaseElector(t *testing.T) {
+	const namespace = "aks-periscope"
+	client := fake.NewSimpleClientset()
+	ctx := context.TODO()
+
+	elect := func(identity, runId string) (string, func()) {
+		holder, release, err := NewRunLeaseElector(client, namespace, identity).Elect(ctx, runId)
+		if err != nil {
+			t.Fatalf("%s: Elect() error = %v", identity, err)
+		}
+		if (holder == identity) != (release != nil) {
+			t.Errorf("%s: expected release function only for the holder, found holder %s", identity, holder)
+		}
+		return holder, release
+	}
+
+	// The first pod to start the run holds the Lease, and the others don't collect cluster-level data.
+	holder, release := elect("node1", "run1")
+	if holder != "node1" {
+		t.Errorf("expected node1 to hold the Lease, found %s", holder)
+	}
+	if holder, _ := elect("node2", "run1"); holder != "node1" {
+		t.Errorf("expected node2 to see node1 holding the Lease, found %s", holder)
+	}
+
+	// The same pod can start the same run again (e.g. after restarting).
+	if holder, restartRelease := elect("node1", "run1"); holder != "node1" {
+		t.Errorf("expected node1 to hold the Lease again, found %s", holder)
+	} else {
+		release = restartRelease
+	}
+
+	// Each run has its own Lease.
+	if holder, otherRelease := elect("node2", "run2"); holder != "node2" {
+		t.Errorf("expected node2 to hold the Lease for run2, found %s", holder)
+	} else {
+		otherRelease()
+	}
+
+	release()
+	lease, err := client.CoordinationV1().Leases(namespace).Get(ctx, getRunLeaseName("run1"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting Lease: %v", err)
+	}
+	if lease.Annotations[completedAnnotation] != "true" || lease.Annotations[runIdAnnotation] != "run1" {
+		t.Errorf("unexpected Lease annotations: %v", lease.Annotations)
+	}
+
+	// A completed run is never taken over, even once its Lease has expired.
+	expired := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	lease.Spec.RenewTime = &expired
+	if _, err := client.CoordinationV1().Leases(namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating Lease: %v", err)
+	}
+	if holder, _ := elect("node3", "run1"); holder != "node1" {
+		t.Errorf("expected completed run to keep its holder, found %s", holder)
+	}
+
+	// A run whose holder stopped renewing the Lease before completing it is taken over.
+	delete(lease.Annotations, completedAnnotation)
+	if _, err := client.CoordinationV1().Leases(namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating Lease: %v", err)
+	}
+	if holder, takeoverRelease := elect("node3", "run1"); holder != "node3" {
+		t.Errorf("expected node3 to take over abandoned run, found %s", holder)
+	} else {
+		takeoverRelease()
+	}
+}
+
+func TestRunLeaseElectorScheduledRun(t *testing.T) {
+	const namespace = "aks-periscope"
+	client := fake.NewSimpleClientset()
+	nodes := []string{"node1", "node2"}
+
+	schedule, err := ParseSchedule("@every 30m")
+	if err != nil {
+		t.Fatalf("ParseSchedule() error = %v", err)
+	}
+
+	// Each node evaluates the schedule at a different time, as the pods are started at different times, and elects a
+	// holder for the scheduled run it is triggered for, at the same time as the other nodes.
+	start := time.Date(2024, time.March, 1, 10, 4, 5, 0, time.UTC)
+	holders := make(chan string, len(nodes))
+	for i, node := range nodes {
+		runId := triggerScheduledRun(schedule, start.Add(time.Duration(i)*7*time.Minute))
+
+		go func(node string) {
+			holder, release, err := NewRunLeaseElector(client, namespace, node).Elect(context.TODO(), runId)
+			if err != nil {
+				t.Errorf("%s: Elect() error = %v", node, err)
+			}
+			if release != nil {
+				release()
+			}
+			holders <- holder
+		}(node)
+	}
+
+	elected := map[string]bool{}
+	for range nodes {
+		select {
+		case holder := <-holders:
+			elected[holder] = true
+		case <-time.After(10 * time.Second):
+			t.Fatalf("expected every node to elect a holder")
+		}
+	}
+
+	if len(elected) != 1 || elected[""] {
+		t.Errorf("expected both nodes to elect the same holder, found %v", elected)
+	}
+
+	leases, err := client.CoordinationV1().Leases(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("error listing Leases: %v", err)
+	}
+	if len(leases.Items) != 1 {
+		t.Errorf("expected a single Lease for the scheduled run, found %d", len(leases.Items))
+	}
+}
+
+func TestRunLeaseElectorDeletesExpiredLeases(t *testing.T) {
+	const namespace = "aks-periscope"
+	old := metav1.NewMicroTime(time.Now().Add(-2 * runLeaseRetention))
+	client := fake.NewSimpleClientset(
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: getRunLeaseName("old"), Namespace: namespace, Labels: map[string]string{runLeaseLabel: "true"}},
+			Spec:       coordinationv1.LeaseSpec{RenewTime: &old},
+		},
+		&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: namespace},
+			Spec:       coordinationv1.LeaseSpec{RenewTime: &old},
+		},
+	)
+
+	_, release, err := NewRunLeaseElector(client, namespace, "node1").Elect(context.TODO(), "new")
+	if err != nil {
+		t.Fatalf("Elect() error = %v", err)
+	}
+	release()
+
+	leases, err := client.CoordinationV1().Leases(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("error listing Leases: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, lease := range leases.Items {
+		names[lease.Name] = true
+	}
+	if names[getRunLeaseName("old")] || !names["unrelated"] || !names[getRunLeaseName("new")] {
+		t.Errorf("unexpected Leases after deleting expired ones: %v", names)
+	}
+}
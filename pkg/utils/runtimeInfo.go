@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/aks-periscope/pkg/interfaces"
 	"github.com/hashicorp/go-multierror"
@@ -21,9 +23,15 @@ func getKnownFeatures() []Feature {
 	return []Feature{WindowsHpc}
 }
 
+// DefaultCollectorTimeout is the deadline applied to collectors (and diagnosers) when none is configured.
+// It needs to be longer than the time the Windows logs collector waits for its separate collection process.
+const DefaultCollectorTimeout = 30 * time.Minute
+
 type RuntimeInfo struct {
 	RunId                   string
+	Scheduled               bool
 	HostNodeName            string
+	Namespace               string
 	CollectorList           []string
 	KubernetesObjects       []string
 	NodeLogs                []string
@@ -32,12 +40,35 @@ type RuntimeInfo struct {
 	StorageSasKey           string
 	StorageContainerName    string
 	StorageSasKeyType       string
+	StorageAuthMode         string
+	StorageClientId         string
+	S3Endpoint              string
+	S3Bucket                string
+	S3Region                string
+	S3AccessKeyId           string
+	S3SecretAccessKey       string
+	Exporter                string
+	ExportDirectory         string
+	CollectorTimeout        time.Duration
+	CollectorTimeouts       map[string]time.Duration
+	UploadOptions           *UploadOptions
+	SizeBudget              int64
+	SizeBudgets             map[string]int64
+	RunSizeBudget           int64
+	ArchiveFormat           string
+	DeduplicationMaxAge     time.Duration
+	RedactionRules          []string
+	EncryptionRecipients    string
+	DryRun                  bool
 	Features                map[Feature]bool
 }
 
-// GetRuntimeInfo gets runtime info
+// GetRuntimeInfo gets runtime info. If the config and secret can be read, but some of their values are invalid, the
+// runtime info is returned along with the error (with defaults in place of the invalid values), so that the run can be
+// reported as failed rather than stopping Periscope.
 func GetRuntimeInfo(fs interfaces.FileSystemAccessor, filePaths *KnownFilePaths) (*RuntimeInfo, error) {
 	var errs error
+	var valueErrs error
 
 	// Config
 	runId, errs := readFileContent(fs, filePaths.GetConfigPath(RunIdKey), true, errs)
@@ -45,12 +76,69 @@ func GetRuntimeInfo(fs interfaces.FileSystemAccessor, filePaths *KnownFilePaths)
 	kubernetesObjects, errs := readFileContent(fs, filePaths.GetConfigPath(KubeObjectsListKey), false, errs)
 	nodeLogs, errs := readFileContent(fs, filePaths.NodeLogsList, false, errs)
 	containerLogsNamespaces, errs := readFileContent(fs, filePaths.GetConfigPath(ContainerLogsListKey), false, errs)
+	exporter, errs := readFileContent(fs, filePaths.GetConfigPath(ExporterKey), false, errs)
+	exportDirectory, errs := readFileContent(fs, filePaths.GetConfigPath(ExportDirectoryKey), false, errs)
+	collectorTimeoutsValue, errs := readFileContent(fs, filePaths.GetConfigPath(CollectorTimeoutsKey), false, errs)
+	configStorageAuthMode, errs := readFileContent(fs, filePaths.GetConfigPath(StorageAuthModeKey), false, errs)
+	uploadOptionsValue, errs := readFileContent(fs, filePaths.GetConfigPath(UploadOptionsKey), false, errs)
+	redactionRules, errs := readFileContent(fs, filePaths.GetConfigPath(RedactionRulesKey), false, errs)
+	encryptionRecipients, errs := readFileContent(fs, filePaths.GetConfigPath(EncryptionKey), false, errs)
+	sizeBudgetsValue, errs := readFileContent(fs, filePaths.GetConfigPath(SizeBudgetsKey), false, errs)
+	runSizeBudgetValue, errs := readFileContent(fs, filePaths.GetConfigPath(RunSizeBudgetKey), false, errs)
+	archiveFormat, errs := readFileContent(fs, filePaths.GetConfigPath(ArchiveFormatKey), false, errs)
+	deduplicationValue, errs := readFileContent(fs, filePaths.GetConfigPath(DeduplicationKey), false, errs)
+	dryRunValue, errs := readFileContent(fs, filePaths.GetConfigPath(DryRunKey), false, errs)
+
+	collectorTimeout, collectorTimeouts, err := parseCollectorTimeouts(collectorTimeoutsValue)
+	if err != nil {
+		valueErrs = multierror.Append(valueErrs, fmt.Errorf("invalid %s value: %w", CollectorTimeoutsKey, err))
+	}
+
+	uploadOptions, err := parseUploadOptions(uploadOptionsValue)
+	if err != nil {
+		valueErrs = multierror.Append(valueErrs, fmt.Errorf("invalid %s value: %w", UploadOptionsKey, err))
+	}
+
+	sizeBudget, sizeBudgets, err := parseSizeBudgets(sizeBudgetsValue)
+	if err != nil {
+		valueErrs = multierror.Append(valueErrs, fmt.Errorf("invalid %s value: %w", SizeBudgetsKey, err))
+	}
+
+	runSizeBudget, err := parseSize(runSizeBudgetValue)
+	if err != nil {
+		valueErrs = multierror.Append(valueErrs, fmt.Errorf("invalid %s value: %w", RunSizeBudgetKey, err))
+	}
+
+	// Deduplication is disabled unless a maximum age is configured for the runs that unchanged data can refer to.
+	var deduplicationMaxAge time.Duration
+	if deduplicationValue = strings.TrimSpace(deduplicationValue); deduplicationValue != "" {
+		deduplicationMaxAge, err = parsePositiveDuration(deduplicationValue)
+		if err != nil {
+			valueErrs = multierror.Append(valueErrs, fmt.Errorf("invalid %s value: %w", DeduplicationKey, err))
+		}
+	}
+
+	// In a dry run, only the plan of what would be collected is exported.
+	var dryRun bool
+	if dryRunValue = strings.TrimSpace(dryRunValue); dryRunValue != "" {
+		dryRun, err = strconv.ParseBool(dryRunValue)
+		if err != nil {
+			valueErrs = multierror.Append(valueErrs, fmt.Errorf("invalid %s value: %w", DryRunKey, err))
+		}
+	}
 
 	// Secret
 	storageAccountName, errs := readFileContent(fs, filePaths.GetSecretPath(AccountNameKey), false, errs)
 	storageSasKey, errs := readFileContent(fs, filePaths.GetSecretPath(SasTokenKey), false, errs)
 	storageContainerName, errs := readFileContent(fs, filePaths.GetSecretPath(ContainerNameKey), false, errs)
 	storageSasKeyType, errs := readFileContent(fs, filePaths.GetSecretPath(SasTokenTypeKey), false, errs)
+	storageAuthMode, errs := readFileContent(fs, filePaths.GetSecretPath(AuthModeKey), false, errs)
+	storageClientId, errs := readFileContent(fs, filePaths.GetSecretPath(ClientIdKey), false, errs)
+	s3Endpoint, errs := readFileContent(fs, filePaths.GetSecretPath(S3EndpointKey), false, errs)
+	s3Bucket, errs := readFileContent(fs, filePaths.GetSecretPath(S3BucketKey), false, errs)
+	s3Region, errs := readFileContent(fs, filePaths.GetSecretPath(S3RegionKey), false, errs)
+	s3AccessKeyId, errs := readFileContent(fs, filePaths.GetSecretPath(S3AccessKeyIdKey), false, errs)
+	s3SecretAccessKey, errs := readFileContent(fs, filePaths.GetSecretPath(S3SecretKeyKey), false, errs)
 
 	// We can't use `os.Hostname` for this, because this gives us the _container_ hostname (i.e. the pod name, by default).
 	// An earlier approach was to `cat /etc/hostname` but that will not work for Windows containers.
@@ -61,6 +149,18 @@ func GetRuntimeInfo(fs interfaces.FileSystemAccessor, filePaths *KnownFilePaths)
 		errs = multierror.Append(errs, errors.New("variable HOST_NODE_NAME value not set for container"))
 	}
 
+	// The namespace is needed for writing namespaced resources (i.e. Diagnostics). It is expected to be exposed via
+	// the downward API, but we can fall back to the service account namespace for deployments that don't do that.
+	namespace := os.Getenv("POD_NAMESPACE")
+	if len(namespace) == 0 {
+		namespace, errs = readFileContent(fs, filePaths.ServiceAccountNamespace, false, errs)
+	}
+
+	// The storage authentication mode can be set in either the secret or the config, with the secret taking precedence.
+	if len(strings.TrimSpace(storageAuthMode)) == 0 {
+		storageAuthMode = configStorageAuthMode
+	}
+
 	features := map[Feature]bool{}
 	for _, feature := range getKnownFeatures() {
 		featureFilePath := filePaths.GetFeaturePath(feature)
@@ -78,6 +178,7 @@ func GetRuntimeInfo(fs interfaces.FileSystemAccessor, filePaths *KnownFilePaths)
 	return &RuntimeInfo{
 		RunId:                   runId,
 		HostNodeName:            hostName,
+		Namespace:               strings.TrimSpace(namespace),
 		CollectorList:           strings.Fields(collectorList),
 		KubernetesObjects:       strings.Fields(kubernetesObjects),
 		NodeLogs:                strings.Fields(nodeLogs),
@@ -86,8 +187,28 @@ func GetRuntimeInfo(fs interfaces.FileSystemAccessor, filePaths *KnownFilePaths)
 		StorageSasKey:           storageSasKey,
 		StorageContainerName:    storageContainerName,
 		StorageSasKeyType:       storageSasKeyType,
+		StorageAuthMode:         strings.TrimSpace(storageAuthMode),
+		StorageClientId:         strings.TrimSpace(storageClientId),
+		S3Endpoint:              strings.TrimSpace(s3Endpoint),
+		S3Bucket:                strings.TrimSpace(s3Bucket),
+		S3Region:                strings.TrimSpace(s3Region),
+		S3AccessKeyId:           strings.TrimSpace(s3AccessKeyId),
+		S3SecretAccessKey:       strings.TrimSpace(s3SecretAccessKey),
+		Exporter:                strings.TrimSpace(exporter),
+		ExportDirectory:         strings.TrimSpace(exportDirectory),
+		CollectorTimeout:        collectorTimeout,
+		CollectorTimeouts:       collectorTimeouts,
+		UploadOptions:           uploadOptions,
+		SizeBudget:              sizeBudget,
+		SizeBudgets:             sizeBudgets,
+		RunSizeBudget:           runSizeBudget,
+		ArchiveFormat:           strings.TrimSpace(archiveFormat),
+		DeduplicationMaxAge:     deduplicationMaxAge,
+		RedactionRules:          strings.Split(redactionRules, "\n"),
+		EncryptionRecipients:    strings.TrimSpace(encryptionRecipients),
+		DryRun:                  dryRun,
 		Features:                features,
-	}, nil
+	}, valueErrs
 }
 
 func readFileContent(fs interfaces.FileSystemAccessor, filePath string, mandatory bool, readErrors error) (string, error) {
@@ -113,6 +234,65 @@ func readFileContent(fs interfaces.FileSystemAccessor, filePath string, mandator
 	return value, readErrors
 }
 
+// parseCollectorTimeouts reads a space-separated list of timeouts, where an unqualified duration (e.g. "10m") sets
+// the default timeout, and a "name=duration" entry (e.g. "osm=20m") sets the timeout for the named collector or
+// diagnoser (or both, if they have the same name).
+func parseCollectorTimeouts(value string) (time.Duration, map[string]time.Duration, error) {
+	defaultTimeout := DefaultCollectorTimeout
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Fields(value) {
+		name, durationValue, isNamed := strings.Cut(entry, "=")
+		if !isNamed {
+			durationValue = name
+		}
+
+		duration, err := time.ParseDuration(durationValue)
+		if err != nil {
+			return 0, nil, err
+		}
+		if duration <= 0 {
+			return 0, nil, fmt.Errorf("timeout must be positive: %s", entry)
+		}
+
+		if isNamed {
+			timeouts[name] = duration
+		} else {
+			defaultTimeout = duration
+		}
+	}
+
+	return defaultTimeout, timeouts, nil
+}
+
+// GetCollectorTimeout gets the deadline for the named collector (or diagnoser), falling back to the default if none is
+// configured.
+func (runtimeInfo *RuntimeInfo) GetCollectorTimeout(name string) time.Duration {
+	if timeout, ok := runtimeInfo.CollectorTimeouts[name]; ok {
+		return timeout
+	}
+	if runtimeInfo.CollectorTimeout > 0 {
+		return runtimeInfo.CollectorTimeout
+	}
+	return DefaultCollectorTimeout
+}
+
+// GetUploadOptions gets the options for uploading data, falling back to the defaults if none are configured.
+func (runtimeInfo *RuntimeInfo) GetUploadOptions() *UploadOptions {
+	if runtimeInfo.UploadOptions != nil {
+		return runtimeInfo.UploadOptions
+	}
+	return GetDefaultUploadOptions()
+}
+
+// GetSizeBudget gets the maximum number of bytes exported for the named producer, falling back to the default if none
+// is configured. Zero means the data is not limited.
+func (runtimeInfo *RuntimeInfo) GetSizeBudget(name string) int64 {
+	if budget, ok := runtimeInfo.SizeBudgets[name]; ok {
+		return budget
+	}
+	return runtimeInfo.SizeBudget
+}
+
 func (runtimeInfo *RuntimeInfo) HasFeature(feature Feature) bool {
 	_, ok := runtimeInfo.Features[feature]
 	return ok
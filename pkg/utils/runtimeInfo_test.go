@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/test"
+)
+
+func TestParseCollectorTimeouts(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantDefault  time.Duration
+		wantTimeouts map[string]time.Duration
+		wantErr      bool
+	}{
+		{
+			name:         "empty",
+			value:        "",
+			wantDefault:  DefaultCollectorTimeout,
+			wantTimeouts: map[string]time.Duration{},
+			wantErr:      false,
+		},
+		{
+			name:         "default only",
+			value:        "5m",
+			wantDefault:  5 * time.Minute,
+			wantTimeouts: map[string]time.Duration{},
+			wantErr:      false,
+		},
+		{
+			name:        "default and named",
+			value:       "5m osm=20m helm=90s",
+			wantDefault: 5 * time.Minute,
+			wantTimeouts: map[string]time.Duration{
+				"osm":  20 * time.Minute,
+				"helm": 90 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid duration",
+			value:   "osm=soon",
+			wantErr: true,
+		},
+		{
+			name:    "non-positive duration",
+			value:   "0s",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultTimeout, timeouts, err := parseCollectorTimeouts(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCollectorTimeouts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if defaultTimeout != tt.wantDefault {
+				t.Errorf("unexpected default timeout: expected %s, found %s", tt.wantDefault, defaultTimeout)
+			}
+			if !reflect.DeepEqual(timeouts, tt.wantTimeouts) {
+				t.Errorf("unexpected timeouts: expected %v, found %v", tt.wantTimeouts, timeouts)
+			}
+		})
+	}
+}
+
+func TestGetCollectorTimeout(t *testing.T) {
+	runtimeInfo := &RuntimeInfo{
+		CollectorTimeout:  5 * time.Minute,
+		CollectorTimeouts: map[string]time.Duration{"osm": 20 * time.Minute},
+	}
+
+	if timeout := runtimeInfo.GetCollectorTimeout("osm"); timeout != 20*time.Minute {
+		t.Errorf("unexpected timeout for osm: %s", timeout)
+	}
+	if timeout := runtimeInfo.GetCollectorTimeout("dns"); timeout != 5*time.Minute {
+		t.Errorf("unexpected timeout for dns: %s", timeout)
+	}
+}
+
+func TestGetRuntimeInfoInvalidValues(t *testing.T) {
+	t.Setenv("HOST_NODE_NAME", "node1")
+	t.Setenv("POD_NAMESPACE", "aks-periscope")
+
+	filePaths, err := GetKnownFilePaths(Linux)
+	if err != nil {
+		t.Fatalf("error getting known file paths: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     ConfigKey
+		value   string
+		wantErr bool
+	}{
+		{"valid", CollectorTimeoutsKey, "10m", false},
+		{"collector timeouts", CollectorTimeoutsKey, "ten", true},
+		{"upload options", UploadOptionsKey, "maxTries", true},
+		{"size budgets", SizeBudgetsKey, "huge", true},
+		{"run size budget", RunSizeBudgetKey, "-1", true},
+		{"deduplication", DeduplicationKey, "often", true},
+		{"dry run", DryRunKey, "maybe", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := test.NewFakeFileSystem(map[string]string{
+				filePaths.GetConfigPath(RunIdKey): "run1",
+				filePaths.GetConfigPath(tt.key):   tt.value,
+			})
+
+			// Invalid values are reported along with the runtime info, so that the run can be reported as failed.
+			runtimeInfo, err := GetRuntimeInfo(fs, filePaths)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetRuntimeInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), string(tt.key)) {
+				t.Errorf("expected error to name %s, found %v", tt.key, err)
+			}
+			if runtimeInfo == nil || runtimeInfo.RunId != "run1" || runtimeInfo.HostNodeName != "node1" {
+				t.Errorf("unexpected runtime info %+v", runtimeInfo)
+			}
+		})
+	}
+
+	// If the config can't be read, there is no runtime info.
+	if runtimeInfo, err := GetRuntimeInfo(test.NewFakeFileSystem(map[string]string{}), filePaths); err == nil || runtimeInfo != nil {
+		t.Errorf("expected error without runtime info for missing run ID, found %v, %+v", err, runtimeInfo)
+	}
+}
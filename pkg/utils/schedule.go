@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinScheduleInterval is the shortest interval between scheduled runs, which is also the resolution of cron schedules.
+const MinScheduleInterval = time.Minute
+
+// maxScheduleSearch is how far ahead the next time of a cron schedule is searched for, beyond which it is assumed
+// that the schedule never matches (e.g. "0 0 30 2 *").
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule determines when scheduled runs start.
+type Schedule interface {
+	// Next gets the first scheduled time after the specified time.
+	Next(after time.Time) time.Time
+}
+
+// ParseSchedule reads a schedule, which is either an interval (e.g. "6h", or "@every 6h"), a predefined schedule
+// (e.g. "@daily") or a standard five-field cron expression (e.g. "0 */6 * * *"), evaluated in UTC.
+// An empty value results in a nil schedule.
+func ParseSchedule(value string) (Schedule, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(value, "@every"))); err == nil {
+		if interval < MinScheduleInterval {
+			return nil, fmt.Errorf("interval must be at least %s: %s", MinScheduleInterval, value)
+		}
+		return &intervalSchedule{interval: interval}, nil
+	} else if strings.HasPrefix(value, "@every") {
+		return nil, fmt.Errorf("invalid interval: %w", err)
+	}
+
+	expression := value
+	if strings.HasPrefix(value, "@") {
+		var ok bool
+		expression, ok = predefinedSchedules[value]
+		if !ok {
+			return nil, fmt.Errorf("unknown predefined schedule: %s", value)
+		}
+	}
+
+	schedule, err := parseCronSchedule(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule never matches: %s", value)
+	}
+
+	return schedule, nil
+}
+
+var predefinedSchedules = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// intervalSchedule runs at a fixed interval, aligned to the Unix epoch so that the scheduled times are the same for
+// every node, regardless of when each evaluates the schedule.
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s *intervalSchedule) Next(after time.Time) time.Time {
+	epoch := time.Unix(0, 0)
+	intervals := after.Sub(epoch) / s.interval
+	return epoch.Add((intervals + 1) * s.interval).UTC()
+}
+
+// cronSchedule matches times whose fields are all included in the corresponding sets, represented as bit masks.
+type cronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// As in cron, if both days of the month and days of the week are restricted, a day matching either is included.
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// Sunday can be either 0 or 7.
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+func parseCronSchedule(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields in cron expression: %s", len(cronFields), expression)
+	}
+
+	masks := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		masks[i], err = cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", cronFields[i].name, field, err)
+		}
+	}
+
+	daysOfWeek := masks[4]
+	if daysOfWeek&(1<<7) != 0 {
+		daysOfWeek |= 1
+	}
+
+	return &cronSchedule{
+		minutes:       masks[0],
+		hours:         masks[1],
+		daysOfMonth:   masks[2],
+		months:        masks[3],
+		daysOfWeek:    daysOfWeek,
+		anyDayOfMonth: fields[2] == "*" || fields[2] == "?",
+		anyDayOfWeek:  fields[4] == "*" || fields[4] == "?",
+	}, nil
+}
+
+// parse reads a comma-separated list of values, ranges ("a-b") or wildcards ("*"), each with an optional step ("/n").
+func (f *cronField) parse(value string) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(value, ",") {
+		rangeValue, stepValue, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepValue)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %s", stepValue)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangeValue != "*" && rangeValue != "?" {
+			startValue, endValue, isRange := strings.Cut(rangeValue, "-")
+
+			var err error
+			start, err = f.parseValue(startValue)
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if isRange {
+				end, err = f.parseValue(endValue)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// A single value with a step (e.g. "5/15") runs from that value to the maximum.
+				end = f.max
+			}
+
+			if end < start {
+				return 0, fmt.Errorf("range end is before start: %s", rangeValue)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			mask |= 1 << i
+		}
+	}
+
+	return mask, nil
+}
+
+func (f *cronField) parseValue(value string) (int, error) {
+	if number, ok := f.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %s", value)
+	}
+	if number < f.min || number > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", number, f.min, f.max)
+	}
+
+	return number, nil
+}
+
+// Next finds the next matching time by moving forward to the next matching month, then day, hour and minute,
+// starting again from the month whenever moving forward changes a larger field.
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleSearch)
+
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
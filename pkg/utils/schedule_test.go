@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty", value: " ", wantNil: true},
+		{name: "interval", value: "6h"},
+		{name: "every", value: "@every 30m"},
+		{name: "predefined", value: "@daily"},
+		{name: "cron", value: "*/15 0-6,18-23 * JAN-mar mon-fri"},
+		{name: "interval too short", value: "10s", wantErr: true},
+		{name: "invalid every", value: "@every often", wantErr: true},
+		{name: "unknown predefined", value: "@fortnightly", wantErr: true},
+		{name: "too few fields", value: "0 * * *", wantErr: true},
+		{name: "out of range", value: "60 * * * *", wantErr: true},
+		{name: "reversed range", value: "0 6-1 * * *", wantErr: true},
+		{name: "invalid step", value: "*/0 * * * *", wantErr: true},
+		{name: "never matches", value: "0 0 30 2 *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (schedule == nil) != tt.wantNil {
+			t.Errorf("%s: unexpected schedule %v", tt.name, schedule)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	after := time.Date(2024, time.January, 31, 22, 47, 30, 0, time.UTC) // A Wednesday
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		// Intervals are aligned to the Unix epoch, rather than measured from the time the schedule is evaluated.
+		{value: "1h", want: time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)},
+		{value: "90m", want: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "@every 7m", want: time.Date(2024, time.January, 31, 22, 50, 0, 0, time.UTC)},
+		{value: "*/15 * * * *", want: time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)},
+		{value: "@hourly", want: time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)},
+		{value: "@daily", want: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "30 6 * * 1", want: time.Date(2024, time.February, 5, 6, 30, 0, 0, time.UTC)},
+		{value: "0 0 * * 7", want: time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		{value: "0 12 29 2 *", want: time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)},
+		{value: "5/20 22 * * *", want: time.Date(2024, time.February, 1, 22, 5, 0, 0, time.UTC)},
+		// When both days of the month and days of the week are restricted, either can match.
+		{value: "0 0 15 * fri", want: time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.value)
+		if err != nil {
+			t.Errorf("%s: ParseSchedule() error = %v", tt.value, err)
+			continue
+		}
+
+		if next := schedule.Next(after); !next.Equal(tt.want) {
+			t.Errorf("%s: expected next time %s, found %s", tt.value, tt.want, next)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-periscope/pkg/logging"
+)
+
+// runIdFormat is the format of generated run IDs, which are the UTC time the run was started.
+const runIdFormat = "2006-01-02T15-04-05Z"
+
+// schedulerComponent is the component lines about scheduled runs are logged with.
+const schedulerComponent = "scheduler"
+
+// GenerateRunId generates a run ID for a run started at the specified time.
+func GenerateRunId(start time.Time) string {
+	return start.UTC().Format(runIdFormat)
+}
+
+// Scheduler starts runs according to a schedule, by sending a run ID generated from the scheduled time to a channel,
+// so that every node running the same schedule starts each run with the same ID. The run ID is only sent if the
+// receiver is ready for it, so a scheduled run is skipped (rather than queued) if the previous run is still in
+// progress. Runs can still be triggered in other ways by sending to the same channel.
+type Scheduler struct {
+	runIdChan  chan<- string
+	lock       sync.Mutex
+	schedule   Schedule
+	timer      *time.Timer
+	generation int
+}
+
+// NewScheduler creates a Scheduler which sends run IDs to the specified channel. It has no schedule (and so doesn't
+// start any runs) until SetSchedule is called.
+func NewScheduler(runIdChan chan<- string) *Scheduler {
+	return &Scheduler{runIdChan: runIdChan}
+}
+
+// SetSchedule replaces the current schedule, with the next run scheduled according to the new schedule.
+// A nil schedule stops scheduled runs.
+func (s *Scheduler) SetSchedule(schedule Schedule) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	// Any trigger from the previous schedule that is already running is ignored.
+	s.generation++
+	s.schedule = schedule
+	s.scheduleNext(time.Now())
+}
+
+func (s *Scheduler) scheduleNext(after time.Time) {
+	if s.schedule == nil {
+		return
+	}
+
+	next := s.schedule.Next(after)
+	if next.IsZero() {
+		slog.Info("No further scheduled runs", logging.ComponentKey, schedulerComponent)
+		return
+	}
+
+	generation := s.generation
+	slog.Info("Next scheduled run", logging.ComponentKey, schedulerComponent, "time", next.UTC().Format(time.RFC3339))
+	s.timer = time.AfterFunc(time.Until(next), func() {
+		s.trigger(generation, next)
+	})
+}
+
+func (s *Scheduler) trigger(generation int, scheduled time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if generation != s.generation {
+		return
+	}
+
+	runId := GenerateRunId(scheduled)
+	select {
+	case s.runIdChan <- runId:
+		slog.Info("Triggered scheduled run", logging.ComponentKey, schedulerComponent, logging.RunIdKey, runId)
+	default:
+		slog.Warn("Skipping scheduled run, the previous run is still in progress", logging.ComponentKey, schedulerComponent, logging.RunIdKey, runId)
+	}
+
+	// Schedule from the later of the scheduled and current times, so that a timer firing slightly early doesn't
+	// trigger the same scheduled time again.
+	after := time.Now()
+	if scheduled.After(after) {
+		after = scheduled
+	}
+	s.scheduleNext(after)
+}
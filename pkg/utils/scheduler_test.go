@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// testSchedule runs at a fixed short interval, which is not allowed for parsed schedules.
+type testSchedule struct {
+	interval time.Duration
+}
+
+func (s *testSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func TestScheduler(t *testing.T) {
+	runIdChan := make(chan string)
+	scheduler := NewScheduler(runIdChan)
+	scheduler.SetSchedule(&testSchedule{interval: 20 * time.Millisecond})
+
+	// While nothing is receiving (i.e. a run is in progress), scheduled runs are skipped rather than blocking.
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case runId := <-runIdChan:
+		if _, err := time.Parse(runIdFormat, runId); err != nil {
+			t.Errorf("unexpected run ID %s: %v", runId, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a scheduled run")
+	}
+
+	scheduler.SetSchedule(nil)
+	select {
+	case runId := <-runIdChan:
+		t.Errorf("unexpected run %s after schedule was removed", runId)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// triggerScheduledRun triggers the first run of the schedule after the specified time, as a scheduler evaluating the
+// schedule at that time would, and returns the run's ID.
+func triggerScheduledRun(schedule Schedule, after time.Time) string {
+	runIdChan := make(chan string, 1)
+	scheduler := NewScheduler(runIdChan)
+	scheduler.trigger(scheduler.generation, schedule.Next(after))
+	return <-runIdChan
+}
+
+func TestSchedulersAgreeOnRunId(t *testing.T) {
+	schedule, err := ParseSchedule("6h")
+	if err != nil {
+		t.Fatalf("ParseSchedule() error = %v", err)
+	}
+
+	// Schedulers on different nodes evaluate the schedule at different times (e.g. because their pods were started at
+	// different times), but trigger each scheduled run with the same ID.
+	start := time.Date(2024, time.March, 1, 10, 4, 5, 0, time.UTC)
+	for _, after := range []time.Time{start, start.Add(17 * time.Minute), start.Add(time.Hour + 55*time.Minute)} {
+		if runId := triggerScheduledRun(schedule, after); runId != "2024-03-01T12-00-00Z" {
+			t.Errorf("expected run ID for the 12:00 slot when scheduled after %s, found %s", after, runId)
+		}
+	}
+}
+
+func TestGenerateRunId(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 10, 4, 5, 0, time.FixedZone("UTC+2", 2*60*60))
+	if runId := GenerateRunId(start); runId != "2024-03-01T08-04-05Z" {
+		t.Errorf("unexpected run ID %s", runId)
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// parseSizeBudgets reads a space-separated list of sizes, where an unqualified size (e.g. "100Mi") sets the default
+// budget for every producer, and a "name=size" entry (e.g. "osm=20Mi") sets the budget for the named producer.
+// A budget of zero means the data is not limited.
+func parseSizeBudgets(value string) (int64, map[string]int64, error) {
+	var defaultBudget int64
+	budgets := map[string]int64{}
+	for _, entry := range strings.Fields(value) {
+		name, sizeValue, isNamed := strings.Cut(entry, "=")
+		if !isNamed {
+			sizeValue = name
+		}
+
+		size, err := parseSize(sizeValue)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid size budget %s: %w", entry, err)
+		}
+
+		if isNamed {
+			budgets[name] = size
+		} else {
+			defaultBudget = size
+		}
+	}
+
+	return defaultBudget, budgets, nil
+}
+
+// parseSize reads a size in bytes expressed as a quantity (e.g. "1Gi" or "500M"). An empty value is zero.
+func parseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() < 0 {
+		return 0, fmt.Errorf("size must not be negative: %s", value)
+	}
+
+	return quantity.Value(), nil
+}
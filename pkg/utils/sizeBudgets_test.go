@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSizeBudgets(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantDefault int64
+		wantBudgets map[string]int64
+		wantErr     bool
+	}{
+		{
+			name:        "empty",
+			value:       "",
+			wantDefault: 0,
+			wantBudgets: map[string]int64{},
+		},
+		{
+			name:        "default and named",
+			value:       "100Mi osm=20Mi systemlogs=1M",
+			wantDefault: 100 * 1024 * 1024,
+			wantBudgets: map[string]int64{"osm": 20 * 1024 * 1024, "systemlogs": 1000000},
+		},
+		{
+			name:        "named unlimited",
+			value:       "10Mi nodelogs=0",
+			wantDefault: 10 * 1024 * 1024,
+			wantBudgets: map[string]int64{"nodelogs": 0},
+		},
+		{
+			name:    "invalid size",
+			value:   "osm=lots",
+			wantErr: true,
+		},
+		{
+			name:    "negative size",
+			value:   "-1Mi",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		defaultBudget, budgets, err := parseSizeBudgets(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if defaultBudget != tt.wantDefault {
+			t.Errorf("%s: unexpected default budget: expected %d, found %d", tt.name, tt.wantDefault, defaultBudget)
+		}
+		if !reflect.DeepEqual(budgets, tt.wantBudgets) {
+			t.Errorf("%s: unexpected budgets: expected %v, found %v", tt.name, tt.wantBudgets, budgets)
+		}
+	}
+}
+
+func TestGetSizeBudget(t *testing.T) {
+	runtimeInfo := &RuntimeInfo{
+		SizeBudget:  10,
+		SizeBudgets: map[string]int64{"osm": 20, "nodelogs": 0},
+	}
+
+	tests := map[string]int64{"osm": 20, "nodelogs": 0, "dns": 10}
+	for name, expected := range tests {
+		if budget := runtimeInfo.GetSizeBudget(name); budget != expected {
+			t.Errorf("unexpected budget for %s: expected %d, found %d", name, expected, budget)
+		}
+	}
+}
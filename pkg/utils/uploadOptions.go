@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// UploadOptions controls how exporters upload data to remote storage.
+type UploadOptions struct {
+	// MaxTries is the maximum number of attempts to upload each key, including the first. Uploads that can't be retried
+	// as a whole (e.g. of the archive) instead make up to this many attempts at each of their requests.
+	MaxTries int
+	// RetryDelay is the delay before the first retry, which doubles for each subsequent retry up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// TryTimeout is the deadline for each individual storage request.
+	TryTimeout time.Duration
+	// BlockSize is the size of each block of a streamed upload, and Parallelism is the number of blocks uploaded
+	// concurrently. Each upload buffers up to BlockSize * Parallelism bytes in memory.
+	BlockSize   int64
+	Parallelism int
+}
+
+// GetDefaultUploadOptions returns the options used when none are configured. The block size and parallelism
+// are kept small because several collectors may be uploading at once, within the container's memory limit.
+func GetDefaultUploadOptions() *UploadOptions {
+	return &UploadOptions{
+		MaxTries:      3,
+		RetryDelay:    4 * time.Second,
+		MaxRetryDelay: time.Minute,
+		TryTimeout:    time.Minute,
+		BlockSize:     1024 * 1024,
+		Parallelism:   1,
+	}
+}
+
+// parseUploadOptions reads a space-separated list of "name=value" entries (e.g. "maxTries=5 blockSize=4Mi"),
+// overriding the defaults for the named options.
+func parseUploadOptions(value string) (*UploadOptions, error) {
+	options := GetDefaultUploadOptions()
+	for _, entry := range strings.Fields(value) {
+		name, optionValue, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=value: %s", entry)
+		}
+
+		var err error
+		switch name {
+		case "maxTries":
+			options.MaxTries, err = parsePositiveInt(optionValue)
+		case "parallelism":
+			options.Parallelism, err = parsePositiveInt(optionValue)
+		case "retryDelay":
+			options.RetryDelay, err = parsePositiveDuration(optionValue)
+		case "maxRetryDelay":
+			options.MaxRetryDelay, err = parsePositiveDuration(optionValue)
+		case "tryTimeout":
+			options.TryTimeout, err = parsePositiveDuration(optionValue)
+		case "blockSize":
+			var quantity resource.Quantity
+			quantity, err = resource.ParseQuantity(optionValue)
+			if err == nil && quantity.Value() <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+			options.BlockSize = quantity.Value()
+		default:
+			err = fmt.Errorf("unknown option")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload option %s: %w", entry, err)
+		}
+	}
+
+	if options.MaxRetryDelay < options.RetryDelay {
+		options.MaxRetryDelay = options.RetryDelay
+	}
+
+	return options, nil
+}
+
+func parsePositiveInt(value string) (int, error) {
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if result <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return result, nil
+}
+
+func parsePositiveDuration(value string) (time.Duration, error) {
+	result, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if result <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return result, nil
+}
+
+// GetRetryDelay returns the exponential backoff delay before the specified retry (where 1 is the first retry).
+func (options *UploadOptions) GetRetryDelay(retry int) time.Duration {
+	delay := options.RetryDelay
+	for i := 1; i < retry && delay < options.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > options.MaxRetryDelay {
+		return options.MaxRetryDelay
+	}
+	return delay
+}
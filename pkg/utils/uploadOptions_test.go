@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseUploadOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *UploadOptions
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			value:   "",
+			want:    GetDefaultUploadOptions(),
+			wantErr: false,
+		},
+		{
+			name:  "all options",
+			value: "maxTries=5 retryDelay=2s maxRetryDelay=30s tryTimeout=5m blockSize=4Mi parallelism=4",
+			want: &UploadOptions{
+				MaxTries:      5,
+				RetryDelay:    2 * time.Second,
+				MaxRetryDelay: 30 * time.Second,
+				TryTimeout:    5 * time.Minute,
+				BlockSize:     4 * 1024 * 1024,
+				Parallelism:   4,
+			},
+			wantErr: false,
+		},
+		{
+			name:  "retry delay longer than max",
+			value: "retryDelay=2m",
+			want: &UploadOptions{
+				MaxTries:      3,
+				RetryDelay:    2 * time.Minute,
+				MaxRetryDelay: 2 * time.Minute,
+				TryTimeout:    time.Minute,
+				BlockSize:     1024 * 1024,
+				Parallelism:   1,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "missing value",
+			value:   "maxTries",
+			wantErr: true,
+		},
+		{
+			name:    "unknown option",
+			value:   "retries=5",
+			wantErr: true,
+		},
+		{
+			name:    "non-positive count",
+			value:   "parallelism=0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			value:   "blockSize=big",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := parseUploadOptions(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUploadOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(options, tt.want) {
+				t.Errorf("unexpected options: expected %+v, found %+v", tt.want, options)
+			}
+		})
+	}
+}
+
+func TestGetRetryDelay(t *testing.T) {
+	options := &UploadOptions{RetryDelay: 4 * time.Second, MaxRetryDelay: time.Minute}
+	expected := []time.Duration{4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+
+	for i, want := range expected {
+		if got := options.GetRetryDelay(i + 1); got != want {
+			t.Errorf("GetRetryDelay(%d) = %s, want %s", i+1, got, want)
+		}
+	}
+}